@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lebensmittel/backend/database"
+	"github.com/lebensmittel/backend/models"
+)
+
+const claimCodeUsage = `usage: backend claim-code <group-id>
+
+Creates a single-use code that lets one device claim a group from before
+access tokens with POST /api/groups/:group_id/claim. Hand it to the group's
+owner out of band.`
+
+// runClaimCodeCommand handles `backend claim-code ...` without starting the
+// server.
+func runClaimCodeCommand(cfg *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%s", claimCodeUsage)
+	}
+
+	ctx := context.Background()
+	pool, err := database.Connect(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	store := database.NewPostgresStore(pool)
+
+	group, err := store.GetGroupByID(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return fmt.Errorf("group %s not found", args[0])
+	}
+	if group.RequiresToken {
+		return fmt.Errorf("group %s has already been claimed", args[0])
+	}
+
+	maxUses := 1
+	invite := models.NewInvite(group.ID, nil, models.DefaultInviteTTL, &maxUses)
+	if err := store.CreateInvite(ctx, invite); err != nil {
+		return err
+	}
+	fmt.Printf("Claim code for %s: %s (expires %s)\n", group.ID, invite.Code, invite.ExpiresAt.Format("2006-01-02 15:04 MST"))
+	return nil
+}
@@ -3,23 +3,30 @@ package main
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 // Config holds all configuration for the application
 type Config struct {
 	DatabaseURL string
-	Port        string
-	SecretKey   string
-	Debug       bool
+	// Storage selects the Store implementation: "postgres" or "memory"
+	Storage   string
+	Port      string
+	SecretKey string
+	Debug     bool
+	// RecurrenceInterval is how often recurring grocery items are checked
+	RecurrenceInterval time.Duration
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 	config := &Config{
-		DatabaseURL: getEnv("DATABASE_URL", "postgres://jsinha:@localhost/lebensmittel"),
-		Port:        getEnv("PORT", "8000"),
-		SecretKey:   getEnv("SECRET_KEY", "your-secret-key-here"),
-		Debug:       getEnvBool("DEBUG", false),
+		DatabaseURL:        getEnv("DATABASE_URL", "postgres://jsinha:@localhost/lebensmittel"),
+		Storage:            getEnv("STORAGE", "postgres"),
+		Port:               getEnv("PORT", "8000"),
+		SecretKey:          getEnv("SECRET_KEY", "your-secret-key-here"),
+		Debug:              getEnvBool("DEBUG", false),
+		RecurrenceInterval: getEnvDuration("RECURRENCE_INTERVAL", time.Minute),
 	}
 
 	return config
@@ -42,3 +49,14 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvDuration gets a positive duration environment variable, like "30s",
+// with a default fallback
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultValue
+}
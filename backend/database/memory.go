@@ -0,0 +1,1418 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lebensmittel/backend/models"
+)
+
+// MemoryStore is a Store that keeps all data in process memory. It is meant
+// for tests and local development; everything is lost on restart.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	groceryItems map[string]models.GroceryItem
+	mealPlans    map[string]models.MealPlan
+	receipts     map[string]models.Receipt
+	settlements  map[string]models.Settlement
+	purchases    []models.GroceryPurchase
+	groups       map[string]models.Group
+	categories   map[string]models.Category // category ID -> category
+	members      map[string]models.Member   // member ID -> member
+	userGroups   map[string][]string        // legacy user ID -> group IDs
+	changeSeqs   map[string]int64           // group ID -> last change sequence
+	tombstones   map[string]memoryTombstone // "type:id" -> tombstone
+	idempotency  map[idempotencyKey]memoryIdempotencyRecord
+	accessTokens map[string]models.AccessToken // token ID -> token
+	invites      map[string]models.Invite      // code -> invite
+}
+
+type idempotencyKey struct {
+	groupID, key string
+}
+
+type memoryIdempotencyRecord struct {
+	IdempotencyRecord
+	CreatedAt time.Time
+}
+
+// memoryTombstone is a Tombstone plus the group it belongs to, which the API
+// type leaves out because syncs are always scoped to one group.
+type memoryTombstone struct {
+	models.Tombstone
+	GroupID string
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		groceryItems: map[string]models.GroceryItem{},
+		mealPlans:    map[string]models.MealPlan{},
+		receipts:     map[string]models.Receipt{},
+		settlements:  map[string]models.Settlement{},
+		groups:       map[string]models.Group{},
+		categories:   map[string]models.Category{},
+		members:      map[string]models.Member{},
+		userGroups:   map[string][]string{},
+		changeSeqs:   map[string]int64{},
+		tombstones:   map[string]memoryTombstone{},
+		idempotency:  map[idempotencyKey]memoryIdempotencyRecord{},
+		accessTokens: map[string]models.AccessToken{},
+		invites:      map[string]models.Invite{},
+	}
+}
+
+func (s *MemoryStore) Close() {}
+
+// GroceryItems
+
+func (s *MemoryStore) GetAllGroceryItems(ctx context.Context, groupID string) ([]models.GroceryItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := []models.GroceryItem{}
+	for _, item := range s.groceryItems {
+		if item.GroupID == groupID {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+func (s *MemoryStore) GetGroceryItemByID(ctx context.Context, id, groupID string) (*models.GroceryItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.groceryItems[id]
+	if !ok || item.GroupID != groupID {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+func (s *MemoryStore) CreateGroceryItem(ctx context.Context, item *models.GroceryItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.groceryItems[item.ID]; exists {
+		return fmt.Errorf("grocery item %s already exists", item.ID)
+	}
+	category, ok := s.categories[item.CategoryID]
+	if !ok || category.GroupID != item.GroupID {
+		return ErrCategoryNotFound
+	}
+	if s.groceryItemNamed(item.GroupID, item.Name, item.ID) {
+		return ErrGroceryItemExists
+	}
+	item.Category = category.Name
+	item.ChangeSeq, item.UpdatedAt = s.nextChange(item.GroupID)
+	item.Version = 1
+	s.groceryItems[item.ID] = *item
+	return nil
+}
+
+func (s *MemoryStore) UpdateGroceryItem(ctx context.Context, id, groupID string, patch models.GroceryItemPatch) (*models.GroceryItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.groceryItems[id]
+	if !ok || item.GroupID != groupID {
+		return nil, nil
+	}
+	if !versionMatches(item.Version, patch.Version) {
+		return nil, ErrVersionConflict
+	}
+
+	if patch.CategoryID != nil {
+		category, ok := s.categories[*patch.CategoryID]
+		if !ok || category.GroupID != groupID {
+			return nil, ErrCategoryNotFound
+		}
+	}
+	if patch.Name != nil && s.groceryItemNamed(groupID, *patch.Name, id) {
+		return nil, models.FieldErrors{"name": "is already on the group's list"}
+	}
+
+	patch.Apply(&item)
+	item.Category = s.categories[item.CategoryID].Name
+	item.ChangeSeq, item.UpdatedAt = s.nextChange(groupID)
+	item.Version++
+	s.groceryItems[id] = item
+	return &item, nil
+}
+
+// groceryItemNamed reports whether the group has an item other than exceptID
+// with the same models.GroceryItemKey as name, like the unique index on
+// grocery_items.name_key.
+func (s *MemoryStore) groceryItemNamed(groupID, name, exceptID string) bool {
+	key := models.GroceryItemKey(name)
+	for _, item := range s.groceryItems {
+		if item.GroupID == groupID && item.ID != exceptID && models.GroceryItemKey(item.Name) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) DeleteGroceryItem(ctx context.Context, id, groupID string, expectedVersion *int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.groceryItems[id]
+	if !ok || item.GroupID != groupID {
+		return ErrGroceryItemNotFound
+	}
+	if !versionMatches(item.Version, expectedVersion) {
+		return ErrVersionConflict
+	}
+	delete(s.groceryItems, id)
+	s.recordDeletion(models.EntityGroceryItem, id, groupID)
+	s.unlinkLineItems(id)
+	s.purchases = slices.DeleteFunc(s.purchases, func(purchase models.GroceryPurchase) bool {
+		return purchase.GroceryItemID == id
+	})
+	return nil
+}
+
+func (s *MemoryStore) RenewRecurringGroceryItems(ctx context.Context, now time.Time) ([]models.GroceryItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	renewed := []models.GroceryItem{}
+	for id, item := range s.groceryItems {
+		if item.NextDueAt == nil || item.NextDueAt.After(now) {
+			continue
+		}
+		var next *time.Time
+		if item.Recurrence != nil {
+			nextDue := item.Recurrence.NextDue(item.NextDueAt, now)
+			next = &nextDue
+		}
+		if item.IsNeeded {
+			// Already on the list, so only the due date moves on
+			item.NextDueAt = next
+			s.groceryItems[id] = item
+			continue
+		}
+		// Items that weren't needed come back unchecked
+		item.IsShoppingChecked = false
+		item.IsNeeded, item.NextDueAt = true, next
+		item.ChangeSeq, item.UpdatedAt = s.nextChange(item.GroupID)
+		item.Version++
+		s.groceryItems[id] = item
+		renewed = append(renewed, item)
+	}
+	return renewed, nil
+}
+
+// MealPlans
+
+func (s *MemoryStore) GetAllMealPlans(ctx context.Context, groupID string) ([]models.MealPlan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	meals := []models.MealPlan{}
+	for _, meal := range s.mealPlans {
+		if meal.GroupID == groupID {
+			meals = append(meals, meal)
+		}
+	}
+	sort.Slice(meals, func(i, j int) bool { return meals[i].Date.Before(meals[j].Date) })
+	return meals, nil
+}
+
+func (s *MemoryStore) GetMealPlanByID(ctx context.Context, id, groupID string) (*models.MealPlan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	meal, ok := s.mealPlans[id]
+	if !ok || meal.GroupID != groupID {
+		return nil, nil
+	}
+	return &meal, nil
+}
+
+func (s *MemoryStore) CreateMealPlan(ctx context.Context, meal *models.MealPlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.mealPlans[meal.ID]; exists {
+		return fmt.Errorf("failed to create meal plan: %s already exists", meal.ID)
+	}
+	meal.Date = truncateToDate(meal.Date)
+	meal.ChangeSeq, meal.UpdatedAt = s.nextChange(meal.GroupID)
+	meal.Version = 1
+	s.mealPlans[meal.ID] = *meal
+	return nil
+}
+
+func (s *MemoryStore) UpdateMealPlan(ctx context.Context, id, groupID string, patch models.MealPlanPatch) (*models.MealPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meal, ok := s.mealPlans[id]
+	if !ok || meal.GroupID != groupID {
+		return nil, nil
+	}
+	if !versionMatches(meal.Version, patch.Version) {
+		return nil, ErrVersionConflict
+	}
+
+	patch.Apply(&meal)
+	meal.Date = truncateToDate(meal.Date)
+	meal.ChangeSeq, meal.UpdatedAt = s.nextChange(groupID)
+	meal.Version++
+	s.mealPlans[id] = meal
+	return &meal, nil
+}
+
+func (s *MemoryStore) DeleteMealPlan(ctx context.Context, id, groupID string, expectedVersion *int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meal, ok := s.mealPlans[id]
+	if !ok || meal.GroupID != groupID {
+		return ErrMealPlanNotFound
+	}
+	if !versionMatches(meal.Version, expectedVersion) {
+		return ErrVersionConflict
+	}
+	delete(s.mealPlans, id)
+	s.recordDeletion(models.EntityMealPlan, id, groupID)
+	return nil
+}
+
+// Receipts
+
+func (s *MemoryStore) GetAllReceipts(ctx context.Context, groupID string) ([]models.Receipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	receipts := []models.Receipt{}
+	for _, receipt := range s.receipts {
+		if receipt.GroupID == groupID {
+			receipts = append(receipts, cloneReceipt(receipt))
+		}
+	}
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].Date.After(receipts[j].Date) })
+	return receipts, nil
+}
+
+func (s *MemoryStore) GetReceiptByID(ctx context.Context, id, groupID string) (*models.Receipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	receipt, ok := s.receipts[id]
+	if !ok || receipt.GroupID != groupID {
+		return nil, nil
+	}
+	receipt = cloneReceipt(receipt)
+	return &receipt, nil
+}
+
+func (s *MemoryStore) CreateReceipt(ctx context.Context, receipt *models.Receipt) ([]models.GroceryItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.receipts[receipt.ID]; exists {
+		return nil, fmt.Errorf("failed to create receipt: %s already exists", receipt.ID)
+	}
+
+	explicitItemSet := map[string]struct{}{}
+	for _, name := range receipt.ItemsList {
+		explicitItemSet[name] = struct{}{}
+	}
+	linkedIDs := map[string]struct{}{}
+	for _, line := range receipt.LineItems {
+		if line.GroceryItemID != nil {
+			linkedIDs[*line.GroceryItemID] = struct{}{}
+		}
+	}
+
+	updatedItems := []models.GroceryItem{}
+	for id, item := range s.groceryItems {
+		if item.GroupID != receipt.GroupID || !item.IsNeeded || !item.IsShoppingChecked {
+			continue
+		}
+		_, listed := explicitItemSet[item.Name]
+		_, linked := linkedIDs[id]
+		if !listed && !linked {
+			continue
+		}
+		item.IsNeeded = false
+		item.IsShoppingChecked = false
+		item.ChangeSeq, item.UpdatedAt = s.nextChange(item.GroupID)
+		item.Version++
+		s.groceryItems[id] = item
+		updatedItems = append(updatedItems, item)
+		s.purchases = append(s.purchases, models.NewGroceryPurchase(id, *receipt, time.Now()))
+	}
+
+	receipt.Date = truncateToDate(receipt.Date)
+	receipt.ChangeSeq, receipt.UpdatedAt = s.nextChange(receipt.GroupID)
+	receipt.Version = 1
+	s.receipts[receipt.ID] = cloneReceipt(*receipt)
+	return updatedItems, nil
+}
+
+func (s *MemoryStore) UpdateReceipt(ctx context.Context, id, groupID string, patch models.ReceiptPatch) (*models.Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	receipt, ok := s.receipts[id]
+	if !ok || receipt.GroupID != groupID {
+		return nil, nil
+	}
+	if !versionMatches(receipt.Version, patch.Version) {
+		return nil, ErrVersionConflict
+	}
+	receipt = cloneReceipt(receipt)
+
+	if err := patch.Apply(&receipt); err != nil {
+		return nil, err
+	}
+	receipt.Date = truncateToDate(receipt.Date)
+	receipt.ChangeSeq, receipt.UpdatedAt = s.nextChange(groupID)
+	receipt.Version++
+	s.receipts[id] = receipt
+	receipt = cloneReceipt(receipt)
+	return &receipt, nil
+}
+
+func (s *MemoryStore) DeleteReceipt(ctx context.Context, id, groupID string, expectedVersion *int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	receipt, ok := s.receipts[id]
+	if !ok || receipt.GroupID != groupID {
+		return ErrReceiptNotFound
+	}
+	if !versionMatches(receipt.Version, expectedVersion) {
+		return ErrVersionConflict
+	}
+	delete(s.receipts, id)
+	s.recordDeletion(models.EntityReceipt, id, groupID)
+	for i, purchase := range s.purchases {
+		if purchase.ReceiptID != nil && *purchase.ReceiptID == id {
+			s.purchases[i].ReceiptID = nil
+		}
+	}
+	return nil
+}
+
+// Purchases
+
+func (s *MemoryStore) GetGroceryPurchases(ctx context.Context, groupID string, since time.Time) ([]models.GroceryPurchase, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	purchases := []models.GroceryPurchase{}
+	for _, purchase := range s.purchases {
+		if purchase.GroupID == groupID && !purchase.PurchasedAt.Before(since) {
+			purchases = append(purchases, purchase)
+		}
+	}
+	// Receipts entered late record purchases out of order
+	slices.SortStableFunc(purchases, func(a, b models.GroceryPurchase) int { return a.PurchasedAt.Compare(b.PurchasedAt) })
+	return purchases, nil
+}
+
+// Settlements
+
+func (s *MemoryStore) GetAllSettlements(ctx context.Context, groupID string) ([]models.Settlement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settlements := []models.Settlement{}
+	for _, settlement := range s.settlements {
+		if settlement.GroupID == groupID {
+			settlements = append(settlements, cloneSettlement(settlement))
+		}
+	}
+	sort.Slice(settlements, func(i, j int) bool { return settlements[i].Date.After(settlements[j].Date) })
+	return settlements, nil
+}
+
+func (s *MemoryStore) GetSettlementByID(ctx context.Context, id, groupID string) (*models.Settlement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settlement, ok := s.settlements[id]
+	if !ok || settlement.GroupID != groupID {
+		return nil, nil
+	}
+	settlement = cloneSettlement(settlement)
+	return &settlement, nil
+}
+
+func (s *MemoryStore) CreateSettlement(ctx context.Context, settlement *models.Settlement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.settlements[settlement.ID]; exists {
+		return fmt.Errorf("failed to create settlement: %s already exists", settlement.ID)
+	}
+	settlement.Date = truncateToDate(settlement.Date)
+	settlement.ChangeSeq, settlement.UpdatedAt = s.nextChange(settlement.GroupID)
+	settlement.Version = 1
+	s.settlements[settlement.ID] = cloneSettlement(*settlement)
+	return nil
+}
+
+func (s *MemoryStore) UpdateSettlement(ctx context.Context, id, groupID string, patch models.SettlementPatch) (*models.Settlement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settlement, ok := s.settlements[id]
+	if !ok || settlement.GroupID != groupID {
+		return nil, nil
+	}
+	if !versionMatches(settlement.Version, patch.Version) {
+		return nil, ErrVersionConflict
+	}
+	settlement = cloneSettlement(settlement)
+
+	if err := patch.Apply(&settlement); err != nil {
+		return nil, err
+	}
+	settlement.Date = truncateToDate(settlement.Date)
+	settlement.ChangeSeq, settlement.UpdatedAt = s.nextChange(groupID)
+	settlement.Version++
+	s.settlements[id] = settlement
+	settlement = cloneSettlement(settlement)
+	return &settlement, nil
+}
+
+func (s *MemoryStore) DeleteSettlement(ctx context.Context, id, groupID string, expectedVersion *int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settlement, ok := s.settlements[id]
+	if !ok || settlement.GroupID != groupID {
+		return ErrSettlementNotFound
+	}
+	if !versionMatches(settlement.Version, expectedVersion) {
+		return ErrVersionConflict
+	}
+	delete(s.settlements, id)
+	s.recordDeletion(models.EntitySettlement, id, groupID)
+	return nil
+}
+
+// Categories
+
+func (s *MemoryStore) GetCategories(ctx context.Context, groupID string) ([]models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.groupCategories(groupID), nil
+}
+
+func (s *MemoryStore) GetCategoryByID(ctx context.Context, id, groupID string) (*models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	category, ok := s.categories[id]
+	if !ok || category.GroupID != groupID {
+		return nil, nil
+	}
+	category = cloneCategory(category)
+	return &category, nil
+}
+
+func (s *MemoryStore) CreateCategory(ctx context.Context, category *models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[category.GroupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	if _, taken := s.loadGroup(group).CanonicalCategory(category.Name); taken {
+		return models.FieldErrors{"name": "is already one of the group's categories"}
+	}
+	s.insertCategory(category)
+	s.bumpGroupVersion(category.GroupID)
+	return nil
+}
+
+func (s *MemoryStore) UpdateCategory(ctx context.Context, id, groupID string, patch models.CategoryPatch) (*models.Category, []models.GroceryItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, ok := s.categories[id]
+	if !ok || category.GroupID != groupID {
+		return nil, nil, nil
+	}
+	if !versionMatches(category.Version, patch.Version) {
+		return nil, nil, ErrVersionConflict
+	}
+	if patch.Name != nil {
+		group := s.loadGroup(s.groups[groupID])
+		if existing, taken := group.CanonicalCategory(*patch.Name); taken && existing != category.Name {
+			return nil, nil, models.FieldErrors{"name": "is already one of the group's categories"}
+		}
+	}
+
+	previous := category
+	category = cloneCategory(category)
+	patch.Apply(&category)
+	category.ChangeSeq, category.UpdatedAt = s.nextChange(groupID)
+	category.Version++
+	s.categories[id] = category
+
+	renamedItems := []models.GroceryItem{}
+	if category.Name != previous.Name {
+		for itemID, item := range s.groceryItems {
+			if item.CategoryID != id {
+				continue
+			}
+			item.Category = category.Name
+			item.ChangeSeq, item.UpdatedAt = s.nextChange(groupID)
+			item.Version++
+			s.groceryItems[itemID] = item
+			renamedItems = append(renamedItems, item)
+		}
+	}
+	if category.Name != previous.Name || category.SortOrder != previous.SortOrder {
+		s.bumpGroupVersion(groupID)
+	}
+	category = cloneCategory(category)
+	return &category, renamedItems, nil
+}
+
+func (s *MemoryStore) DeleteCategory(ctx context.Context, id, groupID string, expectedVersion *int64, policy models.CategoryPolicy) ([]models.GroceryItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, ok := s.categories[id]
+	if !ok || category.GroupID != groupID {
+		return nil, ErrCategoryNotFound
+	}
+	if !versionMatches(category.Version, expectedVersion) {
+		return nil, ErrVersionConflict
+	}
+
+	group := s.loadGroup(s.groups[groupID])
+	changes, err := models.DeleteCategoryChanges(group, category.Name, policy, s.usedCategories(groupID))
+	if err != nil {
+		return nil, err
+	}
+	movedItems := s.applyCategoryChanges(groupID, changes)
+	s.bumpGroupVersion(groupID)
+	return movedItems, nil
+}
+
+// Members
+
+func (s *MemoryStore) GetMembers(ctx context.Context, groupID string) ([]models.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.groupMembers(groupID), nil
+}
+
+func (s *MemoryStore) GetMemberByID(ctx context.Context, id, groupID string) (*models.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	member, ok := s.members[id]
+	if !ok || member.GroupID != groupID {
+		return nil, nil
+	}
+	member = cloneMember(member)
+	return &member, nil
+}
+
+func (s *MemoryStore) CreateMember(ctx context.Context, member *models.Member) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[member.GroupID]; !ok {
+		return ErrGroupNotFound
+	}
+	if !member.Archived && models.NameTaken(s.groupMembers(member.GroupID), member.DisplayName, member.ID) {
+		return models.FieldErrors{"displayName": "is already the name of a member of the group"}
+	}
+	s.insertMember(member)
+	if !member.Archived {
+		s.bumpGroupVersion(member.GroupID)
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateMember(ctx context.Context, id, groupID string, patch models.MemberPatch) (*models.Member, []models.Receipt, []models.Settlement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	member, ok := s.members[id]
+	if !ok || member.GroupID != groupID {
+		return nil, nil, nil, nil
+	}
+	if !versionMatches(member.Version, patch.Version) {
+		return nil, nil, nil, ErrVersionConflict
+	}
+
+	previous := member
+	member = cloneMember(member)
+	patch.Apply(&member)
+	if !member.Archived && models.NameTaken(s.groupMembers(groupID), member.DisplayName, id) {
+		return nil, nil, nil, models.FieldErrors{"displayName": "is already the name of a member of the group"}
+	}
+	member.ChangeSeq, member.UpdatedAt = s.nextChange(groupID)
+	member.Version++
+	s.members[id] = member
+
+	receipts, settlements := []models.Receipt{}, []models.Settlement{}
+	if member.DisplayName != previous.DisplayName {
+		receipts, settlements = s.renameMemberHistory(member)
+	}
+	if member.ChangesMembersList(previous) {
+		s.bumpGroupVersion(groupID)
+	}
+	member = cloneMember(member)
+	return &member, receipts, settlements, nil
+}
+
+// groupMembers returns the group's members in order. Callers must hold the
+// lock.
+func (s *MemoryStore) groupMembers(groupID string) []models.Member {
+	members := []models.Member{}
+	for _, member := range s.members {
+		if member.GroupID == groupID {
+			members = append(members, cloneMember(member))
+		}
+	}
+	models.SortMembers(members)
+	return members
+}
+
+// applyMemberChanges brings the group's members in line with a members list
+// sent by an older client. Callers must hold the write lock.
+func (s *MemoryStore) applyMemberChanges(groupID string, names []string) {
+	created, updated := models.PlanMemberChanges(s.groupMembers(groupID), names, groupID)
+	for _, member := range created {
+		s.insertMember(&member)
+	}
+	for _, member := range updated {
+		previous := s.members[member.ID]
+		member.ChangeSeq, member.UpdatedAt = s.nextChange(groupID)
+		member.Version++
+		s.members[member.ID] = member
+		if member.DisplayName != previous.DisplayName {
+			s.renameMemberHistory(member)
+		}
+	}
+}
+
+// renameMemberHistory updates the member's name on the receipts and
+// settlements that reference it, like reading it through the member does in
+// Postgres, and returns them. Callers must hold the write lock.
+func (s *MemoryStore) renameMemberHistory(member models.Member) ([]models.Receipt, []models.Settlement) {
+	receipts := []models.Receipt{}
+	for id, receipt := range s.receipts {
+		references := receipt.PurchasedByID == member.ID
+		receipt = cloneReceipt(receipt)
+		if receipt.PurchasedByID == member.ID {
+			receipt.PurchasedBy = member.DisplayName
+		}
+		for i, split := range receipt.Splits {
+			if split.MemberID == member.ID {
+				receipt.Splits[i].Member = member.DisplayName
+				references = true
+			}
+		}
+		if !references {
+			continue
+		}
+		receipt.ChangeSeq, receipt.UpdatedAt = s.nextChange(member.GroupID)
+		receipt.Version++
+		s.receipts[id] = receipt
+		receipts = append(receipts, cloneReceipt(receipt))
+	}
+
+	settlements := []models.Settlement{}
+	for id, settlement := range s.settlements {
+		if settlement.PaidByID != member.ID && settlement.PaidToID != member.ID {
+			continue
+		}
+		if settlement.PaidByID == member.ID {
+			settlement.PaidBy = member.DisplayName
+		} else {
+			settlement.PaidTo = member.DisplayName
+		}
+		settlement.ChangeSeq, settlement.UpdatedAt = s.nextChange(member.GroupID)
+		settlement.Version++
+		s.settlements[id] = settlement
+		settlements = append(settlements, cloneSettlement(settlement))
+	}
+	return receipts, settlements
+}
+
+// insertMember stores a new member. Callers must hold the write lock.
+func (s *MemoryStore) insertMember(member *models.Member) {
+	member.ChangeSeq, member.UpdatedAt = s.nextChange(member.GroupID)
+	member.Version = 1
+	s.members[member.ID] = cloneMember(*member)
+}
+
+// Sync
+
+func (s *MemoryStore) GetChangesSince(ctx context.Context, groupID string, since int64) (*models.ChangeSet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.groups[groupID]; !ok {
+		return nil, nil
+	}
+
+	changes := &models.ChangeSet{
+		GroceryItems: []models.GroceryItem{},
+		MealPlans:    []models.MealPlan{},
+		Receipts:     []models.Receipt{},
+		Settlements:  []models.Settlement{},
+		Categories:   []models.Category{},
+		Members:      []models.Member{},
+		Deleted:      []models.Tombstone{},
+		Cursor:       s.changeSeqs[groupID],
+	}
+	if since > changes.Cursor {
+		changes.Reset = true
+		since = 0
+	}
+
+	for _, item := range s.groceryItems {
+		if item.GroupID == groupID && item.ChangeSeq > since {
+			changes.GroceryItems = append(changes.GroceryItems, item)
+		}
+	}
+	for _, meal := range s.mealPlans {
+		if meal.GroupID == groupID && meal.ChangeSeq > since {
+			changes.MealPlans = append(changes.MealPlans, meal)
+		}
+	}
+	for _, receipt := range s.receipts {
+		if receipt.GroupID == groupID && receipt.ChangeSeq > since {
+			changes.Receipts = append(changes.Receipts, cloneReceipt(receipt))
+		}
+	}
+	for _, settlement := range s.settlements {
+		if settlement.GroupID == groupID && settlement.ChangeSeq > since {
+			changes.Settlements = append(changes.Settlements, cloneSettlement(settlement))
+		}
+	}
+	for _, category := range s.categories {
+		if category.GroupID == groupID && category.ChangeSeq > since {
+			changes.Categories = append(changes.Categories, cloneCategory(category))
+		}
+	}
+	for _, member := range s.members {
+		if member.GroupID == groupID && member.ChangeSeq > since {
+			changes.Members = append(changes.Members, cloneMember(member))
+		}
+	}
+	if since > 0 {
+		for _, tombstone := range s.tombstones {
+			if tombstone.GroupID == groupID && tombstone.ChangeSeq > since {
+				changes.Deleted = append(changes.Deleted, tombstone.Tombstone)
+			}
+		}
+	}
+
+	sort.Slice(changes.GroceryItems, func(i, j int) bool { return changes.GroceryItems[i].ChangeSeq < changes.GroceryItems[j].ChangeSeq })
+	sort.Slice(changes.MealPlans, func(i, j int) bool { return changes.MealPlans[i].ChangeSeq < changes.MealPlans[j].ChangeSeq })
+	sort.Slice(changes.Receipts, func(i, j int) bool { return changes.Receipts[i].ChangeSeq < changes.Receipts[j].ChangeSeq })
+	sort.Slice(changes.Settlements, func(i, j int) bool { return changes.Settlements[i].ChangeSeq < changes.Settlements[j].ChangeSeq })
+	sort.Slice(changes.Categories, func(i, j int) bool { return changes.Categories[i].ChangeSeq < changes.Categories[j].ChangeSeq })
+	sort.Slice(changes.Members, func(i, j int) bool { return changes.Members[i].ChangeSeq < changes.Members[j].ChangeSeq })
+	sort.Slice(changes.Deleted, func(i, j int) bool { return changes.Deleted[i].ChangeSeq < changes.Deleted[j].ChangeSeq })
+	return changes, nil
+}
+
+// nextChange draws the group's next change sequence number, mirroring the
+// track_row_change trigger in Postgres. Callers must hold the write lock.
+func (s *MemoryStore) nextChange(groupID string) (int64, time.Time) {
+	s.changeSeqs[groupID]++
+	return s.changeSeqs[groupID], time.Now().UTC()
+}
+
+// recordDeletion leaves a tombstone for a deleted entity, mirroring the
+// track_row_delete trigger in Postgres. Callers must hold the write lock.
+func (s *MemoryStore) recordDeletion(entityType, id, groupID string) {
+	seq, now := s.nextChange(groupID)
+	s.tombstones[entityType+":"+id] = memoryTombstone{
+		Tombstone: models.Tombstone{EntityType: entityType, ID: id, ChangeSeq: seq, DeletedAt: now},
+		GroupID:   groupID,
+	}
+}
+
+// Reports
+
+func (s *MemoryStore) GetSpendingTotals(ctx context.Context, groupID string, from, to *time.Time) ([]models.SpendingTotal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type totalKey struct {
+		date                    time.Time
+		purchasedByID, currency string
+	}
+	byKey := map[totalKey]*models.SpendingTotal{}
+	for _, receipt := range s.receipts {
+		if receipt.GroupID != groupID {
+			continue
+		}
+		if (from != nil && receipt.Date.Before(truncateToDate(*from))) || (to != nil && receipt.Date.After(truncateToDate(*to))) {
+			continue
+		}
+		key := totalKey{receipt.Date, receipt.PurchasedByID, receipt.Currency}
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = &models.SpendingTotal{Date: receipt.Date, PurchasedByID: receipt.PurchasedByID, Currency: receipt.Currency}
+		}
+		byKey[key].Count++
+		byKey[key].Total += receipt.TotalAmount
+	}
+
+	totals := make([]models.SpendingTotal, 0, len(byKey))
+	for _, total := range byKey {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Date.Before(totals[j].Date) })
+	return totals, nil
+}
+
+// Groups
+
+func (s *MemoryStore) CreateGroup(ctx context.Context, group *models.Group, admin *models.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.groups[group.ID]; exists {
+		return fmt.Errorf("group %s already exists", group.ID)
+	}
+	if err := s.insertAccessToken(admin); err != nil {
+		return err
+	}
+	for i, name := range group.Categories {
+		category := models.NewCategory(name, i, group.ID)
+		s.insertCategory(category)
+	}
+	for i, name := range group.Members {
+		s.insertMember(models.NewMember(name, i, group.ID))
+	}
+	group.Version = 1
+	stored := cloneGroup(*group)
+	stored.Categories, stored.Members = nil, nil
+	s.groups[group.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetGroupByID(ctx context.Context, id string) (*models.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	group, ok := s.groups[id]
+	if !ok {
+		return nil, nil
+	}
+	group = s.loadGroup(group)
+	return &group, nil
+}
+
+func (s *MemoryStore) UpdateGroup(ctx context.Context, id string, patch models.GroupPatch) (*models.Group, []models.GroceryItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[id]
+	if !ok {
+		return nil, nil, nil
+	}
+	if !versionMatches(group.Version, patch.Version) {
+		return nil, nil, ErrVersionConflict
+	}
+	group = cloneGroup(group)
+
+	movedItems := []models.GroceryItem{}
+	if patch.ChangesCategories() {
+		changes, err := patch.Recategorize(s.loadGroup(group), s.usedCategories(id))
+		if err != nil {
+			return nil, nil, err
+		}
+		movedItems = s.applyCategoryChanges(id, changes)
+	}
+	if patch.Members != nil {
+		s.applyMemberChanges(id, *patch.Members)
+	}
+
+	patch.Apply(&group)
+	group.Version++
+	s.groups[id] = group
+	group = s.loadGroup(group)
+	return &group, movedItems, nil
+}
+
+// loadGroup returns a copy of a stored group with its categories and members
+// filled in, like the subqueries in Postgres. Callers must hold the lock.
+func (s *MemoryStore) loadGroup(group models.Group) models.Group {
+	group = cloneGroup(group)
+	group.Categories = models.CategoryNames(s.groupCategories(group.ID))
+	group.Members = models.MemberNames(models.ActiveMembers(s.groupMembers(group.ID)))
+	return group
+}
+
+// groupCategories returns the group's categories in order. Callers must hold
+// the lock.
+func (s *MemoryStore) groupCategories(groupID string) []models.Category {
+	categories := []models.Category{}
+	for _, category := range s.categories {
+		if category.GroupID == groupID {
+			categories = append(categories, cloneCategory(category))
+		}
+	}
+	models.SortCategories(categories)
+	return categories
+}
+
+// usedCategories returns the names of the categories the group's grocery
+// items are in. Callers must hold the lock.
+func (s *MemoryStore) usedCategories(groupID string) []string {
+	var used []string
+	for _, item := range s.groceryItems {
+		if item.GroupID == groupID && !slices.Contains(used, item.Category) {
+			used = append(used, item.Category)
+		}
+	}
+	return used
+}
+
+// applyCategoryChanges stores the group's new categories and moves grocery
+// items out of deleted ones. It returns the items that were moved or whose
+// category was renamed. Callers must hold the write lock.
+func (s *MemoryStore) applyCategoryChanges(groupID string, changes *models.CategoryChanges) []models.GroceryItem {
+	plan := models.PlanCategoryChanges(s.groupCategories(groupID), changes, groupID)
+
+	for _, category := range plan.Created {
+		s.insertCategory(&category)
+	}
+	for _, updated := range plan.Updated {
+		category := s.categories[updated.ID]
+		category.Name, category.SortOrder = updated.Name, updated.SortOrder
+		category.ChangeSeq, category.UpdatedAt = s.nextChange(groupID)
+		category.Version++
+		s.categories[category.ID] = category
+	}
+
+	changedItems := []models.GroceryItem{}
+	for itemID, item := range s.groceryItems {
+		if item.GroupID != groupID {
+			continue
+		}
+		to, moved := plan.Moved[item.CategoryID]
+		if moved {
+			item.CategoryID = to
+		} else if !slices.Contains(plan.Renamed, item.CategoryID) {
+			continue
+		}
+		item.Category = s.categories[item.CategoryID].Name
+		item.ChangeSeq, item.UpdatedAt = s.nextChange(groupID)
+		item.Version++
+		s.groceryItems[itemID] = item
+		changedItems = append(changedItems, item)
+	}
+
+	for _, id := range plan.Deleted {
+		delete(s.categories, id)
+		s.recordDeletion(models.EntityCategory, id, groupID)
+	}
+	return changedItems
+}
+
+// insertCategory stores a new category. Callers must hold the write lock.
+func (s *MemoryStore) insertCategory(category *models.Category) {
+	category.ChangeSeq, category.UpdatedAt = s.nextChange(category.GroupID)
+	category.Version = 1
+	s.categories[category.ID] = cloneCategory(*category)
+}
+
+// bumpGroupVersion gives the group a new version after one of its categories
+// or members changed. Callers must hold the write lock.
+func (s *MemoryStore) bumpGroupVersion(groupID string) {
+	group := s.groups[groupID]
+	group.Version++
+	s.groups[groupID] = group
+}
+
+func (s *MemoryStore) DeleteGroup(ctx context.Context, groupID string, expectedVersion *int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[groupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	if !versionMatches(group.Version, expectedVersion) {
+		return ErrVersionConflict
+	}
+
+	for id, item := range s.groceryItems {
+		if item.GroupID == groupID {
+			delete(s.groceryItems, id)
+		}
+	}
+	s.purchases = slices.DeleteFunc(s.purchases, func(purchase models.GroceryPurchase) bool {
+		return purchase.GroupID == groupID
+	})
+	for id, meal := range s.mealPlans {
+		if meal.GroupID == groupID {
+			delete(s.mealPlans, id)
+		}
+	}
+	for id, receipt := range s.receipts {
+		if receipt.GroupID == groupID {
+			delete(s.receipts, id)
+		}
+	}
+	for id, settlement := range s.settlements {
+		if settlement.GroupID == groupID {
+			delete(s.settlements, id)
+		}
+	}
+	for key, tombstone := range s.tombstones {
+		if tombstone.GroupID == groupID {
+			delete(s.tombstones, key)
+		}
+	}
+	for key := range s.idempotency {
+		if key.groupID == groupID {
+			delete(s.idempotency, key)
+		}
+	}
+	for id, token := range s.accessTokens {
+		if token.GroupID == groupID {
+			delete(s.accessTokens, id)
+		}
+	}
+	for code, invite := range s.invites {
+		if invite.GroupID == groupID {
+			delete(s.invites, code)
+		}
+	}
+	for id, category := range s.categories {
+		if category.GroupID == groupID {
+			delete(s.categories, id)
+		}
+	}
+	for id, member := range s.members {
+		if member.GroupID == groupID {
+			delete(s.members, id)
+		}
+	}
+	delete(s.changeSeqs, groupID)
+	delete(s.groups, groupID)
+	return nil
+}
+
+func (s *MemoryStore) GetGroupsFromID(ctx context.Context, id string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := slices.Clone(s.userGroups[id])
+	if groups == nil {
+		groups = []string{}
+	}
+	slices.Sort(groups)
+	return groups, nil
+}
+
+func (s *MemoryStore) GetLegacyMembers(ctx context.Context, groupID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []string{}
+	for userID, groups := range s.userGroups {
+		if slices.Contains(groups, groupID) {
+			users = append(users, userID)
+		}
+	}
+	slices.Sort(users)
+	return users, nil
+}
+
+// Idempotency keys
+
+func (s *MemoryStore) ReserveIdempotencyKey(ctx context.Context, groupID, key, requestHash string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{groupID, key}
+	if existing, ok := s.idempotency[k]; ok && time.Since(existing.CreatedAt) < IdempotencyKeyTTL {
+		record := existing.IdempotencyRecord
+		record.Response = slices.Clone(record.Response)
+		return &record, nil
+	}
+	s.idempotency[k] = memoryIdempotencyRecord{
+		IdempotencyRecord: IdempotencyRecord{RequestHash: requestHash},
+		CreatedAt:         time.Now(),
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) CompleteIdempotencyKey(ctx context.Context, groupID, key string, statusCode int, response []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{groupID, key}
+	record, ok := s.idempotency[k]
+	if !ok {
+		return nil
+	}
+	record.StatusCode = statusCode
+	record.Response = slices.Clone(response)
+	s.idempotency[k] = record
+	return nil
+}
+
+func (s *MemoryStore) ReleaseIdempotencyKey(ctx context.Context, groupID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.idempotency, idempotencyKey{groupID, key})
+	return nil
+}
+
+// Access tokens and invites
+
+func (s *MemoryStore) GetAccessToken(ctx context.Context, groupID, tokenHash string) (*models.AccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.accessTokens {
+		if token.GroupID == groupID && token.TokenHash == tokenHash && token.RevokedAt == nil {
+			return &token, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) GetAccessTokens(ctx context.Context, groupID string) ([]models.AccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := []models.AccessToken{}
+	for _, token := range s.accessTokens {
+		if token.GroupID == groupID && token.RevokedAt == nil {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+func (s *MemoryStore) CreateAccessToken(ctx context.Context, token *models.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.insertAccessToken(token)
+}
+
+// insertAccessToken stores a new token. Callers must hold the write lock.
+func (s *MemoryStore) insertAccessToken(token *models.AccessToken) error {
+	if _, exists := s.accessTokens[token.ID]; exists {
+		return fmt.Errorf("failed to create access token: %s already exists", token.ID)
+	}
+	token.CreatedAt = time.Now().UTC()
+	s.accessTokens[token.ID] = *token
+	return nil
+}
+
+func (s *MemoryStore) RevokeAccessToken(ctx context.Context, groupID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.accessTokens[id]
+	if !ok || token.GroupID != groupID || token.RevokedAt != nil {
+		return ErrAccessTokenNotFound
+	}
+	now := time.Now().UTC()
+	token.RevokedAt = &now
+	s.accessTokens[id] = token
+	return nil
+}
+
+func (s *MemoryStore) ClaimGroup(ctx context.Context, token *models.AccessToken, claimCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[token.GroupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	if group.RequiresToken {
+		return ErrGroupAlreadyClaimed
+	}
+	invite, ok := s.invites[claimCode]
+	if claimCode != "" && (!ok || invite.GroupID != token.GroupID || !invite.Usable(time.Now())) {
+		return ErrInviteInvalid
+	}
+	if err := s.insertAccessToken(token); err != nil {
+		return err
+	}
+	if claimCode != "" {
+		invite.Uses++
+		s.invites[claimCode] = invite
+	}
+	group.RequiresToken = true
+	s.groups[token.GroupID] = group
+	return nil
+}
+
+func (s *MemoryStore) RotateGroupAccess(ctx context.Context, replacement *models.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for id, token := range s.accessTokens {
+		if token.GroupID == replacement.GroupID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.accessTokens[id] = token
+		}
+	}
+	for code, invite := range s.invites {
+		if invite.GroupID == replacement.GroupID && invite.RevokedAt == nil {
+			invite.RevokedAt = &now
+			s.invites[code] = invite
+		}
+	}
+	return s.insertAccessToken(replacement)
+}
+
+func (s *MemoryStore) GetInvites(ctx context.Context, groupID string) ([]models.Invite, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	invites := []models.Invite{}
+	for _, invite := range s.invites {
+		if invite.GroupID == groupID && invite.Usable(now) {
+			invites = append(invites, invite)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.Before(invites[j].CreatedAt) })
+	return invites, nil
+}
+
+func (s *MemoryStore) CreateInvite(ctx context.Context, invite *models.Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.invites[invite.Code]; exists {
+		return fmt.Errorf("failed to create invite: %s already exists", invite.Code)
+	}
+	s.invites[invite.Code] = *invite
+	return nil
+}
+
+func (s *MemoryStore) RevokeInvite(ctx context.Context, groupID, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite, ok := s.invites[code]
+	if !ok || invite.GroupID != groupID || invite.RevokedAt != nil {
+		return ErrInviteNotFound
+	}
+	now := time.Now().UTC()
+	invite.RevokedAt = &now
+	s.invites[code] = invite
+	return nil
+}
+
+func (s *MemoryStore) RedeemInvite(ctx context.Context, code string, token *models.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite, ok := s.invites[code]
+	if !ok || !invite.Usable(time.Now()) || !s.groups[invite.GroupID].RequiresToken {
+		return ErrInviteInvalid
+	}
+	token.GroupID = invite.GroupID
+	if err := s.insertAccessToken(token); err != nil {
+		return err
+	}
+	invite.Uses++
+	s.invites[code] = invite
+	return nil
+}
+
+// unlinkLineItems clears line item links to a deleted grocery item, like the
+// ON DELETE SET NULL foreign key in Postgres. Callers must hold the write lock.
+func (s *MemoryStore) unlinkLineItems(groceryItemID string) {
+	for id, receipt := range s.receipts {
+		for i, line := range receipt.LineItems {
+			if line.GroceryItemID != nil && *line.GroceryItemID == groceryItemID {
+				receipt = cloneReceipt(receipt)
+				receipt.LineItems[i].GroceryItemID = nil
+				s.receipts[id] = receipt
+			}
+		}
+	}
+}
+
+// versionMatches reports whether a row at version satisfies an optional
+// expected version.
+func versionMatches(version int64, expected *int64) bool {
+	return expected == nil || *expected == version
+}
+
+func cloneReceipt(receipt models.Receipt) models.Receipt {
+	receipt.ItemsList = slices.Clone(receipt.ItemsList)
+	receipt.LineItems = slices.Clone(receipt.LineItems)
+	receipt.Splits = slices.Clone(receipt.Splits)
+	if receipt.Notes != nil {
+		notes := *receipt.Notes
+		receipt.Notes = &notes
+	}
+	return receipt
+}
+
+func cloneSettlement(settlement models.Settlement) models.Settlement {
+	if settlement.Notes != nil {
+		notes := *settlement.Notes
+		settlement.Notes = &notes
+	}
+	return settlement
+}
+
+func cloneCategory(category models.Category) models.Category {
+	if category.Color != nil {
+		color := *category.Color
+		category.Color = &color
+	}
+	if category.Icon != nil {
+		icon := *category.Icon
+		category.Icon = &icon
+	}
+	return category
+}
+
+func cloneMember(member models.Member) models.Member {
+	if member.Color != nil {
+		color := *member.Color
+		member.Color = &color
+	}
+	return member
+}
+
+func cloneGroup(group models.Group) models.Group {
+	group.Categories = slices.Clone(group.Categories)
+	group.Members = slices.Clone(group.Members)
+	return group
+}
+
+// truncateToDate mirrors how Postgres stores values in DATE columns.
+func truncateToDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,338 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the key for the advisory lock held while migrating, so
+// that replicas starting at the same time don't apply the same migration twice.
+const migrationLockID = 7_265_425_012
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	checksum   TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// Migration is a single versioned schema change, loaded from the embedded
+// migrations directory. Files are named <version>_<name>.up.sql and
+// <version>_<name>.down.sql; the down file is optional.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum returns the hex encoded SHA-256 of the up script.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationState describes a migration known to the binary, the database, or both.
+type MigrationState struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set when the applied checksum no longer matches the embedded file
+	Modified bool
+	// Unknown is set when the database has a migration this binary doesn't ship
+	Unknown bool
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// LoadMigrations returns the embedded migrations ordered by version.
+func LoadMigrations() ([]Migration, error) {
+	return loadMigrations(migrationFiles)
+}
+
+// loadMigrations reads the migrations directory of fsys.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %q", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %q must be named <version>_<name>.%s.sql", fileName, direction)
+		}
+		version, err := strconv.ParseInt(versionPart, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %q has an invalid version", fileName)
+		}
+
+		contents, err := fs.ReadFile(fsys, path.Join("migrations", fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", fileName, err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d used by both %q and %q", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration in order, each in its own
+// transaction. It refuses to run if an applied migration has been edited.
+func MigrateUp(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withMigrationLock(ctx, pool, func(conn *pgx.Conn) error {
+		applied, err := loadAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := verifyChecksums(migrations, applied); err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx,
+					`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+					m.Version, m.Name, m.Checksum(),
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
+			}
+			log.Printf("Applied migration %d (%s)", m.Version, m.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// MigrateDown reverts the given number of most recently applied migrations.
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, steps int) (int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	count := 0
+	err = withMigrationLock(ctx, pool, func(conn *pgx.Conn) error {
+		applied, err := loadAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := verifyChecksums(migrations, applied); err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if count >= steps {
+				break
+			}
+			m, ok := byVersion[version]
+			if !ok {
+				return fmt.Errorf("cannot revert migration %d (%s): not known to this binary", version, applied[version].name)
+			}
+			if m.Down == "" {
+				return fmt.Errorf("cannot revert migration %d (%s): no down script", m.Version, m.Name)
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %d (%s): %w", m.Version, m.Name, err)
+			}
+			log.Printf("Reverted migration %d (%s)", m.Version, m.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// GetMigrationStatus lists every migration known to the binary or recorded in
+// the database, ordered by version.
+func GetMigrationStatus(ctx context.Context, pool *pgxpool.Pool) ([]MigrationState, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var states []MigrationState
+	err = withMigrationLock(ctx, pool, func(conn *pgx.Conn) error {
+		applied, err := loadAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		known := map[int64]bool{}
+		for _, m := range migrations {
+			known[m.Version] = true
+			state := MigrationState{Version: m.Version, Name: m.Name}
+			if a, ok := applied[m.Version]; ok {
+				appliedAt := a.appliedAt
+				state.Applied = true
+				state.AppliedAt = &appliedAt
+				state.Modified = a.checksum != m.Checksum()
+			}
+			states = append(states, state)
+		}
+		for version, a := range applied {
+			if known[version] {
+				continue
+			}
+			appliedAt := a.appliedAt
+			states = append(states, MigrationState{
+				Version:   version,
+				Name:      a.name,
+				Applied:   true,
+				AppliedAt: &appliedAt,
+				Unknown:   true,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// withMigrationLock runs fn on a single connection holding the migration
+// advisory lock, creating the tracking table first if needed.
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgx.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, int64(migrationLockID)); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, int64(migrationLockID))
+
+	if _, err := conn.Exec(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn.Conn())
+}
+
+func loadAppliedMigrations(ctx context.Context, conn *pgx.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.Query(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]appliedMigration{}
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations row: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+func verifyChecksums(migrations []Migration, applied map[int64]appliedMigration) error {
+	for _, m := range migrations {
+		a, ok := applied[m.Version]
+		if !ok {
+			continue
+		}
+		if a.checksum != m.Checksum() {
+			return fmt.Errorf("migration %d (%s) was modified after it was applied (checksum %s, expected %s)",
+				m.Version, m.Name, m.Checksum(), a.checksum)
+		}
+	}
+	return nil
+}
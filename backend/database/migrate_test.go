@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPool connects to the scratch database in TEST_DATABASE_URL, or skips
+// the test if there is none. The database is migrated, and its contents may
+// be thrown away.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	if _, err := MigrateUp(context.Background(), pool); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d is numbered %d, want the versions without gaps", i+1, m.Version)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d (%s) has no down script", m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"migrations/0002_second.up.sql":   {Data: []byte("SELECT 2")},
+		"migrations/0001_first.up.sql":    {Data: []byte("SELECT 1")},
+		"migrations/0001_first.down.sql":  {Data: []byte("SELECT -1")},
+		"migrations/0010_tenth.up.sql":    {Data: []byte("SELECT 10")},
+		"migrations/0010_tenth.down.sql":  {Data: []byte("SELECT -10")},
+		"migrations/0002_second.down.sql": {Data: []byte("SELECT -2")},
+	}
+	migrations, err := loadMigrations(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "first", Up: "SELECT 1", Down: "SELECT -1"},
+		{Version: 2, Name: "second", Up: "SELECT 2", Down: "SELECT -2"},
+		{Version: 10, Name: "tenth", Up: "SELECT 10", Down: "SELECT -10"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("loaded %+v, want %+v", migrations, want)
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestLoadMigrationsRejectsBadFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"unknown suffix": {"migrations/0001_first.sql": {}},
+		"no name":        {"migrations/0001.up.sql": {}},
+		"bad version":    {"migrations/first_table.up.sql": {}},
+		"zero version":   {"migrations/0000_first.up.sql": {}},
+		"no up script":   {"migrations/0001_first.down.sql": {}},
+		"shared version": {
+			"migrations/0001_first.up.sql": {},
+			"migrations/0001_other.up.sql": {},
+		},
+	}
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			if migrations, err := loadMigrations(files); err == nil {
+				t.Errorf("loaded %+v, want an error", migrations)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	migrations := []Migration{{Version: 1, Name: "first", Up: "SELECT 1"}, {Version: 2, Name: "second", Up: "SELECT 2"}}
+
+	applied := map[int64]appliedMigration{1: {name: "first", checksum: migrations[0].Checksum()}}
+	if err := verifyChecksums(migrations, applied); err != nil {
+		t.Errorf("an unchanged migration failed: %v", err)
+	}
+
+	migrations[0].Up = "SELECT 1 + 0"
+	if err := verifyChecksums(migrations, applied); err == nil {
+		t.Error("a migration edited after it was applied passed")
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every down script has to undo its up script for the way back up to work
+	reverted, err := MigrateDown(ctx, pool, len(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted != len(migrations) {
+		t.Errorf("reverted %d migrations, want %d", reverted, len(migrations))
+	}
+	applied, err := MigrateUp(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", applied, len(migrations))
+	}
+
+	states, err := GetMigrationStatus(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range states {
+		if !state.Applied || state.Modified || state.Unknown {
+			t.Errorf("migration %d (%s) is %+v, want applied", state.Version, state.Name, state)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS user_groups;
+DROP TABLE IF EXISTS receipts;
+DROP TABLE IF EXISTS meal_plans;
+DROP TABLE IF EXISTS grocery_items;
+DROP TABLE IF EXISTS groups;
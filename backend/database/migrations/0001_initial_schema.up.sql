@@ -0,0 +1,49 @@
+-- Baseline schema. Uses IF NOT EXISTS so databases created before migrations
+-- existed are adopted as-is instead of failing on the first run.
+
+CREATE TABLE IF NOT EXISTS groups (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    categories TEXT[] NOT NULL DEFAULT '{}',
+    members    TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE IF NOT EXISTS grocery_items (
+    id                  TEXT PRIMARY KEY,
+    name                TEXT NOT NULL,
+    category            TEXT NOT NULL,
+    is_needed           BOOLEAN NOT NULL DEFAULT TRUE,
+    is_shopping_checked BOOLEAN NOT NULL DEFAULT FALSE,
+    group_id            TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS grocery_items_group_id_idx ON grocery_items (group_id);
+
+CREATE TABLE IF NOT EXISTS meal_plans (
+    id               TEXT PRIMARY KEY,
+    date             DATE NOT NULL,
+    meal_description TEXT NOT NULL,
+    group_id         TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS meal_plans_group_id_idx ON meal_plans (group_id);
+
+CREATE TABLE IF NOT EXISTS receipts (
+    id           TEXT PRIMARY KEY,
+    date         DATE NOT NULL,
+    total_amount NUMERIC(12, 2) NOT NULL,
+    purchased_by TEXT NOT NULL,
+    items        TEXT NOT NULL DEFAULT '[]',
+    notes        TEXT,
+    group_id     TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS receipts_group_id_idx ON receipts (group_id);
+
+-- Legacy memberships from before auth removal (3.0.0). Only read by the
+-- temporary migration endpoint.
+CREATE TABLE IF NOT EXISTS user_groups (
+    user_id  TEXT NOT NULL,
+    group_id TEXT NOT NULL,
+    PRIMARY KEY (user_id, group_id)
+);
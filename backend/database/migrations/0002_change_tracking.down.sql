@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS receipts_track_delete ON receipts;
+DROP TRIGGER IF EXISTS receipts_track_change ON receipts;
+DROP TRIGGER IF EXISTS meal_plans_track_delete ON meal_plans;
+DROP TRIGGER IF EXISTS meal_plans_track_change ON meal_plans;
+DROP TRIGGER IF EXISTS grocery_items_track_delete ON grocery_items;
+DROP TRIGGER IF EXISTS grocery_items_track_change ON grocery_items;
+
+DROP FUNCTION IF EXISTS track_row_delete();
+DROP FUNCTION IF EXISTS track_row_change();
+DROP FUNCTION IF EXISTS next_group_change_seq(TEXT);
+
+DROP TABLE IF EXISTS sync_tombstones;
+
+ALTER TABLE receipts DROP COLUMN change_seq, DROP COLUMN updated_at;
+ALTER TABLE meal_plans DROP COLUMN change_seq, DROP COLUMN updated_at;
+ALTER TABLE grocery_items DROP COLUMN change_seq, DROP COLUMN updated_at;
+ALTER TABLE groups DROP COLUMN last_change_seq;
@@ -0,0 +1,84 @@
+-- Per-group change sequence for delta sync. Every insert or update of a
+-- grocery item, meal plan or receipt draws the next number from its group's
+-- last_change_seq; deletes leave a tombstone with the number instead.
+
+ALTER TABLE groups ADD COLUMN last_change_seq BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE grocery_items
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE meal_plans
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE receipts
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT 0;
+
+-- Existing rows become change 1 so that a sync from cursor 0 includes them.
+UPDATE grocery_items SET change_seq = 1;
+UPDATE meal_plans SET change_seq = 1;
+UPDATE receipts SET change_seq = 1;
+UPDATE groups SET last_change_seq = 1;
+
+CREATE INDEX grocery_items_group_change_seq_idx ON grocery_items (group_id, change_seq);
+CREATE INDEX meal_plans_group_change_seq_idx ON meal_plans (group_id, change_seq);
+CREATE INDEX receipts_group_change_seq_idx ON receipts (group_id, change_seq);
+
+CREATE TABLE sync_tombstones (
+    entity_type TEXT NOT NULL,
+    entity_id   TEXT NOT NULL,
+    group_id    TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    change_seq  BIGINT NOT NULL,
+    deleted_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (entity_type, entity_id)
+);
+
+CREATE INDEX sync_tombstones_group_change_seq_idx ON sync_tombstones (group_id, change_seq);
+
+-- Locks the group row until the calling transaction ends, so sequence numbers
+-- within a group are committed in order. Returns NULL if the group is gone.
+CREATE FUNCTION next_group_change_seq(p_group_id TEXT) RETURNS BIGINT AS $$
+    UPDATE groups SET last_change_seq = last_change_seq + 1
+    WHERE id = p_group_id
+    RETURNING last_change_seq
+$$ LANGUAGE sql;
+
+CREATE FUNCTION track_row_change() RETURNS trigger AS $$
+BEGIN
+    NEW.change_seq := COALESCE(next_group_change_seq(NEW.group_id), 0);
+    NEW.updated_at := now();
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+-- TG_ARGV[0] is the entity type recorded in the tombstone.
+CREATE FUNCTION track_row_delete() RETURNS trigger AS $$
+DECLARE
+    seq BIGINT;
+BEGIN
+    seq := next_group_change_seq(OLD.group_id);
+    -- No group row means the whole group is being deleted; nothing to sync.
+    IF seq IS NOT NULL THEN
+        INSERT INTO sync_tombstones (entity_type, entity_id, group_id, change_seq)
+        VALUES (TG_ARGV[0], OLD.id, OLD.group_id, seq)
+        ON CONFLICT (entity_type, entity_id)
+        DO UPDATE SET change_seq = EXCLUDED.change_seq, deleted_at = now();
+    END IF;
+    RETURN OLD;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER grocery_items_track_change BEFORE INSERT OR UPDATE ON grocery_items
+    FOR EACH ROW EXECUTE FUNCTION track_row_change();
+CREATE TRIGGER grocery_items_track_delete AFTER DELETE ON grocery_items
+    FOR EACH ROW EXECUTE FUNCTION track_row_delete('grocery_item');
+
+CREATE TRIGGER meal_plans_track_change BEFORE INSERT OR UPDATE ON meal_plans
+    FOR EACH ROW EXECUTE FUNCTION track_row_change();
+CREATE TRIGGER meal_plans_track_delete AFTER DELETE ON meal_plans
+    FOR EACH ROW EXECUTE FUNCTION track_row_delete('meal_plan');
+
+CREATE TRIGGER receipts_track_change BEFORE INSERT OR UPDATE ON receipts
+    FOR EACH ROW EXECUTE FUNCTION track_row_change();
+CREATE TRIGGER receipts_track_delete AFTER DELETE ON receipts
+    FOR EACH ROW EXECUTE FUNCTION track_row_delete('receipt');
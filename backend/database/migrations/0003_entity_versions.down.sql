@@ -0,0 +1,12 @@
+CREATE OR REPLACE FUNCTION track_row_change() RETURNS trigger AS $$
+BEGIN
+    NEW.change_seq := COALESCE(next_group_change_seq(NEW.group_id), 0);
+    NEW.updated_at := now();
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE groups DROP COLUMN version;
+ALTER TABLE receipts DROP COLUMN version;
+ALTER TABLE meal_plans DROP COLUMN version;
+ALTER TABLE grocery_items DROP COLUMN version;
@@ -0,0 +1,20 @@
+-- Optimistic concurrency: every entity carries a version that increases on
+-- each update. Clients send it back with If-Match to detect conflicting edits.
+
+ALTER TABLE grocery_items ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE meal_plans ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE receipts ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+-- Group versions are bumped explicitly by UpdateGroup, because the change
+-- tracking triggers also update the groups row.
+ALTER TABLE groups ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION track_row_change() RETURNS trigger AS $$
+BEGIN
+    NEW.change_seq := COALESCE(next_group_change_seq(NEW.group_id), 0);
+    NEW.updated_at := now();
+    IF TG_OP = 'UPDATE' THEN
+        NEW.version := OLD.version + 1;
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
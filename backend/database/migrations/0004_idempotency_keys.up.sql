@@ -0,0 +1,12 @@
+-- Responses to create requests that carried an Idempotency-Key, so a retried
+-- request gets the original response instead of creating a duplicate.
+-- status_code and response stay NULL while the first request is in flight.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    group_id TEXT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER,
+    response BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (group_id, key)
+);
@@ -0,0 +1,24 @@
+-- Itemized receipts. receipts.items keeps the plain list of names for older
+-- clients and is rewritten whenever the line items change.
+CREATE TABLE IF NOT EXISTS receipt_line_items (
+    id TEXT PRIMARY KEY,
+    receipt_id TEXT NOT NULL REFERENCES receipts(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    unit_price NUMERIC(12, 2) CHECK (unit_price >= 0),
+    grocery_item_id TEXT REFERENCES grocery_items(id) ON DELETE SET NULL,
+    category TEXT,
+    UNIQUE (receipt_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_receipt_line_items_grocery_item ON receipt_line_items(grocery_item_id);
+
+-- Existing receipts get an unpriced line item for each name
+INSERT INTO receipt_line_items (id, receipt_id, position, name)
+SELECT gen_random_uuid()::text, r.id, item.position - 1, item.name
+FROM receipts r
+CROSS JOIN LATERAL (SELECT COALESCE(NULLIF(r.items, ''), '[]')::jsonb AS list) parsed
+CROSS JOIN LATERAL jsonb_array_elements_text(
+    CASE WHEN jsonb_typeof(parsed.list) = 'array' THEN parsed.list ELSE '[]'::jsonb END
+) WITH ORDINALITY AS item(name, position);
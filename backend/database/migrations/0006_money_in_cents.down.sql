@@ -0,0 +1,8 @@
+ALTER TABLE receipt_line_items RENAME COLUMN unit_price_cents TO unit_price;
+ALTER TABLE receipt_line_items ALTER COLUMN unit_price TYPE NUMERIC(12, 2) USING unit_price / 100.0;
+
+ALTER TABLE receipts RENAME COLUMN total_cents TO total_amount;
+ALTER TABLE receipts ALTER COLUMN total_amount TYPE NUMERIC(12, 2) USING total_amount / 100.0;
+
+ALTER TABLE receipts DROP COLUMN IF EXISTS currency;
+ALTER TABLE groups DROP COLUMN IF EXISTS currency;
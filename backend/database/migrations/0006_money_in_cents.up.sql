@@ -0,0 +1,16 @@
+-- Store money as integer cents instead of NUMERIC/float values, and record
+-- which currency amounts are in. Groups default to EUR; receipts keep the
+-- currency they were recorded in even if the group's currency changes later.
+
+ALTER TABLE groups ADD COLUMN currency TEXT NOT NULL DEFAULT 'EUR'
+    CHECK (currency ~ '^[A-Z]{3}$');
+
+-- Every group is EUR at this point, so the default is also the right backfill
+ALTER TABLE receipts ADD COLUMN currency TEXT NOT NULL DEFAULT 'EUR'
+    CHECK (currency ~ '^[A-Z]{3}$');
+
+ALTER TABLE receipts ALTER COLUMN total_amount TYPE BIGINT USING round(total_amount * 100)::bigint;
+ALTER TABLE receipts RENAME COLUMN total_amount TO total_cents;
+
+ALTER TABLE receipt_line_items ALTER COLUMN unit_price TYPE BIGINT USING round(unit_price * 100)::bigint;
+ALTER TABLE receipt_line_items RENAME COLUMN unit_price TO unit_price_cents;
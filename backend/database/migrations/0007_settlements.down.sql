@@ -0,0 +1,4 @@
+DELETE FROM sync_tombstones WHERE entity_type = 'settlement';
+DROP TABLE IF EXISTS settlements;
+
+ALTER TABLE receipts DROP COLUMN IF EXISTS splits;
@@ -0,0 +1,28 @@
+-- Expense settlement. Receipts may carry custom split weights (an empty list
+-- splits equally between the group's members), and settle-up payments between
+-- members are recorded as their own entity.
+
+ALTER TABLE receipts ADD COLUMN splits JSONB NOT NULL DEFAULT '[]';
+
+CREATE TABLE settlements (
+    id           TEXT PRIMARY KEY,
+    date         DATE NOT NULL,
+    paid_by      TEXT NOT NULL,
+    paid_to      TEXT NOT NULL,
+    amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+    currency     TEXT NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    notes        TEXT,
+    group_id     TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    change_seq   BIGINT NOT NULL DEFAULT 0,
+    version      BIGINT NOT NULL DEFAULT 1,
+    CHECK (paid_by <> paid_to)
+);
+
+CREATE INDEX settlements_group_id_idx ON settlements (group_id);
+CREATE INDEX settlements_group_change_seq_idx ON settlements (group_id, change_seq);
+
+CREATE TRIGGER settlements_track_change BEFORE INSERT OR UPDATE ON settlements
+    FOR EACH ROW EXECUTE FUNCTION track_row_change();
+CREATE TRIGGER settlements_track_delete AFTER DELETE ON settlements
+    FOR EACH ROW EXECUTE FUNCTION track_row_delete('settlement');
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS group_invites;
+DROP TABLE IF EXISTS group_access_tokens;
+
+ALTER TABLE groups DROP COLUMN IF EXISTS requires_token;
@@ -0,0 +1,31 @@
+-- Access control for groups. Devices hold an access token per group, handed
+-- out when the group is created or in exchange for an invite code. Groups
+-- from before this migration keep accepting a bare group ID until a device
+-- claims them.
+
+ALTER TABLE groups ADD COLUMN requires_token BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE group_access_tokens (
+    id          TEXT PRIMARY KEY,
+    group_id    TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    token_hash  TEXT NOT NULL UNIQUE,
+    device_name TEXT NOT NULL DEFAULT '',
+    is_admin    BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at  TIMESTAMPTZ
+);
+
+CREATE INDEX group_access_tokens_group_id_idx ON group_access_tokens (group_id);
+
+CREATE TABLE group_invites (
+    code       TEXT PRIMARY KEY,
+    group_id   TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    created_by TEXT REFERENCES group_access_tokens (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    max_uses   INTEGER CHECK (max_uses > 0),
+    uses       INTEGER NOT NULL DEFAULT 0,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX group_invites_group_id_idx ON group_invites (group_id);
@@ -0,0 +1,3 @@
+DELETE FROM group_access_tokens WHERE scope <> 'full';
+
+ALTER TABLE group_access_tokens DROP COLUMN IF EXISTS scope;
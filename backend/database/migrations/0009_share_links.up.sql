@@ -0,0 +1,5 @@
+-- Share links are access tokens limited to reading a group, or to reading it
+-- and checking items off the shopping list.
+
+ALTER TABLE group_access_tokens
+    ADD COLUMN scope TEXT NOT NULL DEFAULT 'full' CHECK (scope IN ('full', 'read', 'shopping'));
@@ -0,0 +1,12 @@
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS categories TEXT[] NOT NULL DEFAULT '{}';
+UPDATE groups g SET categories = ARRAY(
+    SELECT c.name FROM group_categories c WHERE c.group_id = g.id ORDER BY c.sort_order, c.name
+);
+
+ALTER TABLE grocery_items ADD COLUMN IF NOT EXISTS category TEXT;
+UPDATE grocery_items i SET category = c.name FROM group_categories c WHERE c.id = i.category_id;
+ALTER TABLE grocery_items ALTER COLUMN category SET NOT NULL;
+ALTER TABLE grocery_items DROP COLUMN IF EXISTS category_id;
+
+DELETE FROM sync_tombstones WHERE entity_type = 'category';
+DROP TABLE IF EXISTS group_categories;
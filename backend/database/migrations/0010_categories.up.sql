@@ -0,0 +1,69 @@
+-- Categories become their own entity with a stable ID, sort order, color and
+-- icon. Grocery items reference them by ID, and a group's categories list is
+-- read from this table instead of the groups.categories array.
+
+CREATE TABLE group_categories (
+    id         TEXT PRIMARY KEY,
+    group_id   TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    color      TEXT CHECK (color ~ '^#[0-9A-F]{6}$'),
+    icon       TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    change_seq BIGINT NOT NULL DEFAULT 0,
+    version    BIGINT NOT NULL DEFAULT 1
+);
+
+-- A group can't have two categories that differ only in case
+CREATE UNIQUE INDEX group_categories_group_name_idx ON group_categories (group_id, lower(name));
+CREATE INDEX group_categories_group_change_seq_idx ON group_categories (group_id, change_seq);
+
+-- Created before the backfill so the backfilled rows get sequence numbers
+-- and show up in the next sync.
+CREATE TRIGGER group_categories_track_change BEFORE INSERT OR UPDATE ON group_categories
+    FOR EACH ROW EXECUTE FUNCTION track_row_change();
+CREATE TRIGGER group_categories_track_delete AFTER DELETE ON group_categories
+    FOR EACH ROW EXECUTE FUNCTION track_row_delete('category');
+
+-- The group's list in order, dropping repeats that differ only in case
+INSERT INTO group_categories (id, group_id, name, sort_order)
+SELECT gen_random_uuid()::text, c.group_id, c.name, c.position - 1
+FROM (
+    SELECT DISTINCT ON (g.id, lower(btrim(category.name)))
+        g.id AS group_id, btrim(category.name) AS name, category.position
+    FROM groups g, unnest(g.categories) WITH ORDINALITY AS category (name, position)
+    WHERE btrim(category.name) <> ''
+    ORDER BY g.id, lower(btrim(category.name)), category.position
+) c;
+
+-- Categories items use that had already been removed from the list are
+-- added back at the end, so every item has a category to reference. Items
+-- without a category are moved to Other.
+INSERT INTO group_categories (id, group_id, name, sort_order)
+SELECT gen_random_uuid()::text, i.group_id, i.name,
+    COALESCE((SELECT max(c.sort_order) FROM group_categories c WHERE c.group_id = i.group_id), -1)
+        + row_number() OVER (PARTITION BY i.group_id ORDER BY lower(i.name))
+FROM (
+    SELECT DISTINCT ON (group_id, lower(name)) group_id, name
+    FROM (
+        SELECT group_id, COALESCE(NULLIF(btrim(category), ''), 'Other') AS name
+        FROM grocery_items
+    ) named
+    ORDER BY group_id, lower(name), name
+) i
+WHERE NOT EXISTS (
+    SELECT 1 FROM group_categories c WHERE c.group_id = i.group_id AND lower(c.name) = lower(i.name)
+);
+
+ALTER TABLE grocery_items ADD COLUMN category_id TEXT REFERENCES group_categories (id);
+
+UPDATE grocery_items i SET category_id = c.id
+FROM group_categories c
+WHERE c.group_id = i.group_id
+    AND lower(c.name) = lower(COALESCE(NULLIF(btrim(i.category), ''), 'Other'));
+
+ALTER TABLE grocery_items ALTER COLUMN category_id SET NOT NULL;
+ALTER TABLE grocery_items DROP COLUMN category;
+ALTER TABLE groups DROP COLUMN categories;
+
+CREATE INDEX grocery_items_category_id_idx ON grocery_items (category_id);
@@ -0,0 +1,31 @@
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS members TEXT[] NOT NULL DEFAULT '{}';
+UPDATE groups g SET members = ARRAY(
+    SELECT m.display_name FROM group_members m
+    WHERE m.group_id = g.id AND NOT m.archived ORDER BY m.sort_order, m.display_name
+);
+
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS paid_by TEXT;
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS paid_to TEXT;
+UPDATE settlements s SET
+    paid_by = (SELECT m.display_name FROM group_members m WHERE m.id = s.paid_by_id),
+    paid_to = (SELECT m.display_name FROM group_members m WHERE m.id = s.paid_to_id);
+ALTER TABLE settlements ALTER COLUMN paid_by SET NOT NULL;
+ALTER TABLE settlements ALTER COLUMN paid_to SET NOT NULL;
+ALTER TABLE settlements DROP COLUMN IF EXISTS paid_by_id;
+ALTER TABLE settlements DROP COLUMN IF EXISTS paid_to_id;
+ALTER TABLE settlements ADD CHECK (paid_by <> paid_to);
+
+UPDATE receipts r SET splits = (
+    SELECT COALESCE(jsonb_agg(jsonb_build_object('member', m.display_name, 'weight', (s.split->>'weight')::int) ORDER BY s.position), '[]')
+    FROM jsonb_array_elements(r.splits) WITH ORDINALITY AS s (split, position)
+    JOIN group_members m ON m.id = s.split->>'memberId'
+)
+WHERE r.splits <> '[]';
+
+ALTER TABLE receipts ADD COLUMN IF NOT EXISTS purchased_by TEXT;
+UPDATE receipts r SET purchased_by = m.display_name FROM group_members m WHERE m.id = r.purchased_by_id;
+ALTER TABLE receipts ALTER COLUMN purchased_by SET NOT NULL;
+ALTER TABLE receipts DROP COLUMN IF EXISTS purchased_by_id;
+
+DELETE FROM sync_tombstones WHERE entity_type = 'member';
+DROP TABLE IF EXISTS group_members;
@@ -0,0 +1,108 @@
+-- Members become their own entity with a stable ID, display name, color and
+-- archived flag. Receipts, their splits and settlements reference members by
+-- ID, so renaming a member no longer orphans their history. A group's members
+-- list is read from this table instead of the groups.members array.
+
+CREATE TABLE group_members (
+    id           TEXT PRIMARY KEY,
+    group_id     TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    display_name TEXT NOT NULL,
+    color        TEXT CHECK (color ~ '^#[0-9A-F]{6}$'),
+    archived     BOOLEAN NOT NULL DEFAULT false,
+    sort_order   INTEGER NOT NULL DEFAULT 0,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    change_seq   BIGINT NOT NULL DEFAULT 0,
+    version      BIGINT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX group_members_group_id_idx ON group_members (group_id);
+CREATE INDEX group_members_group_change_seq_idx ON group_members (group_id, change_seq);
+
+CREATE TRIGGER group_members_track_change BEFORE INSERT OR UPDATE ON group_members
+    FOR EACH ROW EXECUTE FUNCTION track_row_change();
+CREATE TRIGGER group_members_track_delete AFTER DELETE ON group_members
+    FOR EACH ROW EXECUTE FUNCTION track_row_delete('member');
+
+-- The group's list in order, dropping repeats that differ only in case
+INSERT INTO group_members (id, group_id, display_name, sort_order)
+SELECT gen_random_uuid()::text, m.group_id, m.name, m.position - 1
+FROM (
+    SELECT DISTINCT ON (g.id, lower(btrim(member.name)))
+        g.id AS group_id, btrim(member.name) AS name, member.position
+    FROM groups g, unnest(g.members) WITH ORDINALITY AS member (name, position)
+    WHERE btrim(member.name) <> ''
+    ORDER BY g.id, lower(btrim(member.name)), member.position
+) m;
+
+-- Names receipts, splits and settlements use that aren't in the list anymore
+-- become archived members, so their history still adds up
+INSERT INTO group_members (id, group_id, display_name, archived)
+SELECT gen_random_uuid()::text, n.group_id, n.name, true
+FROM (
+    SELECT DISTINCT ON (group_id, lower(name)) group_id, name
+    FROM (
+        SELECT group_id, btrim(purchased_by) AS name FROM receipts
+        UNION ALL
+        SELECT r.group_id, btrim(split->>'member') FROM receipts r, jsonb_array_elements(r.splits) AS split
+        UNION ALL
+        SELECT group_id, btrim(paid_by) FROM settlements
+        UNION ALL
+        SELECT group_id, btrim(paid_to) FROM settlements
+    ) used
+    WHERE name <> ''
+    ORDER BY group_id, lower(name), name
+) n
+WHERE NOT EXISTS (
+    SELECT 1 FROM group_members m WHERE m.group_id = n.group_id AND lower(m.display_name) = lower(n.name)
+);
+
+ALTER TABLE receipts ADD COLUMN purchased_by_id TEXT REFERENCES group_members (id);
+UPDATE receipts r SET purchased_by_id = m.id
+FROM group_members m
+WHERE m.group_id = r.group_id AND lower(m.display_name) = lower(btrim(r.purchased_by));
+
+-- Receipts bought by a blank name go to the group's first member, or to a
+-- new Default member if the group has none
+UPDATE receipts r SET purchased_by_id = (
+    SELECT m.id FROM group_members m WHERE m.group_id = r.group_id ORDER BY m.archived, m.sort_order, m.display_name LIMIT 1
+)
+WHERE purchased_by_id IS NULL;
+INSERT INTO group_members (id, group_id, display_name)
+SELECT gen_random_uuid()::text, r.group_id, 'Default'
+FROM (SELECT DISTINCT group_id FROM receipts WHERE purchased_by_id IS NULL) r;
+UPDATE receipts r SET purchased_by_id = m.id
+FROM group_members m
+WHERE r.purchased_by_id IS NULL AND m.group_id = r.group_id;
+
+ALTER TABLE receipts ALTER COLUMN purchased_by_id SET NOT NULL;
+ALTER TABLE receipts DROP COLUMN purchased_by;
+
+-- Splits keep their order and weights but name members by ID
+UPDATE receipts r SET splits = (
+    SELECT COALESCE(jsonb_agg(jsonb_build_object('memberId', m.id, 'weight', (s.split->>'weight')::int) ORDER BY s.position), '[]')
+    FROM jsonb_array_elements(r.splits) WITH ORDINALITY AS s (split, position)
+    JOIN group_members m ON m.group_id = r.group_id AND lower(m.display_name) = lower(btrim(s.split->>'member'))
+)
+WHERE r.splits <> '[]';
+
+ALTER TABLE settlements ADD COLUMN paid_by_id TEXT REFERENCES group_members (id);
+ALTER TABLE settlements ADD COLUMN paid_to_id TEXT REFERENCES group_members (id);
+UPDATE settlements s SET paid_by_id = payer.id, paid_to_id = payee.id
+FROM group_members payer, group_members payee
+WHERE payer.group_id = s.group_id AND lower(payer.display_name) = lower(btrim(s.paid_by))
+    AND payee.group_id = s.group_id AND lower(payee.display_name) = lower(btrim(s.paid_to));
+
+-- Settlements between two spellings of the same name cancel out
+DELETE FROM settlements WHERE paid_by_id = paid_to_id;
+
+ALTER TABLE settlements ALTER COLUMN paid_by_id SET NOT NULL;
+ALTER TABLE settlements ALTER COLUMN paid_to_id SET NOT NULL;
+ALTER TABLE settlements DROP COLUMN paid_by;
+ALTER TABLE settlements DROP COLUMN paid_to;
+ALTER TABLE settlements ADD CHECK (paid_by_id <> paid_to_id);
+
+ALTER TABLE groups DROP COLUMN members;
+
+CREATE INDEX receipts_purchased_by_id_idx ON receipts (purchased_by_id);
+CREATE INDEX settlements_paid_by_id_idx ON settlements (paid_by_id);
+CREATE INDEX settlements_paid_to_id_idx ON settlements (paid_to_id);
@@ -0,0 +1,4 @@
+ALTER TABLE grocery_items
+    DROP COLUMN IF EXISTS note,
+    DROP COLUMN IF EXISTS unit,
+    DROP COLUMN IF EXISTS quantity;
@@ -0,0 +1,6 @@
+-- Optional quantity, unit and note on grocery items, so amounts no longer
+-- have to be written into the name
+ALTER TABLE grocery_items
+    ADD COLUMN IF NOT EXISTS quantity NUMERIC(12, 3) CHECK (quantity > 0),
+    ADD COLUMN IF NOT EXISTS unit TEXT CHECK (unit IN ('g', 'kg', 'ml', 'l', 'pcs', 'pack')),
+    ADD COLUMN IF NOT EXISTS note TEXT;
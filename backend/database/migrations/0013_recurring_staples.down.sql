@@ -0,0 +1,10 @@
+DROP TRIGGER grocery_items_track_change ON grocery_items;
+CREATE TRIGGER grocery_items_track_change BEFORE INSERT OR UPDATE ON grocery_items
+    FOR EACH ROW EXECUTE FUNCTION track_row_change();
+
+DROP FUNCTION track_grocery_item_change();
+
+DROP INDEX IF EXISTS idx_grocery_items_next_due_at;
+ALTER TABLE grocery_items
+    DROP COLUMN IF EXISTS next_due_at,
+    DROP COLUMN IF EXISTS recurrence;
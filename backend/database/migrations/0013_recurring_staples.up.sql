@@ -0,0 +1,28 @@
+-- Recurring staples. The scheduler marks items needed again once next_due_at
+-- has passed and moves it on to the following due date.
+ALTER TABLE grocery_items
+    ADD COLUMN IF NOT EXISTS recurrence JSONB,
+    ADD COLUMN IF NOT EXISTS next_due_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_grocery_items_next_due_at ON grocery_items(next_due_at) WHERE next_due_at IS NOT NULL;
+
+-- Moving a recurring grocery item on to its next due date while it is already
+-- needed isn't a change clients have to see, so an update touching nothing
+-- but next_due_at keeps the item's version and change_seq.
+CREATE FUNCTION track_grocery_item_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND (to_jsonb(NEW) - 'next_due_at') = (to_jsonb(OLD) - 'next_due_at') THEN
+        RETURN NEW;
+    END IF;
+    NEW.change_seq := COALESCE(next_group_change_seq(NEW.group_id), 0);
+    NEW.updated_at := now();
+    IF TG_OP = 'UPDATE' THEN
+        NEW.version := OLD.version + 1;
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER grocery_items_track_change ON grocery_items;
+CREATE TRIGGER grocery_items_track_change BEFORE INSERT OR UPDATE ON grocery_items
+    FOR EACH ROW EXECUTE FUNCTION track_grocery_item_change();
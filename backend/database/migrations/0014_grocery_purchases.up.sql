@@ -0,0 +1,14 @@
+-- Purchases of grocery items, recorded when a receipt marks them as bought.
+-- They are never changed afterwards and feed the grocery suggestions.
+CREATE TABLE grocery_purchases (
+    id              TEXT PRIMARY KEY,
+    grocery_item_id TEXT NOT NULL REFERENCES grocery_items (id) ON DELETE CASCADE,
+    receipt_id      TEXT REFERENCES receipts (id) ON DELETE SET NULL,
+    member_id       TEXT NOT NULL REFERENCES group_members (id),
+    purchased_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    group_id        TEXT NOT NULL REFERENCES groups (id) ON DELETE CASCADE
+);
+
+CREATE INDEX grocery_purchases_group_purchased_at_idx ON grocery_purchases (group_id, purchased_at);
+CREATE INDEX grocery_purchases_grocery_item_id_idx ON grocery_purchases (grocery_item_id);
+CREATE INDEX grocery_purchases_receipt_id_idx ON grocery_purchases (receipt_id);
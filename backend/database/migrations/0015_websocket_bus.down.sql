@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS websocket_events;
+DROP TABLE IF EXISTS websocket_sequences;
@@ -0,0 +1,18 @@
+-- Websocket events shared between backend instances. Each group's events are
+-- numbered from websocket_sequences, stored here for the other instances to
+-- load when NOTIFY announces them, and pruned after a few minutes.
+CREATE TABLE websocket_sequences (
+    group_id TEXT PRIMARY KEY,
+    seq      BIGINT NOT NULL
+);
+
+CREATE TABLE websocket_events (
+    id         BIGSERIAL PRIMARY KEY,
+    group_id   TEXT NOT NULL,
+    seq        BIGINT NOT NULL,
+    event      TEXT NOT NULL,
+    data       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX websocket_events_created_at_idx ON websocket_events (created_at);
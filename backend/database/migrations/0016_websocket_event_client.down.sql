@@ -0,0 +1 @@
+ALTER TABLE websocket_events DROP COLUMN client_id;
@@ -0,0 +1,3 @@
+-- The client whose request caused a websocket event, so its own connections
+-- can tell it apart. Empty when the request didn't name one.
+ALTER TABLE websocket_events ADD COLUMN client_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS grocery_items_group_name_key_idx;
+ALTER TABLE grocery_items DROP COLUMN IF EXISTS name_key;
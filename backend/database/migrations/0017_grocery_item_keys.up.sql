@@ -0,0 +1,24 @@
+-- The key a grocery item's name is matched by when it's added again, so that
+-- two devices adding the same item at once can't both create it. The backend
+-- sets it from models.GroceryItemKey, which also drops amounts like "1L" from
+-- the name. The backfill only lowercases and collapses spaces, which never
+-- gives two different items the same key; of items that already share one,
+-- only the most recently updated keeps it.
+ALTER TABLE grocery_items ADD COLUMN IF NOT EXISTS name_key TEXT;
+
+-- Nothing clients see changes, so the items keep their version and
+-- change_seq
+ALTER TABLE grocery_items DISABLE TRIGGER grocery_items_track_change;
+UPDATE grocery_items i SET name_key = k.name_key
+FROM (
+    SELECT id, lower(regexp_replace(btrim(name), '\s+', ' ', 'g')) AS name_key,
+        row_number() OVER (
+            PARTITION BY group_id, lower(regexp_replace(btrim(name), '\s+', ' ', 'g'))
+            ORDER BY updated_at DESC, id
+        ) AS position
+    FROM grocery_items
+) k
+WHERE k.id = i.id AND k.position = 1;
+ALTER TABLE grocery_items ENABLE TRIGGER grocery_items_track_change;
+
+CREATE UNIQUE INDEX IF NOT EXISTS grocery_items_group_name_key_idx ON grocery_items (group_id, name_key);
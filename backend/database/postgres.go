@@ -0,0 +1,1660 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lebensmittel/backend/models"
+)
+
+// PostgresStore is the Store implementation backed by a pgx connection pool.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore wraps an existing pool. The schema is expected to be migrated.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+// InitDB connects to the database at databaseURL, applies any pending
+// migrations and returns the ready to use store.
+func InitDB(databaseURL string) (*PostgresStore, error) {
+	pool, err := Connect(context.Background(), databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := MigrateUp(context.Background(), pool)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if applied > 0 {
+		log.Printf("Applied %d database migration(s)", applied)
+	}
+
+	log.Println("Database connection established")
+	return NewPostgresStore(pool), nil
+}
+
+// Connect opens a connection pool without touching the schema.
+func Connect(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+
+	err = pool.Ping(ctx)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return pool, nil
+}
+
+func (s *PostgresStore) Close() {
+	s.pool.Close()
+}
+
+// Pool returns the store's connection pool, for sharing it with the
+// websocket bus.
+func (s *PostgresStore) Pool() *pgxpool.Pool {
+	return s.pool
+}
+
+// setClause builds the SET list of an UPDATE from a fixed set of column names.
+// The key arguments (id, group_id) come first so they are always $1 and $2.
+type setClause struct {
+	parts []string
+	args  []any
+}
+
+func newSetClause(keys ...any) *setClause {
+	return &setClause{args: keys}
+}
+
+func (s *setClause) add(column string, value any) {
+	s.args = append(s.args, value)
+	s.parts = append(s.parts, fmt.Sprintf("%s = $%d", column, len(s.args)))
+}
+
+// arg adds a value that isn't a column assignment (e.g. for the WHERE clause)
+// and returns its placeholder.
+func (s *setClause) arg(value any) string {
+	s.args = append(s.args, value)
+	return fmt.Sprintf("$%d", len(s.args))
+}
+
+func (s *setClause) empty() bool {
+	return len(s.parts) == 0
+}
+
+func (s *setClause) String() string {
+	return strings.Join(s.parts, ", ")
+}
+
+// versionGuard returns the WHERE condition for an optional expected version.
+func (s *setClause) versionGuard(expectedVersion *int64) string {
+	if expectedVersion == nil {
+		return ""
+	}
+	return " AND version = " + s.arg(*expectedVersion)
+}
+
+// querier is satisfied by both the pool and a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// isUniqueViolation reports whether err comes from breaking the unique index
+// named index.
+func isUniqueViolation(err error, index string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == index
+}
+
+// Column lists and their matching scan functions, shared by every query that
+// returns a full entity.
+
+// The category name is read from the category the item references, so that
+// renaming a category renames it on every item.
+const groceryItemColumns = `id, name, (SELECT c.name FROM group_categories c WHERE c.id = grocery_items.category_id), category_id,
+	is_needed, is_shopping_checked, quantity, unit, note, recurrence, next_due_at, group_id, updated_at, change_seq, version`
+
+func scanGroceryItem(row pgx.Row) (models.GroceryItem, error) {
+	var item models.GroceryItem
+	err := row.Scan(&item.ID, &item.Name, &item.Category, &item.CategoryID, &item.IsNeeded, &item.IsShoppingChecked, &item.Quantity, &item.Unit, &item.Note, &item.Recurrence, &item.NextDueAt, &item.GroupID, &item.UpdatedAt, &item.ChangeSeq, &item.Version)
+	return item, err
+}
+
+const mealPlanColumns = `id, date, meal_description, group_id, updated_at, change_seq, version`
+
+func scanMealPlan(row pgx.Row) (models.MealPlan, error) {
+	var meal models.MealPlan
+	err := row.Scan(&meal.ID, &meal.Date, &meal.MealDescription, &meal.GroupID, &meal.UpdatedAt, &meal.ChangeSeq, &meal.Version)
+	return meal, err
+}
+
+// Member names are read from the members the receipt references, like
+// category names on grocery items. Splits are stored as member IDs and
+// weights.
+const receiptColumns = `id, date, total_cents, currency, purchased_by_id,
+	(SELECT m.display_name FROM group_members m WHERE m.id = receipts.purchased_by_id), items,
+	(SELECT COALESCE(jsonb_agg(jsonb_build_object('memberId', m.id, 'member', m.display_name, 'weight', (s.split->>'weight')::int) ORDER BY s.position), '[]')
+		FROM jsonb_array_elements(receipts.splits) WITH ORDINALITY AS s (split, position)
+		JOIN group_members m ON m.id = s.split->>'memberId'),
+	notes, group_id, updated_at, change_seq, version`
+
+func scanReceipt(row pgx.Row) (models.Receipt, error) {
+	var receipt models.Receipt
+	err := row.Scan(&receipt.ID, &receipt.Date, &receipt.TotalAmount, &receipt.Currency, &receipt.PurchasedByID, &receipt.PurchasedBy, &receipt.Items, &receipt.Splits, &receipt.Notes, &receipt.GroupID, &receipt.UpdatedAt, &receipt.ChangeSeq, &receipt.Version)
+	return receipt, err
+}
+
+// storedSplit is how a receipt split is kept in the splits column.
+type storedSplit struct {
+	MemberID string `json:"memberId"`
+	Weight   int    `json:"weight"`
+}
+
+func storedSplits(splits []models.ReceiptSplit) []storedSplit {
+	stored := make([]storedSplit, len(splits))
+	for i, split := range splits {
+		stored[i] = storedSplit{MemberID: split.MemberID, Weight: split.Weight}
+	}
+	return stored
+}
+
+const settlementColumns = `id, date, paid_by_id, paid_to_id,
+	(SELECT m.display_name FROM group_members m WHERE m.id = settlements.paid_by_id),
+	(SELECT m.display_name FROM group_members m WHERE m.id = settlements.paid_to_id),
+	amount_cents, currency, notes, group_id, updated_at, change_seq, version`
+
+func scanSettlement(row pgx.Row) (models.Settlement, error) {
+	var settlement models.Settlement
+	err := row.Scan(&settlement.ID, &settlement.Date, &settlement.PaidByID, &settlement.PaidToID, &settlement.PaidBy, &settlement.PaidTo, &settlement.Amount, &settlement.Currency, &settlement.Notes, &settlement.GroupID, &settlement.UpdatedAt, &settlement.ChangeSeq, &settlement.Version)
+	return settlement, err
+}
+
+const groupColumns = `id, name,
+	ARRAY(SELECT c.name FROM group_categories c WHERE c.group_id = groups.id ORDER BY c.sort_order, c.name),
+	ARRAY(SELECT m.display_name FROM group_members m WHERE m.group_id = groups.id AND NOT m.archived ORDER BY m.sort_order, m.display_name),
+	currency, requires_token, version`
+
+func scanGroup(row pgx.Row) (models.Group, error) {
+	var group models.Group
+	err := row.Scan(&group.ID, &group.Name, &group.Categories, &group.Members, &group.Currency, &group.RequiresToken, &group.Version)
+	return group, err
+}
+
+const categoryColumns = `id, name, sort_order, color, icon, group_id, updated_at, change_seq, version`
+
+func scanCategory(row pgx.Row) (models.Category, error) {
+	var category models.Category
+	err := row.Scan(&category.ID, &category.Name, &category.SortOrder, &category.Color, &category.Icon, &category.GroupID, &category.UpdatedAt, &category.ChangeSeq, &category.Version)
+	return category, err
+}
+
+const memberColumns = `id, display_name, color, archived, sort_order, group_id, updated_at, change_seq, version`
+
+func scanMember(row pgx.Row) (models.Member, error) {
+	var member models.Member
+	err := row.Scan(&member.ID, &member.DisplayName, &member.Color, &member.Archived, &member.SortOrder, &member.GroupID, &member.UpdatedAt, &member.ChangeSeq, &member.Version)
+	return member, err
+}
+
+const accessTokenColumns = `id, group_id, token_hash, device_name, is_admin, scope, created_at, revoked_at`
+
+func scanAccessToken(row pgx.Row) (models.AccessToken, error) {
+	var token models.AccessToken
+	err := row.Scan(&token.ID, &token.GroupID, &token.TokenHash, &token.DeviceName, &token.Admin, &token.Scope, &token.CreatedAt, &token.RevokedAt)
+	return token, err
+}
+
+const inviteColumns = `code, group_id, created_by, created_at, expires_at, max_uses, uses, revoked_at`
+
+func scanInvite(row pgx.Row) (models.Invite, error) {
+	var invite models.Invite
+	err := row.Scan(&invite.Code, &invite.GroupID, &invite.CreatedBy, &invite.CreatedAt, &invite.ExpiresAt, &invite.MaxUses, &invite.Uses, &invite.RevokedAt)
+	return invite, err
+}
+
+// queryAll runs a query returning many rows and scans each one with scan.
+func queryAll[T any](ctx context.Context, q querier, scan func(pgx.Row) (T, error), query string, args ...any) ([]T, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []T{}
+	for rows.Next() {
+		result, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// queryOne runs a query returning at most one row. A missing row is reported
+// as a nil result and a nil error, like the Get*ByID lookups.
+func queryOne[T any](ctx context.Context, q querier, scan func(pgx.Row) (T, error), query string, args ...any) (*T, error) {
+	result, err := scan(q.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GroceryItems
+
+func (s *PostgresStore) GetAllGroceryItems(ctx context.Context, groupID string) ([]models.GroceryItem, error) {
+	query := `SELECT ` + groceryItemColumns + ` FROM grocery_items WHERE group_id = $1 ORDER BY name`
+	items, err := queryAll(ctx, s.pool, scanGroceryItem, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query grocery items: %w", err)
+	}
+	return items, nil
+}
+
+func (s *PostgresStore) CreateGroceryItem(ctx context.Context, item *models.GroceryItem) error {
+	query := `INSERT INTO grocery_items (id, name, name_key, category_id, is_needed, is_shopping_checked, quantity, unit, note, recurrence, next_due_at, group_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING updated_at, change_seq, version`
+	err := s.pool.QueryRow(ctx, query, item.ID, item.Name, models.GroceryItemKey(item.Name), item.CategoryID, item.IsNeeded, item.IsShoppingChecked, item.Quantity, item.Unit, item.Note, item.Recurrence, item.NextDueAt, item.GroupID).
+		Scan(&item.UpdatedAt, &item.ChangeSeq, &item.Version)
+	if isUniqueViolation(err, "grocery_items_group_name_key_idx") {
+		return ErrGroceryItemExists
+	}
+	return err
+}
+
+func (s *PostgresStore) UpdateGroceryItem(ctx context.Context, id, groupID string, patch models.GroceryItemPatch) (*models.GroceryItem, error) {
+	set := newSetClause(id, groupID)
+	if patch.Name != nil {
+		set.add("name", *patch.Name)
+		set.add("name_key", models.GroceryItemKey(*patch.Name))
+	}
+	// The handler resolves a category name to its ID
+	if patch.CategoryID != nil {
+		set.add("category_id", *patch.CategoryID)
+	}
+	if patch.IsNeeded != nil {
+		set.add("is_needed", *patch.IsNeeded)
+	}
+	if patch.IsShoppingChecked != nil {
+		set.add("is_shopping_checked", *patch.IsShoppingChecked)
+	}
+	if patch.Quantity != nil {
+		set.add("quantity", patch.Quantity.Value)
+	}
+	if patch.Unit != nil {
+		set.add("unit", patch.Unit.Value)
+	}
+	if patch.Note != nil {
+		set.add("note", patch.Note.Value)
+	}
+	if patch.Recurrence != nil {
+		set.add("recurrence", patch.Recurrence.Value)
+		set.add("next_due_at", patch.Recurrence.Value.FirstDue(time.Now()))
+	}
+
+	if set.empty() {
+		return s.GetGroceryItemByID(ctx, id, groupID)
+	}
+
+	query := fmt.Sprintf("UPDATE grocery_items SET %s WHERE id = $1 AND group_id = $2%s RETURNING %s", set, set.versionGuard(patch.Version), groceryItemColumns)
+	item, err := queryOne(ctx, s.pool, scanGroceryItem, query, set.args...)
+	if isUniqueViolation(err, "grocery_items_group_name_key_idx") {
+		return nil, models.FieldErrors{"name": "is already on the group's list"}
+	}
+	if err != nil || item != nil || patch.Version == nil {
+		return item, err
+	}
+	return nil, s.conflictIfExists(ctx, "grocery_items", id, groupID)
+}
+
+func (s *PostgresStore) GetGroceryItemByID(ctx context.Context, id, groupID string) (*models.GroceryItem, error) {
+	query := `SELECT ` + groceryItemColumns + ` FROM grocery_items WHERE id = $1 AND group_id = $2`
+	return queryOne(ctx, s.pool, scanGroceryItem, query, id, groupID)
+}
+
+func (s *PostgresStore) DeleteGroceryItem(ctx context.Context, id, groupID string, expectedVersion *int64) error {
+	return s.deleteVersioned(ctx, "grocery_items", id, groupID, expectedVersion, ErrGroceryItemNotFound)
+}
+
+func (s *PostgresStore) RenewRecurringGroceryItems(ctx context.Context, now time.Time) ([]models.GroceryItem, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Other instances renewing at the same time skip the rows this one holds
+	query := `SELECT ` + groceryItemColumns + ` FROM grocery_items WHERE next_due_at <= $1 FOR UPDATE SKIP LOCKED`
+	due, err := queryAll(ctx, tx, scanGroceryItem, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due grocery items: %w", err)
+	}
+
+	renewed := []models.GroceryItem{}
+	for _, item := range due {
+		var next *time.Time
+		if item.Recurrence != nil {
+			nextDue := item.Recurrence.NextDue(item.NextDueAt, now)
+			next = &nextDue
+		}
+		if item.IsNeeded {
+			// The change tracking trigger ignores an update of next_due_at
+			// alone, so the item keeps its version
+			if _, err := tx.Exec(ctx, `UPDATE grocery_items SET next_due_at = $2 WHERE id = $1`, item.ID, next); err != nil {
+				return nil, fmt.Errorf("failed to move grocery item on: %w", err)
+			}
+			continue
+		}
+		// Items that weren't needed come back unchecked
+		updateQuery := `UPDATE grocery_items SET is_needed = true, is_shopping_checked = false, next_due_at = $2
+			WHERE id = $1 RETURNING ` + groceryItemColumns
+		updated, err := queryOne(ctx, tx, scanGroceryItem, updateQuery, item.ID, next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to renew grocery item: %w", err)
+		}
+		renewed = append(renewed, *updated)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return renewed, nil
+}
+
+// MealPlans
+
+func (s *PostgresStore) GetAllMealPlans(ctx context.Context, groupID string) ([]models.MealPlan, error) {
+	query := `SELECT ` + mealPlanColumns + ` FROM meal_plans WHERE group_id = $1 ORDER BY date`
+	meals, err := queryAll(ctx, s.pool, scanMealPlan, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query meal plans: %w", err)
+	}
+	return meals, nil
+}
+
+func (s *PostgresStore) CreateMealPlan(ctx context.Context, meal *models.MealPlan) error {
+	query := `INSERT INTO meal_plans (id, date, meal_description, group_id) VALUES ($1, $2, $3, $4)
+		RETURNING updated_at, change_seq, version`
+	err := s.pool.QueryRow(ctx, query, meal.ID, meal.Date, meal.MealDescription, meal.GroupID).Scan(&meal.UpdatedAt, &meal.ChangeSeq, &meal.Version)
+	if err != nil {
+		return fmt.Errorf("failed to create meal plan: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) UpdateMealPlan(ctx context.Context, id, groupID string, patch models.MealPlanPatch) (*models.MealPlan, error) {
+	set := newSetClause(id, groupID)
+	if patch.Date != nil {
+		set.add("date", patch.Date.Time)
+	}
+	if patch.MealDescription != nil {
+		set.add("meal_description", *patch.MealDescription)
+	}
+	if set.empty() {
+		return s.GetMealPlanByID(ctx, id, groupID)
+	}
+
+	query := fmt.Sprintf("UPDATE meal_plans SET %s WHERE id = $1 AND group_id = $2%s RETURNING %s", set, set.versionGuard(patch.Version), mealPlanColumns)
+	meal, err := queryOne(ctx, s.pool, scanMealPlan, query, set.args...)
+	if err != nil || meal != nil || patch.Version == nil {
+		return meal, err
+	}
+	return nil, s.conflictIfExists(ctx, "meal_plans", id, groupID)
+}
+
+func (s *PostgresStore) GetMealPlanByID(ctx context.Context, id, groupID string) (*models.MealPlan, error) {
+	query := `SELECT ` + mealPlanColumns + ` FROM meal_plans WHERE id = $1 AND group_id = $2`
+	return queryOne(ctx, s.pool, scanMealPlan, query, id, groupID)
+}
+
+func (s *PostgresStore) DeleteMealPlan(ctx context.Context, id, groupID string, expectedVersion *int64) error {
+	return s.deleteVersioned(ctx, "meal_plans", id, groupID, expectedVersion, ErrMealPlanNotFound)
+}
+
+// Receipts
+
+const receiptLineItemColumns = `receipt_id, id, name, quantity, unit_price_cents, grocery_item_id, category`
+
+// attachLineItems loads the line items of receipts with a single query.
+func attachLineItems(ctx context.Context, q querier, receipts []models.Receipt) error {
+	if len(receipts) == 0 {
+		return nil
+	}
+	ids := make([]string, len(receipts))
+	byID := make(map[string]*models.Receipt, len(receipts))
+	for i := range receipts {
+		ids[i] = receipts[i].ID
+		receipts[i].LineItems = []models.ReceiptLineItem{}
+		byID[receipts[i].ID] = &receipts[i]
+	}
+
+	query := `SELECT ` + receiptLineItemColumns + ` FROM receipt_line_items WHERE receipt_id = ANY($1) ORDER BY receipt_id, position`
+	rows, err := q.Query(ctx, query, ids)
+	if err != nil {
+		return fmt.Errorf("failed to query receipt line items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var receiptID string
+		var line models.ReceiptLineItem
+		if err := rows.Scan(&receiptID, &line.ID, &line.Name, &line.Quantity, &line.UnitPrice, &line.GroceryItemID, &line.Category); err != nil {
+			return fmt.Errorf("failed to scan receipt line item: %w", err)
+		}
+		receipt := byID[receiptID]
+		receipt.LineItems = append(receipt.LineItems, line)
+	}
+	return rows.Err()
+}
+
+// replaceLineItems overwrites the stored line items of receipt with its current ones.
+func replaceLineItems(ctx context.Context, q querier, receipt *models.Receipt) error {
+	if _, err := q.Exec(ctx, `DELETE FROM receipt_line_items WHERE receipt_id = $1`, receipt.ID); err != nil {
+		return fmt.Errorf("failed to clear receipt line items: %w", err)
+	}
+	query := `INSERT INTO receipt_line_items (id, receipt_id, position, name, quantity, unit_price_cents, grocery_item_id, category)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	for i, line := range receipt.LineItems {
+		_, err := q.Exec(ctx, query, line.ID, receipt.ID, i, line.Name, line.Quantity, line.UnitPrice, line.GroceryItemID, line.Category)
+		if err != nil {
+			return fmt.Errorf("failed to insert receipt line item: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStore) GetAllReceipts(ctx context.Context, groupID string) ([]models.Receipt, error) {
+	query := `SELECT ` + receiptColumns + ` FROM receipts WHERE group_id = $1 ORDER BY date DESC`
+	receipts, err := queryAll(ctx, s.pool, scanReceipt, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipts: %w", err)
+	}
+	if err := attachLineItems(ctx, s.pool, receipts); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+func (s *PostgresStore) CreateReceipt(ctx context.Context, receipt *models.Receipt) ([]models.GroceryItem, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Mark the needed and checked items listed on the receipt, by name or
+	// by a line item's link, as bought.
+	var linkedIDs []string
+	for _, line := range receipt.LineItems {
+		if line.GroceryItemID != nil {
+			linkedIDs = append(linkedIDs, *line.GroceryItemID)
+		}
+	}
+	updatedItems := []models.GroceryItem{}
+	if len(receipt.ItemsList) > 0 || len(linkedIDs) > 0 {
+		updateQuery := `UPDATE grocery_items SET is_needed = false, is_shopping_checked = false
+			WHERE group_id = $1 AND is_needed = true AND is_shopping_checked = true AND (name = ANY($2) OR id = ANY($3))
+			RETURNING ` + groceryItemColumns
+		updatedItems, err = queryAll(ctx, tx, scanGroceryItem, updateQuery, receipt.GroupID, receipt.ItemsList, linkedIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update explicit grocery items: %w", err)
+		}
+	}
+
+	if receipt.Splits == nil {
+		receipt.Splits = []models.ReceiptSplit{}
+	}
+	query := `INSERT INTO receipts (id, date, total_cents, currency, purchased_by_id, items, splits, notes, group_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING updated_at, change_seq, version`
+	err = tx.QueryRow(ctx, query, receipt.ID, receipt.Date, receipt.TotalAmount, receipt.Currency, receipt.PurchasedByID, receipt.Items, storedSplits(receipt.Splits), receipt.Notes, receipt.GroupID).
+		Scan(&receipt.UpdatedAt, &receipt.ChangeSeq, &receipt.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create receipt: %w", err)
+	}
+	if err := replaceLineItems(ctx, tx, receipt); err != nil {
+		return nil, err
+	}
+	if err := insertPurchases(ctx, tx, *receipt, updatedItems); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return updatedItems, nil
+}
+
+// UpdateReceipt applies the patch in Go rather than building the SET clause
+// from it, because changes to the items or the total have to be checked
+// against the whole receipt.
+func (s *PostgresStore) UpdateReceipt(ctx context.Context, id, groupID string, patch models.ReceiptPatch) (*models.Receipt, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT ` + receiptColumns + ` FROM receipts WHERE id = $1 AND group_id = $2 FOR UPDATE`
+	receipt, err := queryOne(ctx, tx, scanReceipt, query, id, groupID)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	if patch.Version != nil && *patch.Version != receipt.Version {
+		return nil, ErrVersionConflict
+	}
+	receipts := []models.Receipt{*receipt}
+	if err := attachLineItems(ctx, tx, receipts); err != nil {
+		return nil, err
+	}
+	receipt = &receipts[0]
+
+	if err := patch.Apply(receipt); err != nil {
+		return nil, err
+	}
+
+	if receipt.Splits == nil {
+		receipt.Splits = []models.ReceiptSplit{}
+	}
+	query = `UPDATE receipts SET date = $3, total_cents = $4, currency = $5, purchased_by_id = $6, items = $7, splits = $8, notes = $9
+		WHERE id = $1 AND group_id = $2 RETURNING updated_at, change_seq, version`
+	err = tx.QueryRow(ctx, query, id, groupID, receipt.Date, receipt.TotalAmount, receipt.Currency, receipt.PurchasedByID, receipt.Items, storedSplits(receipt.Splits), receipt.Notes).
+		Scan(&receipt.UpdatedAt, &receipt.ChangeSeq, &receipt.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update receipt: %w", err)
+	}
+	if patch.Items != nil || patch.LineItems != nil {
+		if err := replaceLineItems(ctx, tx, receipt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+func (s *PostgresStore) GetReceiptByID(ctx context.Context, id, groupID string) (*models.Receipt, error) {
+	query := `SELECT ` + receiptColumns + ` FROM receipts WHERE id = $1 AND group_id = $2`
+	receipt, err := queryOne(ctx, s.pool, scanReceipt, query, id, groupID)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	receipts := []models.Receipt{*receipt}
+	if err := attachLineItems(ctx, s.pool, receipts); err != nil {
+		return nil, err
+	}
+	return &receipts[0], nil
+}
+
+func (s *PostgresStore) DeleteReceipt(ctx context.Context, id, groupID string, expectedVersion *int64) error {
+	return s.deleteVersioned(ctx, "receipts", id, groupID, expectedVersion, ErrReceiptNotFound)
+}
+
+// insertPurchases records that receipt bought the grocery items.
+func insertPurchases(ctx context.Context, q querier, receipt models.Receipt, items []models.GroceryItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	ids := make([]string, len(items))
+	itemIDs := make([]string, len(items))
+	times := make([]time.Time, len(items))
+	now := time.Now()
+	for i, item := range items {
+		purchase := models.NewGroceryPurchase(item.ID, receipt, now)
+		ids[i], itemIDs[i], times[i] = purchase.ID, purchase.GroceryItemID, purchase.PurchasedAt
+	}
+	query := `INSERT INTO grocery_purchases (id, grocery_item_id, receipt_id, member_id, purchased_at, group_id)
+		SELECT id, grocery_item_id, $4, $5, purchased_at, $6
+		FROM unnest($1::text[], $2::text[], $3::timestamptz[]) AS p(id, grocery_item_id, purchased_at)`
+	if _, err := q.Exec(ctx, query, ids, itemIDs, times, receipt.ID, receipt.PurchasedByID, receipt.GroupID); err != nil {
+		return fmt.Errorf("failed to record purchases: %w", err)
+	}
+	return nil
+}
+
+// Purchases
+
+const purchaseColumns = `id, grocery_item_id, receipt_id, member_id, purchased_at, group_id`
+
+func scanPurchase(row pgx.Row) (models.GroceryPurchase, error) {
+	var purchase models.GroceryPurchase
+	err := row.Scan(&purchase.ID, &purchase.GroceryItemID, &purchase.ReceiptID, &purchase.MemberID, &purchase.PurchasedAt, &purchase.GroupID)
+	return purchase, err
+}
+
+func (s *PostgresStore) GetGroceryPurchases(ctx context.Context, groupID string, since time.Time) ([]models.GroceryPurchase, error) {
+	query := `SELECT ` + purchaseColumns + ` FROM grocery_purchases WHERE group_id = $1 AND purchased_at >= $2 ORDER BY purchased_at`
+	purchases, err := queryAll(ctx, s.pool, scanPurchase, query, groupID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchases: %w", err)
+	}
+	return purchases, nil
+}
+
+// Settlements
+
+func (s *PostgresStore) GetAllSettlements(ctx context.Context, groupID string) ([]models.Settlement, error) {
+	query := `SELECT ` + settlementColumns + ` FROM settlements WHERE group_id = $1 ORDER BY date DESC`
+	settlements, err := queryAll(ctx, s.pool, scanSettlement, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settlements: %w", err)
+	}
+	return settlements, nil
+}
+
+func (s *PostgresStore) GetSettlementByID(ctx context.Context, id, groupID string) (*models.Settlement, error) {
+	query := `SELECT ` + settlementColumns + ` FROM settlements WHERE id = $1 AND group_id = $2`
+	return queryOne(ctx, s.pool, scanSettlement, query, id, groupID)
+}
+
+func (s *PostgresStore) CreateSettlement(ctx context.Context, settlement *models.Settlement) error {
+	query := `INSERT INTO settlements (id, date, paid_by_id, paid_to_id, amount_cents, currency, notes, group_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING updated_at, change_seq, version`
+	err := s.pool.QueryRow(ctx, query, settlement.ID, settlement.Date, settlement.PaidByID, settlement.PaidToID, settlement.Amount, settlement.Currency, settlement.Notes, settlement.GroupID).
+		Scan(&settlement.UpdatedAt, &settlement.ChangeSeq, &settlement.Version)
+	if err != nil {
+		return fmt.Errorf("failed to create settlement: %w", err)
+	}
+	return nil
+}
+
+// UpdateSettlement applies the patch in Go, like UpdateReceipt, so that the
+// payer and payee can be checked against each other.
+func (s *PostgresStore) UpdateSettlement(ctx context.Context, id, groupID string, patch models.SettlementPatch) (*models.Settlement, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT ` + settlementColumns + ` FROM settlements WHERE id = $1 AND group_id = $2 FOR UPDATE`
+	settlement, err := queryOne(ctx, tx, scanSettlement, query, id, groupID)
+	if err != nil || settlement == nil {
+		return nil, err
+	}
+	if patch.Version != nil && *patch.Version != settlement.Version {
+		return nil, ErrVersionConflict
+	}
+	if err := patch.Apply(settlement); err != nil {
+		return nil, err
+	}
+
+	query = `UPDATE settlements SET date = $3, paid_by_id = $4, paid_to_id = $5, amount_cents = $6, currency = $7, notes = $8
+		WHERE id = $1 AND group_id = $2 RETURNING updated_at, change_seq, version`
+	err = tx.QueryRow(ctx, query, id, groupID, settlement.Date, settlement.PaidByID, settlement.PaidToID, settlement.Amount, settlement.Currency, settlement.Notes).
+		Scan(&settlement.UpdatedAt, &settlement.ChangeSeq, &settlement.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update settlement: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return settlement, nil
+}
+
+func (s *PostgresStore) DeleteSettlement(ctx context.Context, id, groupID string, expectedVersion *int64) error {
+	return s.deleteVersioned(ctx, "settlements", id, groupID, expectedVersion, ErrSettlementNotFound)
+}
+
+// deleteVersioned deletes a group-scoped row, failing with ErrVersionConflict
+// if expectedVersion is set and stale, or notFound if the row doesn't exist.
+// table must be one of the entity tables, never user input.
+func (s *PostgresStore) deleteVersioned(ctx context.Context, table, id, groupID string, expectedVersion *int64, notFound error) error {
+	query := "DELETE FROM " + table + " WHERE id = $1 AND group_id = $2 AND ($3::bigint IS NULL OR version = $3)"
+	tag, err := s.pool.Exec(ctx, query, id, groupID, expectedVersion)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	if expectedVersion != nil {
+		if err := s.conflictIfExists(ctx, table, id, groupID); err != nil {
+			return err
+		}
+	}
+	return notFound
+}
+
+// conflictIfExists is called after a version guarded statement matched no
+// rows. It returns ErrVersionConflict if the row exists (so the version was
+// the mismatch) and nil if it doesn't.
+func (s *PostgresStore) conflictIfExists(ctx context.Context, table, id, groupID string) error {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM " + table + " WHERE id = $1 AND group_id = $2)"
+	if err := s.pool.QueryRow(ctx, query, id, groupID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// Categories
+
+func (s *PostgresStore) GetCategories(ctx context.Context, groupID string) ([]models.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM group_categories WHERE group_id = $1 ORDER BY sort_order, name`
+	categories, err := queryAll(ctx, s.pool, scanCategory, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	return categories, nil
+}
+
+func (s *PostgresStore) GetCategoryByID(ctx context.Context, id, groupID string) (*models.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM group_categories WHERE id = $1 AND group_id = $2`
+	return queryOne(ctx, s.pool, scanCategory, query, id, groupID)
+}
+
+func (s *PostgresStore) CreateCategory(ctx context.Context, category *models.Category) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	group, err := lockGroup(ctx, tx, category.GroupID)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return ErrGroupNotFound
+	}
+	if _, taken := group.CanonicalCategory(category.Name); taken {
+		return models.FieldErrors{"name": "is already one of the group's categories"}
+	}
+
+	if err := insertCategory(ctx, tx, category); err != nil {
+		if isUniqueViolation(err, "group_categories_group_name_idx") {
+			return models.FieldErrors{"name": "is already one of the group's categories"}
+		}
+		return err
+	}
+	if err := bumpGroupVersion(ctx, tx, category.GroupID); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) UpdateCategory(ctx context.Context, id, groupID string, patch models.CategoryPatch) (*models.Category, []models.GroceryItem, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	group, err := lockGroup(ctx, tx, groupID)
+	if err != nil || group == nil {
+		return nil, nil, err
+	}
+	category, err := queryOne(ctx, tx, scanCategory, `SELECT `+categoryColumns+` FROM group_categories WHERE id = $1 AND group_id = $2`, id, groupID)
+	if err != nil || category == nil {
+		return nil, nil, err
+	}
+	if patch.Version != nil && *patch.Version != category.Version {
+		return nil, nil, ErrVersionConflict
+	}
+	if patch.Name != nil {
+		if existing, taken := group.CanonicalCategory(*patch.Name); taken && existing != category.Name {
+			return nil, nil, models.FieldErrors{"name": "is already one of the group's categories"}
+		}
+	}
+
+	previous := *category
+	patch.Apply(category)
+	query := `UPDATE group_categories SET name = $3, sort_order = $4, color = $5, icon = $6
+		WHERE id = $1 AND group_id = $2 RETURNING updated_at, change_seq, version`
+	err = tx.QueryRow(ctx, query, id, groupID, category.Name, category.SortOrder, category.Color, category.Icon).
+		Scan(&category.UpdatedAt, &category.ChangeSeq, &category.Version)
+	if isUniqueViolation(err, "group_categories_group_name_idx") {
+		return nil, nil, models.FieldErrors{"name": "is already one of the group's categories"}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update category: %w", err)
+	}
+
+	renamedItems := []models.GroceryItem{}
+	if category.Name != previous.Name {
+		if renamedItems, err = touchCategoryItems(ctx, tx, id); err != nil {
+			return nil, nil, err
+		}
+	}
+	if category.Name != previous.Name || category.SortOrder != previous.SortOrder {
+		if err := bumpGroupVersion(ctx, tx, groupID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return category, renamedItems, nil
+}
+
+func (s *PostgresStore) DeleteCategory(ctx context.Context, id, groupID string, expectedVersion *int64, policy models.CategoryPolicy) ([]models.GroceryItem, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	group, err := lockGroup(ctx, tx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	category, err := queryOne(ctx, tx, scanCategory, `SELECT `+categoryColumns+` FROM group_categories WHERE id = $1 AND group_id = $2`, id, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil || category == nil {
+		return nil, ErrCategoryNotFound
+	}
+	if expectedVersion != nil && *expectedVersion != category.Version {
+		return nil, ErrVersionConflict
+	}
+
+	used, err := queryAll(ctx, tx, scanName, `SELECT DISTINCT c.name FROM grocery_items i
+		JOIN group_categories c ON c.id = i.category_id WHERE i.group_id = $1`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query item categories: %w", err)
+	}
+	changes, err := models.DeleteCategoryChanges(*group, category.Name, policy, used)
+	if err != nil {
+		return nil, err
+	}
+	movedItems, err := applyCategoryChanges(ctx, tx, groupID, changes)
+	if err != nil {
+		return nil, err
+	}
+	if err := bumpGroupVersion(ctx, tx, groupID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return movedItems, nil
+}
+
+// Members
+
+func (s *PostgresStore) GetMembers(ctx context.Context, groupID string) ([]models.Member, error) {
+	query := `SELECT ` + memberColumns + ` FROM group_members WHERE group_id = $1`
+	members, err := queryAll(ctx, s.pool, scanMember, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %w", err)
+	}
+	models.SortMembers(members)
+	return members, nil
+}
+
+func (s *PostgresStore) GetMemberByID(ctx context.Context, id, groupID string) (*models.Member, error) {
+	query := `SELECT ` + memberColumns + ` FROM group_members WHERE id = $1 AND group_id = $2`
+	return queryOne(ctx, s.pool, scanMember, query, id, groupID)
+}
+
+func (s *PostgresStore) CreateMember(ctx context.Context, member *models.Member) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	group, err := lockGroup(ctx, tx, member.GroupID)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return ErrGroupNotFound
+	}
+	members, err := queryAll(ctx, tx, scanMember, `SELECT `+memberColumns+` FROM group_members WHERE group_id = $1`, member.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to query members: %w", err)
+	}
+	if !member.Archived && models.NameTaken(members, member.DisplayName, member.ID) {
+		return models.FieldErrors{"displayName": "is already the name of a member of the group"}
+	}
+
+	if err := insertMember(ctx, tx, member); err != nil {
+		return err
+	}
+	if !member.Archived {
+		if err := bumpGroupVersion(ctx, tx, member.GroupID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) UpdateMember(ctx context.Context, id, groupID string, patch models.MemberPatch) (*models.Member, []models.Receipt, []models.Settlement, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	group, err := lockGroup(ctx, tx, groupID)
+	if err != nil || group == nil {
+		return nil, nil, nil, err
+	}
+	members, err := queryAll(ctx, tx, scanMember, `SELECT `+memberColumns+` FROM group_members WHERE group_id = $1`, groupID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to query members: %w", err)
+	}
+	member := models.FindMember(members, id)
+	if member == nil {
+		return nil, nil, nil, nil
+	}
+	if patch.Version != nil && *patch.Version != member.Version {
+		return nil, nil, nil, ErrVersionConflict
+	}
+
+	previous := *member
+	patch.Apply(member)
+	if !member.Archived && models.NameTaken(members, member.DisplayName, id) {
+		return nil, nil, nil, models.FieldErrors{"displayName": "is already the name of a member of the group"}
+	}
+	query := `UPDATE group_members SET display_name = $3, color = $4, archived = $5, sort_order = $6
+		WHERE id = $1 AND group_id = $2 RETURNING updated_at, change_seq, version`
+	err = tx.QueryRow(ctx, query, id, groupID, member.DisplayName, member.Color, member.Archived, member.SortOrder).
+		Scan(&member.UpdatedAt, &member.ChangeSeq, &member.Version)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update member: %w", err)
+	}
+
+	receipts, settlements := []models.Receipt{}, []models.Settlement{}
+	if member.DisplayName != previous.DisplayName {
+		if receipts, settlements, err = touchMemberHistory(ctx, tx, id); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if member.ChangesMembersList(previous) {
+		if err := bumpGroupVersion(ctx, tx, groupID); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return member, receipts, settlements, nil
+}
+
+// applyMemberChanges brings the group's member rows in line with a members
+// list sent by an older client. See models.PlanMemberChanges.
+func applyMemberChanges(ctx context.Context, tx pgx.Tx, groupID string, names []string) error {
+	members, err := queryAll(ctx, tx, scanMember, `SELECT `+memberColumns+` FROM group_members WHERE group_id = $1`, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to query members: %w", err)
+	}
+	created, updated := models.PlanMemberChanges(members, names, groupID)
+
+	for _, member := range created {
+		if err := insertMember(ctx, tx, &member); err != nil {
+			return err
+		}
+	}
+	for _, member := range updated {
+		query := `UPDATE group_members SET display_name = $2, archived = $3, sort_order = $4 WHERE id = $1`
+		if _, err := tx.Exec(ctx, query, member.ID, member.DisplayName, member.Archived, member.SortOrder); err != nil {
+			return fmt.Errorf("failed to update member %s: %w", member.DisplayName, err)
+		}
+		if previous := models.FindMember(members, member.ID); previous.DisplayName != member.DisplayName {
+			if _, _, err := touchMemberHistory(ctx, tx, member.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// touchMemberHistory gives the receipts and settlements that reference a
+// renamed member a new change sequence, since the names on them changed.
+func touchMemberHistory(ctx context.Context, tx pgx.Tx, memberID string) ([]models.Receipt, []models.Settlement, error) {
+	query := `UPDATE receipts SET purchased_by_id = purchased_by_id
+		WHERE purchased_by_id = $1 OR splits @> jsonb_build_array(jsonb_build_object('memberId', $1::text))
+		RETURNING ` + receiptColumns
+	receipts, err := queryAll(ctx, tx, scanReceipt, query, memberID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update receipts of renamed member: %w", err)
+	}
+	if err := attachLineItems(ctx, tx, receipts); err != nil {
+		return nil, nil, err
+	}
+
+	query = `UPDATE settlements SET paid_by_id = paid_by_id WHERE paid_by_id = $1 OR paid_to_id = $1 RETURNING ` + settlementColumns
+	settlements, err := queryAll(ctx, tx, scanSettlement, query, memberID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update settlements of renamed member: %w", err)
+	}
+	return receipts, settlements, nil
+}
+
+func insertMember(ctx context.Context, q querier, member *models.Member) error {
+	query := `INSERT INTO group_members (id, display_name, color, archived, sort_order, group_id) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING updated_at, change_seq, version`
+	err := q.QueryRow(ctx, query, member.ID, member.DisplayName, member.Color, member.Archived, member.SortOrder, member.GroupID).
+		Scan(&member.UpdatedAt, &member.ChangeSeq, &member.Version)
+	if err != nil {
+		return fmt.Errorf("failed to create member %s: %w", member.DisplayName, err)
+	}
+	return nil
+}
+
+// Sync
+
+func scanTombstone(row pgx.Row) (models.Tombstone, error) {
+	var tombstone models.Tombstone
+	err := row.Scan(&tombstone.EntityType, &tombstone.ID, &tombstone.ChangeSeq, &tombstone.DeletedAt)
+	return tombstone, err
+}
+
+func (s *PostgresStore) GetChangesSince(ctx context.Context, groupID string, since int64) (*models.ChangeSet, error) {
+	// A repeatable read snapshot keeps the cursor consistent with the rows
+	// returned. Writers take the group row lock to draw a sequence number, so
+	// every sequence up to the committed last_change_seq is already visible.
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	changes := &models.ChangeSet{}
+	err = tx.QueryRow(ctx, `SELECT last_change_seq FROM groups WHERE id = $1`, groupID).Scan(&changes.Cursor)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read group change sequence: %w", err)
+	}
+
+	if since > changes.Cursor {
+		// The client has seen sequence numbers this database never handed out
+		// (e.g. after a restore), so its state can't be diffed against ours.
+		changes.Reset = true
+		since = 0
+	}
+
+	changes.GroceryItems, err = queryAll(ctx, tx, scanGroceryItem,
+		`SELECT `+groceryItemColumns+` FROM grocery_items WHERE group_id = $1 AND change_seq > $2 ORDER BY change_seq`, groupID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed grocery items: %w", err)
+	}
+	changes.MealPlans, err = queryAll(ctx, tx, scanMealPlan,
+		`SELECT `+mealPlanColumns+` FROM meal_plans WHERE group_id = $1 AND change_seq > $2 ORDER BY change_seq`, groupID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed meal plans: %w", err)
+	}
+	changes.Receipts, err = queryAll(ctx, tx, scanReceipt,
+		`SELECT `+receiptColumns+` FROM receipts WHERE group_id = $1 AND change_seq > $2 ORDER BY change_seq`, groupID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed receipts: %w", err)
+	}
+	if err := attachLineItems(ctx, tx, changes.Receipts); err != nil {
+		return nil, err
+	}
+	changes.Categories, err = queryAll(ctx, tx, scanCategory,
+		`SELECT `+categoryColumns+` FROM group_categories WHERE group_id = $1 AND change_seq > $2 ORDER BY change_seq`, groupID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed categories: %w", err)
+	}
+	changes.Members, err = queryAll(ctx, tx, scanMember,
+		`SELECT `+memberColumns+` FROM group_members WHERE group_id = $1 AND change_seq > $2 ORDER BY change_seq`, groupID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed members: %w", err)
+	}
+	changes.Settlements, err = queryAll(ctx, tx, scanSettlement,
+		`SELECT `+settlementColumns+` FROM settlements WHERE group_id = $1 AND change_seq > $2 ORDER BY change_seq`, groupID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed settlements: %w", err)
+	}
+
+	changes.Deleted = []models.Tombstone{}
+	if since > 0 {
+		changes.Deleted, err = queryAll(ctx, tx, scanTombstone,
+			`SELECT entity_type, entity_id, change_seq, deleted_at FROM sync_tombstones
+			WHERE group_id = $1 AND change_seq > $2 ORDER BY change_seq`, groupID, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query tombstones: %w", err)
+		}
+	}
+
+	return changes, nil
+}
+
+// Reports
+
+func (s *PostgresStore) GetSpendingTotals(ctx context.Context, groupID string, from, to *time.Time) ([]models.SpendingTotal, error) {
+	query := `SELECT date, purchased_by_id, currency, COUNT(*), SUM(total_cents)::bigint FROM receipts
+		WHERE group_id = $1 AND ($2::date IS NULL OR date >= $2) AND ($3::date IS NULL OR date <= $3)
+		GROUP BY date, purchased_by_id, currency ORDER BY date`
+	totals, err := queryAll(ctx, s.pool, func(row pgx.Row) (models.SpendingTotal, error) {
+		var total models.SpendingTotal
+		err := row.Scan(&total.Date, &total.PurchasedByID, &total.Currency, &total.Count, &total.Total)
+		return total, err
+	}, query, groupID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spending totals: %w", err)
+	}
+	return totals, nil
+}
+
+// Groups
+
+func (s *PostgresStore) CreateGroup(ctx context.Context, group *models.Group, admin *models.AccessToken) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO groups (id, name, currency, requires_token) VALUES ($1, $2, $3, $4) RETURNING version`
+	err = tx.QueryRow(ctx, query, group.ID, group.Name, group.Currency, group.RequiresToken).Scan(&group.Version)
+	if err != nil {
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+	for i, name := range group.Categories {
+		if err := insertCategory(ctx, tx, models.NewCategory(name, i, group.ID)); err != nil {
+			return err
+		}
+	}
+	for i, name := range group.Members {
+		if err := insertMember(ctx, tx, models.NewMember(name, i, group.ID)); err != nil {
+			return err
+		}
+	}
+	if err := insertAccessToken(ctx, tx, admin); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) GetGroupByID(ctx context.Context, id string) (*models.Group, error) {
+	query := `SELECT ` + groupColumns + ` FROM groups WHERE id = $1`
+	return queryOne(ctx, s.pool, scanGroup, query, id)
+}
+
+func (s *PostgresStore) UpdateGroup(ctx context.Context, id string, patch models.GroupPatch) (*models.Group, []models.GroceryItem, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := lockGroup(ctx, tx, id)
+	if err != nil || current == nil {
+		return nil, nil, err
+	}
+	if patch.Version != nil && *patch.Version != current.Version {
+		return nil, nil, ErrVersionConflict
+	}
+
+	movedItems := []models.GroceryItem{}
+	if patch.ChangesCategories() {
+		used, err := queryAll(ctx, tx, scanName, `SELECT DISTINCT c.name FROM grocery_items i
+			JOIN group_categories c ON c.id = i.category_id WHERE i.group_id = $1`, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to query item categories: %w", err)
+		}
+		changes, err := patch.Recategorize(*current, used)
+		if err != nil {
+			return nil, nil, err
+		}
+		if movedItems, err = applyCategoryChanges(ctx, tx, id, changes); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if patch.Members != nil {
+		if err := applyMemberChanges(ctx, tx, id, *patch.Members); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	set := newSetClause(id)
+	if patch.Name != nil {
+		set.add("name", *patch.Name)
+	}
+	if patch.Currency != nil {
+		set.add("currency", *patch.Currency)
+	}
+	if set.empty() && !patch.ChangesCategories() && patch.Members == nil {
+		return current, movedItems, nil
+	}
+
+	// Category and member changes show in the group's lists, so they count
+	// as a new version of the group too
+	assignments := "version = version + 1"
+	if !set.empty() {
+		assignments = set.String() + ", " + assignments
+	}
+	query := fmt.Sprintf("UPDATE groups SET %s WHERE id = $1 RETURNING %s", assignments, groupColumns)
+	group, err := queryOne(ctx, tx, scanGroup, query, set.args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return group, movedItems, nil
+}
+
+// lockGroup reads the group and locks it until the transaction ends. Every
+// change to a group's categories holds this lock.
+func lockGroup(ctx context.Context, tx pgx.Tx, id string) (*models.Group, error) {
+	return queryOne(ctx, tx, scanGroup, `SELECT `+groupColumns+` FROM groups WHERE id = $1 FOR UPDATE`, id)
+}
+
+// bumpGroupVersion marks a change to the group's categories or members list.
+func bumpGroupVersion(ctx context.Context, tx pgx.Tx, id string) error {
+	if _, err := tx.Exec(ctx, `UPDATE groups SET version = version + 1 WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to update group version: %w", err)
+	}
+	return nil
+}
+
+func scanName(row pgx.Row) (string, error) {
+	var name string
+	err := row.Scan(&name)
+	return name, err
+}
+
+// applyCategoryChanges brings the group's category rows in line with
+// changes: kept and renamed categories keep their IDs and get their new
+// position, new names are inserted, and removed categories are deleted after
+// their items are moved. It returns the grocery items whose category changed.
+func applyCategoryChanges(ctx context.Context, tx pgx.Tx, groupID string, changes *models.CategoryChanges) ([]models.GroceryItem, error) {
+	rows, err := queryAll(ctx, tx, scanCategory, `SELECT `+categoryColumns+` FROM group_categories WHERE group_id = $1`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	plan := models.PlanCategoryChanges(rows, changes, groupID)
+
+	changedItems := []models.GroceryItem{}
+	for _, category := range plan.Created {
+		if err := insertCategory(ctx, tx, &category); err != nil {
+			return nil, err
+		}
+	}
+	for _, category := range plan.Updated {
+		_, err := tx.Exec(ctx, `UPDATE group_categories SET name = $2, sort_order = $3 WHERE id = $1`, category.ID, category.Name, category.SortOrder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update category %s: %w", category.Name, err)
+		}
+	}
+	for _, id := range plan.Renamed {
+		items, err := touchCategoryItems(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		changedItems = append(changedItems, items...)
+	}
+	for from, to := range plan.Moved {
+		query := `UPDATE grocery_items SET category_id = $2 WHERE category_id = $1 RETURNING ` + groceryItemColumns
+		items, err := queryAll(ctx, tx, scanGroceryItem, query, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move grocery items: %w", err)
+		}
+		changedItems = append(changedItems, items...)
+	}
+	if len(plan.Deleted) > 0 {
+		if _, err := tx.Exec(ctx, `DELETE FROM group_categories WHERE id = ANY($1)`, plan.Deleted); err != nil {
+			return nil, fmt.Errorf("failed to delete categories: %w", err)
+		}
+	}
+	return changedItems, nil
+}
+
+// touchCategoryItems gives the items in a renamed category a new change
+// sequence, since their category name changed with it.
+func touchCategoryItems(ctx context.Context, tx pgx.Tx, categoryID string) ([]models.GroceryItem, error) {
+	query := `UPDATE grocery_items SET category_id = category_id WHERE category_id = $1 RETURNING ` + groceryItemColumns
+	items, err := queryAll(ctx, tx, scanGroceryItem, query, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update grocery items of renamed category: %w", err)
+	}
+	return items, nil
+}
+
+func insertCategory(ctx context.Context, q querier, category *models.Category) error {
+	query := `INSERT INTO group_categories (id, name, sort_order, color, icon, group_id) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING updated_at, change_seq, version`
+	err := q.QueryRow(ctx, query, category.ID, category.Name, category.SortOrder, category.Color, category.Icon, category.GroupID).
+		Scan(&category.UpdatedAt, &category.ChangeSeq, &category.Version)
+	if err != nil {
+		return fmt.Errorf("failed to create category %s: %w", category.Name, err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteGroup(ctx context.Context, groupID string, expectedVersion *int64) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var version int64
+	err = tx.QueryRow(ctx, `SELECT version FROM groups WHERE id = $1 FOR UPDATE`, groupID).Scan(&version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrGroupNotFound
+		}
+		return fmt.Errorf("failed to lock group: %w", err)
+	}
+	if expectedVersion != nil && *expectedVersion != version {
+		return ErrVersionConflict
+	}
+
+	// TODO: i think delete cascades automatically. can we remove this?
+	queries := []string{
+		`DELETE FROM grocery_purchases WHERE group_id = $1`,
+		`DELETE FROM grocery_items WHERE group_id = $1`,
+		`DELETE FROM group_categories WHERE group_id = $1`,
+		`DELETE FROM meal_plans WHERE group_id = $1`,
+		`DELETE FROM receipts WHERE group_id = $1`,
+		`DELETE FROM settlements WHERE group_id = $1`,
+		`DELETE FROM group_members WHERE group_id = $1`,
+		`DELETE FROM group_invites WHERE group_id = $1`,
+		`DELETE FROM group_access_tokens WHERE group_id = $1`,
+		`DELETE FROM groups WHERE id = $1`,
+	}
+
+	for i, query := range queries {
+		tag, err := tx.Exec(ctx, query, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to execute query %d: %w", i, err)
+		}
+		// If the last query (deleting the group) affects no rows, return group not found
+		if i == len(queries)-1 && tag.RowsAffected() == 0 {
+			return ErrGroupNotFound
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetGroupsFromID is a temporary migration helper that reads legacy user-group
+// memberships so old installs can recover their existing groups after auth removal.
+func (s *PostgresStore) GetGroupsFromID(ctx context.Context, id string) ([]string, error) {
+	query := `SELECT group_id FROM user_groups WHERE user_id = $1 ORDER BY group_id`
+	rows, err := s.pool.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []string{}
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			return nil, err
+		}
+		groups = append(groups, groupID)
+	}
+
+	return groups, rows.Err()
+}
+
+func (s *PostgresStore) GetLegacyMembers(ctx context.Context, groupID string) ([]string, error) {
+	query := `SELECT user_id FROM user_groups WHERE group_id = $1 ORDER BY user_id`
+	rows, err := s.pool.Query(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		users = append(users, userID)
+	}
+	return users, rows.Err()
+}
+
+// Idempotency keys
+
+func (s *PostgresStore) ReserveIdempotencyKey(ctx context.Context, groupID, key, requestHash string) (*IdempotencyRecord, error) {
+	// Expired keys are cleared first so they can be claimed again
+	_, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE group_id = $1 AND created_at < now() - make_interval(secs => $2)`,
+		groupID, IdempotencyKeyTTL.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO idempotency_keys (group_id, key, request_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (group_id, key) DO NOTHING`, groupID, key, requestHash)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	var record IdempotencyRecord
+	var statusCode *int
+	err = s.pool.QueryRow(ctx, `SELECT request_hash, status_code, response FROM idempotency_keys WHERE group_id = $1 AND key = $2`,
+		groupID, key).Scan(&record.RequestHash, &statusCode, &record.Response)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != nil {
+		record.StatusCode = *statusCode
+	}
+	return &record, nil
+}
+
+func (s *PostgresStore) CompleteIdempotencyKey(ctx context.Context, groupID, key string, statusCode int, response []byte) error {
+	_, err := s.pool.Exec(ctx, `UPDATE idempotency_keys SET status_code = $3, response = $4 WHERE group_id = $1 AND key = $2`,
+		groupID, key, statusCode, response)
+	return err
+}
+
+func (s *PostgresStore) ReleaseIdempotencyKey(ctx context.Context, groupID, key string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE group_id = $1 AND key = $2`, groupID, key)
+	return err
+}
+
+// Access tokens and invites
+
+func (s *PostgresStore) GetAccessToken(ctx context.Context, groupID, tokenHash string) (*models.AccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM group_access_tokens WHERE group_id = $1 AND token_hash = $2 AND revoked_at IS NULL`
+	return queryOne(ctx, s.pool, scanAccessToken, query, groupID, tokenHash)
+}
+
+func (s *PostgresStore) GetAccessTokens(ctx context.Context, groupID string) ([]models.AccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM group_access_tokens WHERE group_id = $1 AND revoked_at IS NULL ORDER BY created_at`
+	tokens, err := queryAll(ctx, s.pool, scanAccessToken, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query access tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *PostgresStore) CreateAccessToken(ctx context.Context, token *models.AccessToken) error {
+	return insertAccessToken(ctx, s.pool, token)
+}
+
+func insertAccessToken(ctx context.Context, q querier, token *models.AccessToken) error {
+	query := `INSERT INTO group_access_tokens (id, group_id, token_hash, device_name, is_admin, scope) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at`
+	err := q.QueryRow(ctx, query, token.ID, token.GroupID, token.TokenHash, token.DeviceName, token.Admin, token.Scope).Scan(&token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create access token: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) RevokeAccessToken(ctx context.Context, groupID, id string) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE group_access_tokens SET revoked_at = now() WHERE id = $1 AND group_id = $2 AND revoked_at IS NULL`, id, groupID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) ClaimGroup(ctx context.Context, token *models.AccessToken, claimCode string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var requiresToken bool
+	err = tx.QueryRow(ctx, `SELECT requires_token FROM groups WHERE id = $1 FOR UPDATE`, token.GroupID).Scan(&requiresToken)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrGroupNotFound
+		}
+		return fmt.Errorf("failed to lock group: %w", err)
+	}
+	if requiresToken {
+		return ErrGroupAlreadyClaimed
+	}
+	if claimCode != "" {
+		tag, err := tx.Exec(ctx, `UPDATE group_invites SET uses = uses + 1
+			WHERE code = $1 AND group_id = $2 AND revoked_at IS NULL AND expires_at > now() AND (max_uses IS NULL OR uses < max_uses)`,
+			claimCode, token.GroupID)
+		if err != nil {
+			return fmt.Errorf("failed to use claim code: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrInviteInvalid
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE groups SET requires_token = true WHERE id = $1`, token.GroupID); err != nil {
+		return fmt.Errorf("failed to claim group: %w", err)
+	}
+	if err := insertAccessToken(ctx, tx, token); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresStore) RotateGroupAccess(ctx context.Context, replacement *models.AccessToken) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	queries := []string{
+		`UPDATE group_access_tokens SET revoked_at = now() WHERE group_id = $1 AND revoked_at IS NULL`,
+		`UPDATE group_invites SET revoked_at = now() WHERE group_id = $1 AND revoked_at IS NULL`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(ctx, query, replacement.GroupID); err != nil {
+			return fmt.Errorf("failed to revoke group access: %w", err)
+		}
+	}
+	if err := insertAccessToken(ctx, tx, replacement); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresStore) GetInvites(ctx context.Context, groupID string) ([]models.Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM group_invites
+		WHERE group_id = $1 AND revoked_at IS NULL AND expires_at > now() AND (max_uses IS NULL OR uses < max_uses)
+		ORDER BY created_at`
+	invites, err := queryAll(ctx, s.pool, scanInvite, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invites: %w", err)
+	}
+	return invites, nil
+}
+
+func (s *PostgresStore) CreateInvite(ctx context.Context, invite *models.Invite) error {
+	query := `INSERT INTO group_invites (code, group_id, created_by, created_at, expires_at, max_uses) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := s.pool.Exec(ctx, query, invite.Code, invite.GroupID, invite.CreatedBy, invite.CreatedAt, invite.ExpiresAt, invite.MaxUses)
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) RevokeInvite(ctx context.Context, groupID, code string) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE group_invites SET revoked_at = now() WHERE code = $1 AND group_id = $2 AND revoked_at IS NULL`, code, groupID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) RedeemInvite(ctx context.Context, code string, token *models.AccessToken) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `UPDATE group_invites SET uses = uses + 1
+		WHERE code = $1 AND revoked_at IS NULL AND expires_at > now() AND (max_uses IS NULL OR uses < max_uses)
+			AND group_id IN (SELECT id FROM groups WHERE requires_token)
+		RETURNING group_id`, code).Scan(&token.GroupID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInviteInvalid
+		}
+		return fmt.Errorf("failed to redeem invite: %w", err)
+	}
+	if err := insertAccessToken(ctx, tx, token); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lebensmittel/backend/models"
+)
+
+// Errors returned by Delete operations when the row doesn't exist in the group.
+// Lookups return a nil entity and a nil error instead.
+var (
+	ErrGroceryItemNotFound = errors.New("grocery item not found")
+	ErrMealPlanNotFound    = errors.New("meal plan not found")
+	ErrReceiptNotFound     = errors.New("receipt not found")
+	ErrSettlementNotFound  = errors.New("settlement not found")
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrGroupNotFound       = errors.New("group not found")
+)
+
+// ErrGroceryItemExists is returned when creating a grocery item the group
+// already has under the same models.GroceryItemKey.
+var ErrGroceryItemExists = errors.New("grocery item already exists")
+
+// Errors returned by the access token and invite operations.
+var (
+	ErrAccessTokenNotFound = errors.New("access token not found")
+	ErrInviteNotFound      = errors.New("invite not found")
+	// ErrInviteInvalid is returned when redeeming an invite that doesn't
+	// exist, has expired, is used up or was revoked.
+	ErrInviteInvalid = errors.New("invite is invalid or expired")
+	// ErrGroupAlreadyClaimed is returned when claiming a group that already
+	// requires access tokens.
+	ErrGroupAlreadyClaimed = errors.New("group already requires an access token")
+)
+
+// ErrVersionConflict is returned by updates and deletes whose expected
+// version no longer matches the stored row.
+var ErrVersionConflict = errors.New("version conflict")
+
+// Store is the persistence layer used by the handlers. PostgresStore is the
+// production implementation; MemoryStore keeps everything in process.
+type Store interface {
+	GroceryItemStore
+	MealPlanStore
+	ReceiptStore
+	SettlementStore
+	PurchaseStore
+	CategoryStore
+	MemberStore
+	GroupStore
+	SyncStore
+	IdempotencyStore
+	ReportStore
+	AccessStore
+
+	Close()
+}
+
+// Updates and deletes take an optional expected version. When it is set and
+// doesn't match the stored row they fail with ErrVersionConflict; patches carry
+// it in their Version field.
+
+// GroceryItemStore persists a group's grocery items.
+type GroceryItemStore interface {
+	GetAllGroceryItems(ctx context.Context, groupID string) ([]models.GroceryItem, error)
+	GetGroceryItemByID(ctx context.Context, id, groupID string) (*models.GroceryItem, error)
+	CreateGroceryItem(ctx context.Context, item *models.GroceryItem) error
+	UpdateGroceryItem(ctx context.Context, id, groupID string, patch models.GroceryItemPatch) (*models.GroceryItem, error)
+	DeleteGroceryItem(ctx context.Context, id, groupID string, expectedVersion *int64) error
+	// RenewRecurringGroceryItems marks the recurring items that came due by
+	// now as needed, in every group, and moves them on to their next due
+	// date. It returns the items it changed. Items that were needed already
+	// only move on to their next due date, keeping their version, and aren't
+	// returned.
+	RenewRecurringGroceryItems(ctx context.Context, now time.Time) ([]models.GroceryItem, error)
+}
+
+// MealPlanStore persists a group's meal plans.
+type MealPlanStore interface {
+	GetAllMealPlans(ctx context.Context, groupID string) ([]models.MealPlan, error)
+	GetMealPlanByID(ctx context.Context, id, groupID string) (*models.MealPlan, error)
+	CreateMealPlan(ctx context.Context, meal *models.MealPlan) error
+	UpdateMealPlan(ctx context.Context, id, groupID string, patch models.MealPlanPatch) (*models.MealPlan, error)
+	DeleteMealPlan(ctx context.Context, id, groupID string, expectedVersion *int64) error
+}
+
+// ReceiptStore persists a group's receipts.
+type ReceiptStore interface {
+	GetAllReceipts(ctx context.Context, groupID string) ([]models.Receipt, error)
+	GetReceiptByID(ctx context.Context, id, groupID string) (*models.Receipt, error)
+	// CreateReceipt inserts the receipt and marks the checked grocery items it
+	// lists as bought, recording a purchase of each. It returns those items.
+	CreateReceipt(ctx context.Context, receipt *models.Receipt) ([]models.GroceryItem, error)
+	UpdateReceipt(ctx context.Context, id, groupID string, patch models.ReceiptPatch) (*models.Receipt, error)
+	DeleteReceipt(ctx context.Context, id, groupID string, expectedVersion *int64) error
+}
+
+// PurchaseStore reads the grocery purchases receipts record.
+type PurchaseStore interface {
+	// GetGroceryPurchases returns the group's purchases since the given
+	// time, oldest first.
+	GetGroceryPurchases(ctx context.Context, groupID string, since time.Time) ([]models.GroceryPurchase, error)
+}
+
+// SettlementStore persists a group's settle-up payments.
+type SettlementStore interface {
+	GetAllSettlements(ctx context.Context, groupID string) ([]models.Settlement, error)
+	GetSettlementByID(ctx context.Context, id, groupID string) (*models.Settlement, error)
+	CreateSettlement(ctx context.Context, settlement *models.Settlement) error
+	UpdateSettlement(ctx context.Context, id, groupID string, patch models.SettlementPatch) (*models.Settlement, error)
+	DeleteSettlement(ctx context.Context, id, groupID string, expectedVersion *int64) error
+}
+
+// CategoryStore persists a group's grocery categories. Their names and order
+// make up the group's categories list, so changing either also bumps the
+// group's version. Operations that would leave two categories with the same
+// name, or grocery items without a category, fail with models.FieldErrors.
+type CategoryStore interface {
+	// GetCategories returns the group's categories in sort order.
+	GetCategories(ctx context.Context, groupID string) ([]models.Category, error)
+	GetCategoryByID(ctx context.Context, id, groupID string) (*models.Category, error)
+	CreateCategory(ctx context.Context, category *models.Category) error
+	// UpdateCategory also returns the category's grocery items if it was
+	// renamed, since their category name changed with it.
+	UpdateCategory(ctx context.Context, id, groupID string, patch models.CategoryPatch) (*models.Category, []models.GroceryItem, error)
+	// DeleteCategory handles the category's grocery items according to
+	// policy, like removing it from the group's categories list would, and
+	// returns the items it moved.
+	DeleteCategory(ctx context.Context, id, groupID string, expectedVersion *int64, policy models.CategoryPolicy) ([]models.GroceryItem, error)
+}
+
+// MemberStore persists a group's members. The display names and order of the
+// active ones make up the group's members list, so changing those also bumps
+// the group's version. Members are archived rather than deleted, since
+// receipts and settlements reference them. Operations that would give two
+// active members the same name fail with models.FieldErrors.
+type MemberStore interface {
+	// GetMembers returns all of the group's members, archived ones last.
+	GetMembers(ctx context.Context, groupID string) ([]models.Member, error)
+	GetMemberByID(ctx context.Context, id, groupID string) (*models.Member, error)
+	CreateMember(ctx context.Context, member *models.Member) error
+	// UpdateMember also returns the receipts and settlements that reference
+	// the member if it was renamed, since the names on them changed with it.
+	UpdateMember(ctx context.Context, id, groupID string, patch models.MemberPatch) (*models.Member, []models.Receipt, []models.Settlement, error)
+}
+
+// SyncStore answers delta sync requests.
+type SyncStore interface {
+	// GetChangesSince returns the group's entities with a change sequence
+	// greater than since, plus tombstones for entities deleted after it. It
+	// returns nil if the group doesn't exist.
+	GetChangesSince(ctx context.Context, groupID string, since int64) (*models.ChangeSet, error)
+}
+
+// ReportStore aggregates a group's receipts for spending reports.
+type ReportStore interface {
+	// GetSpendingTotals sums the group's receipts per date, purchaser and
+	// currency. from and to are optional and inclusive.
+	GetSpendingTotals(ctx context.Context, groupID string, from, to *time.Time) ([]models.SpendingTotal, error)
+}
+
+// IdempotencyKeyTTL is how long a group's Idempotency-Key is remembered.
+// After that the key may be reused for a new request.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyRecord is the stored outcome of a request that carried an
+// Idempotency-Key. StatusCode is 0 while the request is still in flight.
+type IdempotencyRecord struct {
+	RequestHash string
+	StatusCode  int
+	Response    []byte
+}
+
+// IdempotencyStore remembers the responses to create requests that carried an
+// Idempotency-Key.
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims key for a new request and returns nil. If
+	// the key was already claimed, it returns the existing record instead.
+	ReserveIdempotencyKey(ctx context.Context, groupID, key, requestHash string) (*IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response for a reserved key.
+	CompleteIdempotencyKey(ctx context.Context, groupID, key string, statusCode int, response []byte) error
+	// ReleaseIdempotencyKey forgets a reserved key, e.g. because the request
+	// failed and may be retried.
+	ReleaseIdempotencyKey(ctx context.Context, groupID, key string) error
+}
+
+// AccessStore persists the access tokens devices use to reach a group and the
+// invite codes that hand them out. Revoked tokens and invites are kept but
+// never returned.
+type AccessStore interface {
+	// GetAccessToken returns the group's unrevoked token with tokenHash, or nil.
+	GetAccessToken(ctx context.Context, groupID, tokenHash string) (*models.AccessToken, error)
+	GetAccessTokens(ctx context.Context, groupID string) ([]models.AccessToken, error)
+	CreateAccessToken(ctx context.Context, token *models.AccessToken) error
+	RevokeAccessToken(ctx context.Context, groupID, id string) error
+	// ClaimGroup stores the first token of a group that doesn't require one
+	// yet and makes the group require it from now on. A non-empty claimCode
+	// must be a usable invite of the group, which is used up; otherwise it
+	// returns ErrInviteInvalid.
+	ClaimGroup(ctx context.Context, token *models.AccessToken, claimCode string) error
+	// RotateGroupAccess revokes every token and invite of the group and stores
+	// replacement as its only token.
+	RotateGroupAccess(ctx context.Context, replacement *models.AccessToken) error
+
+	// GetInvites returns the group's invites that can still be redeemed.
+	GetInvites(ctx context.Context, groupID string) ([]models.Invite, error)
+	CreateInvite(ctx context.Context, invite *models.Invite) error
+	RevokeInvite(ctx context.Context, groupID, code string) error
+	// RedeemInvite uses up one use of the invite and stores token for the
+	// invite's group, filling in token.GroupID. Invites of groups that don't
+	// require a token yet are claim codes, which only ClaimGroup accepts.
+	RedeemInvite(ctx context.Context, code string, token *models.AccessToken) error
+}
+
+// GroupStore persists groups.
+type GroupStore interface {
+	// CreateGroup stores a new group together with admin, its first access
+	// token, so a group is never left without a way in.
+	CreateGroup(ctx context.Context, group *models.Group, admin *models.AccessToken) error
+	GetGroupByID(ctx context.Context, id string) (*models.Group, error)
+	// UpdateGroup also moves grocery items out of categories the patch
+	// removes, following its CategoryPolicy, and returns the moved items. A
+	// policy that refuses the change fails with models.FieldErrors. Members
+	// left out of a new members list are archived.
+	UpdateGroup(ctx context.Context, id string, patch models.GroupPatch) (*models.Group, []models.GroceryItem, error)
+	// DeleteGroup removes the group together with everything that belongs to it.
+	DeleteGroup(ctx context.Context, groupID string, expectedVersion *int64) error
+	// GetGroupsFromID reads legacy user-group memberships from before auth removal.
+	GetGroupsFromID(ctx context.Context, id string) ([]string, error)
+	// GetLegacyMembers returns the legacy user IDs with a membership in the
+	// group, the other way round from GetGroupsFromID.
+	GetLegacyMembers(ctx context.Context, groupID string) ([]string, error)
+}
+
+var (
+	_ Store = (*PostgresStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
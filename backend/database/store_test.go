@@ -0,0 +1,233 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lebensmittel/backend/models"
+)
+
+// forEachStore runs test against a MemoryStore and, if TEST_DATABASE_URL is
+// set, a PostgresStore, so both behave the same way.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("postgres", func(t *testing.T) {
+		test(t, NewPostgresStore(testPool(t)))
+	})
+}
+
+// createTestGroup stores a new group, which starts out with the default
+// categories.
+func createTestGroup(t *testing.T, store Store) *models.Group {
+	t.Helper()
+	group := models.NewGroup("Test")
+	admin, _ := models.NewAccessToken(group.ID, "Test", true)
+	if err := store.CreateGroup(context.Background(), group, admin); err != nil {
+		t.Fatal(err)
+	}
+	return group
+}
+
+// createTestItem stores a needed grocery item in the group's first category.
+func createTestItem(t *testing.T, store Store, groupID, name string) *models.GroceryItem {
+	t.Helper()
+	categories, err := store.GetCategories(context.Background(), groupID)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("group has categories %v: %v", categories, err)
+	}
+	item := models.NewGroceryItem(name, categories[0], true, false, groupID)
+	if err := store.CreateGroceryItem(context.Background(), item); err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func TestStoreGroceryItems(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		group := createTestGroup(t, store)
+		other := createTestGroup(t, store)
+		item := createTestItem(t, store, group.ID, "Milk")
+		if item.Version != 1 || item.Category == "" {
+			t.Errorf("created %+v, want version 1 with its category name", item)
+		}
+
+		stored, err := store.GetGroceryItemByID(ctx, item.ID, group.ID)
+		if err != nil || stored == nil || stored.Name != "Milk" {
+			t.Fatalf("GetGroceryItemByID = %+v, %v", stored, err)
+		}
+		if stored, err := store.GetGroceryItemByID(ctx, item.ID, other.ID); stored != nil || err != nil {
+			t.Errorf("another group read the item: %+v, %v", stored, err)
+		}
+
+		checked, needed := true, false
+		version := int64(1)
+		updated, err := store.UpdateGroceryItem(ctx, item.ID, group.ID, models.GroceryItemPatch{IsShoppingChecked: &checked, Version: &version})
+		if err != nil || updated == nil || !updated.IsShoppingChecked || updated.Name != "Milk" || updated.Version != 2 {
+			t.Fatalf("UpdateGroceryItem = %+v, %v; want it checked at version 2", updated, err)
+		}
+		if _, err := store.UpdateGroceryItem(ctx, item.ID, group.ID, models.GroceryItemPatch{IsNeeded: &needed, Version: &version}); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("updating an old version = %v, want ErrVersionConflict", err)
+		}
+		if updated, err := store.UpdateGroceryItem(ctx, item.ID, other.ID, models.GroceryItemPatch{IsNeeded: &needed}); updated != nil || err != nil {
+			t.Errorf("another group updated the item: %+v, %v", updated, err)
+		}
+
+		items, err := store.GetAllGroceryItems(ctx, group.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 || items[0].ID != item.ID || !items[0].IsShoppingChecked || items[0].Version != 2 {
+			t.Errorf("GetAllGroceryItems = %+v, want the checked item at version 2", items)
+		}
+
+		if err := store.DeleteGroceryItem(ctx, item.ID, group.ID, &version); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("deleting an old version = %v, want ErrVersionConflict", err)
+		}
+		if err := store.DeleteGroceryItem(ctx, item.ID, other.ID, nil); !errors.Is(err, ErrGroceryItemNotFound) {
+			t.Errorf("deleting from another group = %v, want ErrGroceryItemNotFound", err)
+		}
+		if err := store.DeleteGroceryItem(ctx, item.ID, group.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+		if stored, err := store.GetGroceryItemByID(ctx, item.ID, group.ID); stored != nil || err != nil {
+			t.Errorf("deleted item is still there: %+v, %v", stored, err)
+		}
+	})
+}
+
+func TestStoreChangesSince(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		group := createTestGroup(t, store)
+		first := createTestItem(t, store, group.ID, "Milk")
+		second := createTestItem(t, store, group.ID, "Bread")
+
+		changes, err := store.GetChangesSince(ctx, group.ID, first.ChangeSeq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes.GroceryItems) != 1 || changes.GroceryItems[0].ID != second.ID || changes.Cursor != second.ChangeSeq {
+			t.Errorf("changes after the first item = %+v, want the second up to %d", changes, second.ChangeSeq)
+		}
+
+		if err := store.DeleteGroceryItem(ctx, first.ID, group.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+		changes, err = store.GetChangesSince(ctx, group.ID, second.ChangeSeq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes.GroceryItems) != 0 || len(changes.Deleted) != 1 || changes.Deleted[0].ID != first.ID || changes.Deleted[0].EntityType != models.EntityGroceryItem {
+			t.Errorf("changes after deleting = %+v, want a tombstone of %s", changes, first.ID)
+		}
+
+		if changes, err := store.GetChangesSince(ctx, "missing", 0); changes != nil || err != nil {
+			t.Errorf("changes of a missing group = %+v, %v", changes, err)
+		}
+	})
+}
+
+func TestStoreClaimGroup(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		group := models.NewGroup("Old")
+		group.RequiresToken = false
+		unused, _ := models.NewAccessToken(group.ID, "unused", true)
+		if err := store.CreateGroup(ctx, group, unused); err != nil {
+			t.Fatal(err)
+		}
+		claimer, _ := models.NewAccessToken(group.ID, "Phone", true)
+
+		missing, _ := models.NewAccessToken(models.NewGroup("Missing").ID, "Phone", true)
+		if err := store.ClaimGroup(ctx, missing, ""); !errors.Is(err, ErrGroupNotFound) {
+			t.Errorf("claiming a missing group = %v, want ErrGroupNotFound", err)
+		}
+		if err := store.ClaimGroup(ctx, claimer, "NOTACODE"); !errors.Is(err, ErrInviteInvalid) {
+			t.Errorf("claiming with a made-up code = %v, want ErrInviteInvalid", err)
+		}
+		maxUses := 1
+		code := models.NewInvite(group.ID, nil, models.DefaultInviteTTL, &maxUses)
+		if err := store.CreateInvite(ctx, code); err != nil {
+			t.Fatal(err)
+		}
+		redeemer, _ := models.NewAccessToken("", "Laptop", false)
+		if err := store.RedeemInvite(ctx, code.Code, redeemer); !errors.Is(err, ErrInviteInvalid) {
+			t.Errorf("redeeming a claim code = %v, want ErrInviteInvalid", err)
+		}
+
+		if err := store.ClaimGroup(ctx, claimer, code.Code); err != nil {
+			t.Fatal(err)
+		}
+		if claimed, err := store.GetGroupByID(ctx, group.ID); err != nil || !claimed.RequiresToken {
+			t.Errorf("claimed group = %+v, %v; want it to require a token", claimed, err)
+		}
+		again, _ := models.NewAccessToken(group.ID, "Tablet", true)
+		if err := store.ClaimGroup(ctx, again, ""); !errors.Is(err, ErrGroupAlreadyClaimed) {
+			t.Errorf("claiming twice = %v, want ErrGroupAlreadyClaimed", err)
+		}
+	})
+}
+
+func TestStoreCategoryNamesAreUnique(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		group := createTestGroup(t, store)
+
+		var rejected models.FieldErrors
+		err := store.CreateCategory(ctx, models.NewCategory("essentials", 9, group.ID))
+		if !errors.As(err, &rejected) || rejected["name"] == "" {
+			t.Errorf("adding a category that differs in case = %v, want a name error", err)
+		}
+
+		snacks := models.NewCategory("Snacks", 9, group.ID)
+		if err := store.CreateCategory(ctx, snacks); err != nil {
+			t.Fatal(err)
+		}
+		name := "PROTEIN"
+		_, _, err = store.UpdateCategory(ctx, snacks.ID, group.ID, models.CategoryPatch{Name: &name})
+		if !errors.As(err, &rejected) || rejected["name"] == "" {
+			t.Errorf("renaming a category to another's name = %v, want a name error", err)
+		}
+	})
+}
+
+func TestStoreGroceryItemKeys(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		group := createTestGroup(t, store)
+		createTestItem(t, store, group.ID, "Oat milk")
+		rice := createTestItem(t, store, group.ID, "Rice")
+
+		categories, err := store.GetCategories(ctx, group.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		duplicate := models.NewGroceryItem("2x oat milk", categories[0], true, false, group.ID)
+		if err := store.CreateGroceryItem(ctx, duplicate); !errors.Is(err, ErrGroceryItemExists) {
+			t.Errorf("adding an item the list has = %v, want ErrGroceryItemExists", err)
+		}
+
+		var rejected models.FieldErrors
+		name := "Oat Milk 1L"
+		if _, err := store.UpdateGroceryItem(ctx, rice.ID, group.ID, models.GroceryItemPatch{Name: &name}); !errors.As(err, &rejected) {
+			t.Errorf("renaming an item to one the list has = %v, want a name error", err)
+		}
+		// Renaming an item to another spelling of itself is fine
+		name = "Rice 1kg"
+		if _, err := store.UpdateGroceryItem(ctx, rice.ID, group.ID, models.GroceryItemPatch{Name: &name}); err != nil {
+			t.Errorf("renaming an item to another amount = %v", err)
+		}
+
+		// Deleting the item frees its name
+		other := createTestGroup(t, store)
+		createTestItem(t, store, other.ID, "Rice")
+		if err := store.DeleteGroceryItem(ctx, rice.ID, group.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+		createTestItem(t, store, group.ID, "rice")
+	})
+}
@@ -0,0 +1,507 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lebensmittel/backend/database"
+	"github.com/lebensmittel/backend/models"
+	"github.com/lebensmittel/backend/websocket"
+)
+
+// groupAccessKey is the gin context key RequireGroupAccess stores the
+// request's access token under.
+const groupAccessKey = "groupAccess"
+
+// groupWithToken is the response to every request that hands out an access
+// token: the group as usual plus the token's secret, which is never shown again.
+type groupWithToken struct {
+	*models.Group
+	AccessToken   string `json:"accessToken"`
+	AccessTokenID string `json:"accessTokenId"`
+}
+
+// RequireGroupAccess rejects requests without a valid access token for the
+// group ResolveGroup loaded, and must run after it. The token is sent as
+// `Authorization: Bearer <token>`. Groups that haven't been claimed since
+// access tokens were introduced are let through without one.
+func (h *Handler) RequireGroupAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := requestedGroup(c).ID
+
+		token, ok, err := h.authorize(c.Request.Context(), groupID, bearerToken(c))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "A valid access token for this group is required"})
+			return
+		}
+
+		c.Set(groupAccessKey, token)
+		c.Next()
+	}
+}
+
+// RequireGroupAdmin rejects requests whose access token isn't an admin token.
+// It must run after RequireGroupAccess.
+func (h *Handler) RequireGroupAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := requestAccess(c); token != nil && !token.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only group admins can do this"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireFullAccess rejects requests made with a share link. It must run
+// after RequireGroupAccess.
+func (h *Handler) RequireFullAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requestAccess(c).FullAccess() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Share links can't do this"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AuthorizeGroup reports whether token grants access to the group. It is used
+// to check websocket subscriptions, which only receive the events the
+// token's scope allows.
+func (h *Handler) AuthorizeGroup(ctx context.Context, groupID, token string) (websocket.Grant, bool) {
+	access, ok, err := h.authorize(ctx, groupID, token)
+	if err != nil {
+		log.Printf("Failed to authorize access to group %s: %v", groupID, err)
+		return websocket.Grant{}, false
+	}
+	if !ok || access == nil {
+		return websocket.Grant{}, ok
+	}
+	return websocket.Grant{TokenID: access.ID, Allows: access.Scope.AllowsEvent}, true
+}
+
+// authorize looks up the access token with the given secret. A missing secret
+// is only accepted for existing groups that don't require a token yet, in
+// which case the returned token is nil.
+func (h *Handler) authorize(ctx context.Context, groupID, secret string) (*models.AccessToken, bool, error) {
+	if secret != "" {
+		token, err := h.store.GetAccessToken(ctx, groupID, models.HashAccessToken(secret))
+		return token, token != nil, err
+	}
+	// Not the cached group: another instance may have just claimed it
+	group, err := h.store.GetGroupByID(ctx, groupID)
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, group != nil && !group.RequiresToken, nil
+}
+
+func bearerToken(c *gin.Context) string {
+	scheme, token, _ := strings.Cut(strings.TrimSpace(c.GetHeader("Authorization")), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// requestAccess returns the access token RequireGroupAccess accepted, or nil
+// for an unclaimed group.
+func requestAccess(c *gin.Context) *models.AccessToken {
+	token, _ := c.MustGet(groupAccessKey).(*models.AccessToken)
+	return token
+}
+
+// requireToken is requestAccess for endpoints that manage access, which an
+// unclaimed group has to be claimed for first. It writes a 409 and returns
+// false for unclaimed groups.
+func requireToken(c *gin.Context) (*models.AccessToken, bool) {
+	token := requestAccess(c)
+	if token == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The group has to be claimed before managing access"})
+		return nil, false
+	}
+	return token, true
+}
+
+// ClaimGroup hands the first admin token of a group from before access tokens
+// to the requesting device. From then on the group requires a token.
+//
+// Knowing the group ID isn't enough to claim it. Groups with legacy
+// memberships can be claimed with the user ID of one of them. Any group can
+// be claimed with a claim code the server operator creates with `backend
+// claim-code`; groups without legacy memberships need one.
+func (h *Handler) ClaimGroup(c *gin.Context) {
+	groupID := c.Param("group_id")
+
+	var data struct {
+		DeviceName string `json:"deviceName"`
+		// UserID is the legacy user ID stored on the device, if any
+		UserID string `json:"userId"`
+		// ClaimCode is a code from the server operator
+		ClaimCode string `json:"claimCode"`
+	}
+	if !bindOptionalJSON(c, &data) {
+		return
+	}
+
+	claimCode := models.NormalizeInviteCode(strings.TrimSpace(data.ClaimCode))
+	if claimCode == "" {
+		legacyMembers, err := h.store.GetLegacyMembers(c.Request.Context(), groupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(legacyMembers) == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This group can only be claimed with a claim code from the server operator"})
+			return
+		}
+		if !slices.Contains(legacyMembers, strings.TrimSpace(data.UserID)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "userId must be a legacy member of the group to claim it"})
+			return
+		}
+	}
+
+	token, secret := models.NewAccessToken(groupID, data.DeviceName, true)
+	if err := h.store.ClaimGroup(c.Request.Context(), token, claimCode); err != nil {
+		if errors.Is(err, database.ErrGroupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		} else if errors.Is(err, database.ErrInviteInvalid) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Claim code is invalid or has expired"})
+		} else if errors.Is(err, database.ErrGroupAlreadyClaimed) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Group has already been claimed; ask a member for an invite code"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// The group requires a token from now on, so subscriptions made without
+	// one end
+	h.groups.evict(groupID)
+	websocket.RevokeAccess(groupID, "")
+
+	h.respondWithToken(c, http.StatusCreated, groupID, token, secret)
+}
+
+// GetShareLinks lists the group's share links.
+func (h *Handler) GetShareLinks(c *gin.Context) {
+	groupID := c.Param("group_id")
+
+	if _, ok := requireToken(c); !ok {
+		return
+	}
+
+	tokens, err := h.store.GetAccessTokens(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	links := []models.AccessToken{}
+	for _, token := range tokens {
+		if token.Scope.IsShareScope() {
+			links = append(links, token)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shareLinks": links,
+		"count":      len(links),
+	})
+}
+
+// CreateShareLink creates a read-only or shopping-only token for the group.
+// Like invites, any device with full access may share the group.
+func (h *Handler) CreateShareLink(c *gin.Context) {
+	groupID := c.Param("group_id")
+
+	var data struct {
+		Scope models.Scope `json:"scope" binding:"required"`
+		Name  string       `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope is required"})
+		return
+	}
+	if !data.Scope.IsShareScope() {
+		respondRejectedFields(c, models.FieldErrors{"scope": "must be read or shopping"})
+		return
+	}
+
+	if _, ok := requireToken(c); !ok {
+		return
+	}
+
+	token, secret := models.NewScopedAccessToken(groupID, data.Name, false, data.Scope)
+	if err := h.store.CreateAccessToken(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"shareLink":   token,
+		"accessToken": secret,
+	})
+}
+
+// RevokeShareLink revokes one of the group's share links. Like creating them,
+// any device with full access may. Device tokens are revoked through
+// RevokeAccessToken instead.
+func (h *Handler) RevokeShareLink(c *gin.Context) {
+	groupID := c.Param("group_id")
+	tokenID := c.Param("token_id")
+
+	if _, ok := requireToken(c); !ok {
+		return
+	}
+
+	tokens, err := h.store.GetAccessTokens(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	isLink := slices.ContainsFunc(tokens, func(token models.AccessToken) bool {
+		return token.ID == tokenID && token.Scope.IsShareScope()
+	})
+	if !isLink {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	if err := h.store.RevokeAccessToken(c.Request.Context(), groupID, tokenID); err != nil {
+		if errors.Is(err, database.ErrAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	websocket.RevokeAccess(groupID, tokenID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked successfully"})
+}
+
+// RotateGroupAccess revokes every access token and invite of the group and
+// gives the requesting admin a fresh token. Other devices have to join again.
+func (h *Handler) RotateGroupAccess(c *gin.Context) {
+	groupID := c.Param("group_id")
+
+	current, ok := requireToken(c)
+	if !ok {
+		return
+	}
+
+	token, secret := models.NewAccessToken(groupID, current.DeviceName, true)
+	if err := h.store.RotateGroupAccess(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	websocket.RevokeAccess(groupID)
+
+	h.respondWithToken(c, http.StatusOK, groupID, token, secret)
+}
+
+func (h *Handler) GetAccessTokens(c *gin.Context) {
+	groupID := c.Param("group_id")
+
+	current, ok := requireToken(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.store.GetAccessTokens(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accessTokens":   tokens,
+		"count":          len(tokens),
+		"currentTokenId": current.ID,
+	})
+}
+
+// RevokeAccessToken signs a device out of the group. Admins can revoke any
+// token, other devices only their own.
+func (h *Handler) RevokeAccessToken(c *gin.Context) {
+	groupID := c.Param("group_id")
+	tokenID := c.Param("token_id")
+
+	current, ok := requireToken(c)
+	if !ok {
+		return
+	}
+	if !current.Admin && current.ID != tokenID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only group admins can do this"})
+		return
+	}
+
+	tokens, err := h.store.GetAccessTokens(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	admins, revokingAdmin := 0, false
+	for _, token := range tokens {
+		if token.Admin {
+			admins++
+			revokingAdmin = revokingAdmin || token.ID == tokenID
+		}
+	}
+	if revokingAdmin && admins == 1 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot revoke the group's last admin token; rotate access instead"})
+		return
+	}
+
+	if err := h.store.RevokeAccessToken(c.Request.Context(), groupID, tokenID); err != nil {
+		if errors.Is(err, database.ErrAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Access token not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	websocket.RevokeAccess(groupID, tokenID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access token revoked successfully"})
+}
+
+func (h *Handler) GetInvites(c *gin.Context) {
+	groupID := c.Param("group_id")
+
+	if _, ok := requireToken(c); !ok {
+		return
+	}
+
+	invites, err := h.store.GetInvites(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invites": invites,
+		"count":   len(invites),
+	})
+}
+
+// CreateInvite creates an invite code for the group. Any device with access
+// may invite others.
+func (h *Handler) CreateInvite(c *gin.Context) {
+	groupID := c.Param("group_id")
+
+	var data struct {
+		ExpiresInHours *int `json:"expiresInHours"`
+		MaxUses        *int `json:"maxUses"`
+	}
+	if !bindOptionalJSON(c, &data) {
+		return
+	}
+
+	current, ok := requireToken(c)
+	if !ok {
+		return
+	}
+
+	ttl := models.DefaultInviteTTL
+	rejected := models.FieldErrors{}
+	if data.ExpiresInHours != nil {
+		ttl = time.Duration(*data.ExpiresInHours) * time.Hour
+		if ttl <= 0 || ttl > models.MaxInviteTTL {
+			rejected["expiresInHours"] = "must be between 1 and 168"
+		}
+	}
+	if data.MaxUses != nil && *data.MaxUses < 1 {
+		rejected["maxUses"] = "must be positive"
+	}
+	if len(rejected) > 0 {
+		respondRejectedFields(c, rejected)
+		return
+	}
+
+	invite := models.NewInvite(groupID, &current.ID, ttl, data.MaxUses)
+	if err := h.store.CreateInvite(c.Request.Context(), invite); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+func (h *Handler) RevokeInvite(c *gin.Context) {
+	groupID := c.Param("group_id")
+	code := models.NormalizeInviteCode(c.Param("code"))
+
+	if _, ok := requireToken(c); !ok {
+		return
+	}
+
+	if err := h.store.RevokeInvite(c.Request.Context(), groupID, code); err != nil {
+		if errors.Is(err, database.ErrInviteNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked successfully"})
+}
+
+// RedeemInvite exchanges an invite code for an access token to its group.
+func (h *Handler) RedeemInvite(c *gin.Context) {
+	var data struct {
+		Code       string `json:"code" binding:"required"`
+		DeviceName string `json:"deviceName"`
+	}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	token, secret := models.NewAccessToken("", data.DeviceName, false)
+	if err := h.store.RedeemInvite(c.Request.Context(), models.NormalizeInviteCode(data.Code), token); err != nil {
+		if errors.Is(err, database.ErrInviteInvalid) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite code is invalid or has expired"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.respondWithToken(c, http.StatusCreated, token.GroupID, token, secret)
+}
+
+// bindOptionalJSON binds a request body that may also be left out. It writes a
+// 400 and returns false if the body isn't valid JSON.
+func bindOptionalJSON(c *gin.Context, dst any) bool {
+	if err := c.ShouldBindJSON(dst); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body must be a JSON object"})
+		return false
+	}
+	return true
+}
+
+// respondWithToken writes the group together with a newly issued token.
+func (h *Handler) respondWithToken(c *gin.Context, status int, groupID string, token *models.AccessToken, secret string) {
+	group, ok := h.requestGroup(c, groupID)
+	if !ok {
+		return
+	}
+	setETag(c, group.Version)
+	c.JSON(status, groupWithToken{Group: group, AccessToken: secret, AccessTokenID: token.ID})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/lebensmittel/backend/models"
+)
+
+// legacyGroup stores a group from before access tokens.
+func (s *testServer) legacyGroup(id string) {
+	s.t.Helper()
+	unused, _ := models.NewAccessToken(id, "unused", true)
+	if err := s.store.CreateGroup(context.Background(), &models.Group{ID: id, Name: "Old", Currency: "EUR"}, unused); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func TestClaimGroupWithoutLegacyMembers(t *testing.T) {
+	s := newTestServer(t)
+	s.legacyGroup("old")
+	claim := func(body map[string]any) int {
+		return s.request(http.MethodPost, "/api/groups/old/claim", body).Code
+	}
+
+	// Knowing the ID isn't enough
+	if status := claim(map[string]any{"deviceName": "Phone"}); status != http.StatusForbidden {
+		t.Errorf("claim without a code = %d, want 403", status)
+	}
+	if status := claim(map[string]any{"userId": "someone"}); status != http.StatusForbidden {
+		t.Errorf("claim with an unknown user = %d, want 403", status)
+	}
+	if status := claim(map[string]any{"claimCode": "ABCD-2345"}); status != http.StatusForbidden {
+		t.Errorf("claim with a made-up code = %d, want 403", status)
+	}
+
+	// Codes of other groups don't count
+	maxUses := 1
+	other := models.NewInvite(s.groupID, nil, models.DefaultInviteTTL, &maxUses)
+	if err := s.store.CreateInvite(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+	if status := claim(map[string]any{"claimCode": other.Code}); status != http.StatusForbidden {
+		t.Errorf("claim with another group's code = %d, want 403", status)
+	}
+
+	code := models.NewInvite("old", nil, models.DefaultInviteTTL, &maxUses)
+	if err := s.store.CreateInvite(context.Background(), code); err != nil {
+		t.Fatal(err)
+	}
+	// Claim codes aren't invites into the group
+	if rec := s.request(http.MethodPost, "/api/invites/redeem", map[string]any{"code": code.Code}); rec.Code != http.StatusNotFound {
+		t.Errorf("redeeming a claim code as an invite = %d, want 404", rec.Code)
+	}
+
+	var claimed struct {
+		AccessToken string `json:"accessToken"`
+	}
+	s.decode(s.request(http.MethodPost, "/api/groups/old/claim", map[string]any{"claimCode": code.Code}), http.StatusCreated, &claimed)
+	if claimed.AccessToken == "" {
+		t.Error("claiming returned no access token")
+	}
+	if status := claim(map[string]any{"claimCode": code.Code}); status != http.StatusConflict {
+		t.Errorf("claiming twice = %d, want 409", status)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lebensmittel/backend/models"
+	"github.com/lebensmittel/backend/websocket"
+)
+
+// GetBalances returns every member's balance from the group's receipts and
+// settlements, and the transfers that would settle them.
+func (h *Handler) GetBalances(c *gin.Context) {
+	groupID := requestedGroup(c).ID
+
+	balances, err := h.computeBalances(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if balances == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, balances)
+}
+
+// computeBalances returns nil if the group doesn't exist.
+func (h *Handler) computeBalances(ctx context.Context, groupID string) (*models.Balances, error) {
+	group, err := h.loadGroup(ctx, groupID)
+	if err != nil || group == nil {
+		return nil, err
+	}
+	members, err := h.store.GetMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := h.store.GetAllReceipts(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	settlements, err := h.store.GetAllSettlements(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return models.ComputeBalances(group, members, receipts, settlements), nil
+}
+
+// emitBalancesUpdated broadcasts the group's balances after a change to its
+// receipts, settlements or members. Failures are only logged because the
+// change itself has already been made.
+func (h *Handler) emitBalancesUpdated(ctx context.Context, groupID string) {
+	balances, err := h.computeBalances(context.WithoutCancel(ctx), groupID)
+	if err != nil {
+		log.Printf("Failed to compute balances for group %s: %v", groupID, err)
+		return
+	}
+	if balances != nil {
+		websocket.EmitEvent(ctx, websocket.EventBalancesUpdated, balances, groupID)
+	}
+}
+
+// requestGroup loads the group a request is for. It writes a 404 or 500 and
+// returns false if that fails.
+func (h *Handler) requestGroup(c *gin.Context, groupID string) (*models.Group, bool) {
+	group, err := h.store.GetGroupByID(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return nil, false
+	}
+	return group, true
+}
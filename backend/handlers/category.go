@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lebensmittel/backend/database"
+	"github.com/lebensmittel/backend/models"
+	"github.com/lebensmittel/backend/websocket"
+)
+
+func (h *Handler) GetCategories(c *gin.Context) {
+	groupID := requestedGroup(c).ID
+
+	categories, err := h.store.GetCategories(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if categories == nil { // ensure JSON never returns null
+		categories = []models.Category{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories": categories,
+		"count":      len(categories),
+	})
+}
+
+func (h *Handler) CreateCategory(c *gin.Context) {
+	var data struct {
+		Name      string  `json:"name" binding:"required"`
+		SortOrder *int    `json:"sortOrder"`
+		Color     *string `json:"color"`
+		Icon      *string `json:"icon"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	groupID := requestedGroup(c).ID
+
+	// New categories go at the end of the list unless the client says otherwise
+	sortOrder := 0
+	if data.SortOrder != nil {
+		sortOrder = *data.SortOrder
+	} else {
+		categories, err := h.store.GetCategories(c.Request.Context(), groupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, category := range categories {
+			sortOrder = max(sortOrder, category.SortOrder+1)
+		}
+	}
+
+	newCategory := models.NewCategory(data.Name, sortOrder, groupID)
+	newCategory.Color, newCategory.Icon = data.Color, data.Icon
+	if err := newCategory.Validate(); err != nil {
+		var rejected models.FieldErrors
+		errors.As(err, &rejected)
+		respondRejectedFields(c, rejected)
+		return
+	}
+
+	err := h.store.CreateCategory(c.Request.Context(), newCategory)
+	var rejected models.FieldErrors
+	if errors.As(err, &rejected) {
+		respondRejectedFields(c, rejected)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventCategoryCreated, newCategory, groupID)
+	h.emitGroupUpdated(c.Request.Context(), groupID)
+
+	setETag(c, newCategory.Version)
+	c.JSON(http.StatusCreated, newCategory)
+}
+
+func (h *Handler) UpdateCategory(c *gin.Context) {
+	categoryID := c.Param("category_id")
+
+	var patch models.CategoryPatch
+	if !bindPatch(c, &patch) {
+		return
+	}
+
+	groupID := requestedGroup(c).ID
+
+	var err error
+	if patch.Version, err = expectedVersion(c, patch.Version); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, renamedItems, err := h.store.UpdateCategory(c.Request.Context(), categoryID, groupID, patch)
+	if errors.Is(err, database.ErrVersionConflict) {
+		h.categoryConflict(c, categoryID, groupID)
+		return
+	}
+	var rejected models.FieldErrors
+	if errors.As(err, &rejected) {
+		respondRejectedFields(c, rejected)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if category == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventCategoryUpdated, category, groupID)
+	if patch.Name != nil || patch.SortOrder != nil {
+		h.emitGroupUpdated(c.Request.Context(), groupID)
+	}
+	if len(renamedItems) > 0 {
+		websocket.EmitEvent(c.Request.Context(), websocket.EventGroceryItemsUpdated, renamedItems, groupID)
+	}
+
+	setETag(c, category.Version)
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory removes a category from the group. Its grocery items move to
+// FallbackCategory, or with ?categoryPolicy=reject the delete fails while the
+// category still has items.
+func (h *Handler) DeleteCategory(c *gin.Context) {
+	categoryID := c.Param("category_id")
+
+	groupID := requestedGroup(c).ID
+
+	version, err := deleteVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := models.CategoryPolicy(c.DefaultQuery("categoryPolicy", string(models.CategoryPolicyOther)))
+	if policy != models.CategoryPolicyOther && policy != models.CategoryPolicyReject {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "categoryPolicy must be other or reject"})
+		return
+	}
+
+	movedItems, err := h.store.DeleteCategory(c.Request.Context(), categoryID, groupID, version, policy)
+	if err != nil {
+		var rejected models.FieldErrors
+		if errors.Is(err, database.ErrCategoryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		} else if errors.Is(err, database.ErrVersionConflict) {
+			h.categoryConflict(c, categoryID, groupID)
+		} else if errors.As(err, &rejected) {
+			respondRejectedFields(c, rejected)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventCategoryDeleted, websocket.DeletedPayload{ID: categoryID, Version: version}, groupID)
+	h.emitGroupUpdated(c.Request.Context(), groupID)
+	if len(movedItems) > 0 {
+		websocket.EmitEvent(c.Request.Context(), websocket.EventGroceryItemsUpdated, movedItems, groupID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// categoryConflict responds to a failed version precondition with the current category.
+func (h *Handler) categoryConflict(c *gin.Context, id, groupID string) {
+	current, err := h.store.GetCategoryByID(c.Request.Context(), id, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+	respondConflict(c, current, current.Version)
+}
+
+// emitGroupUpdated sends the group after a change to its categories list,
+// which also gives it a new version.
+func (h *Handler) emitGroupUpdated(ctx context.Context, groupID string) {
+	h.groups.evict(groupID)
+	group, err := h.loadGroup(ctx, groupID)
+	if err != nil || group == nil {
+		return
+	}
+	websocket.EmitEvent(ctx, websocket.EventGroupUpdated, group, groupID)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/lebensmittel/backend/models"
+)
+
+// testCategory is the part of a category the tests look at.
+type testCategory struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+}
+
+func (s *testServer) createCategory(name string) testCategory {
+	s.t.Helper()
+	var category testCategory
+	s.decode(s.request(http.MethodPost, "/api/categories", map[string]any{"name": name}), http.StatusCreated, &category)
+	return category
+}
+
+func (s *testServer) categoryNames() []string {
+	s.t.Helper()
+	var group struct {
+		Categories []string `json:"categories"`
+	}
+	s.decode(s.request(http.MethodGet, "/api/groups/"+s.groupID, nil), http.StatusOK, &group)
+	return group.Categories
+}
+
+func TestDeleteCategoryPolicies(t *testing.T) {
+	s := newTestServer(t)
+	snacks := s.createCategory("Snacks")
+	item := s.createItem("Crisps", "Snacks", nil)
+	if item.CategoryID != snacks.ID {
+		t.Fatalf("item is in category %s, want %s", item.CategoryID, snacks.ID)
+	}
+
+	s.decode(s.request(http.MethodDelete, "/api/categories/"+snacks.ID+"?categoryPolicy=rename", nil), http.StatusBadRequest, nil)
+
+	var response struct {
+		RejectedFields map[string]string `json:"rejectedFields"`
+	}
+	s.decode(s.request(http.MethodDelete, "/api/categories/"+snacks.ID+"?categoryPolicy=reject", nil), http.StatusBadRequest, &response)
+	if _, rejected := response.RejectedFields["categories"]; !rejected {
+		t.Errorf("rejected fields = %v, want categories", response.RejectedFields)
+	}
+	if current := s.items()[item.ID]; current.Category != "Snacks" || current.Version != 1 {
+		t.Errorf("rejected delete changed the item to %+v", current)
+	}
+
+	s.decode(s.request(http.MethodDelete, "/api/categories/"+snacks.ID, nil), http.StatusOK, nil)
+	moved := s.items()[item.ID]
+	if moved.Category != models.FallbackCategory || moved.CategoryID == snacks.ID || moved.Version != 2 {
+		t.Errorf("item after deleting its category = %+v, want it moved to %s at version 2", moved, models.FallbackCategory)
+	}
+	for _, name := range s.categoryNames() {
+		if name == "Snacks" {
+			t.Error("deleted category is still in the group's categories")
+		}
+	}
+}
+
+func TestDeleteUnusedCategoryWithRejectPolicy(t *testing.T) {
+	s := newTestServer(t)
+	empty := s.createCategory("Frozen")
+
+	s.decode(s.request(http.MethodDelete, "/api/categories/"+empty.ID+"?categoryPolicy=reject&version=2", nil), http.StatusConflict, nil)
+	s.decode(s.request(http.MethodDelete, "/api/categories/"+empty.ID+"?categoryPolicy=reject", nil), http.StatusOK, nil)
+	s.decode(s.request(http.MethodDelete, "/api/categories/"+empty.ID, nil), http.StatusNotFound, nil)
+}
+
+func TestUpdateGroupCategoryPolicies(t *testing.T) {
+	s := newTestServer(t)
+	item := s.createItem("Crisps", "Carbs", nil)
+	categories := []string{"Essentials", "Protein", "Veggies", "Snacks", "Household", "Other"}
+
+	// Rename pairs the removed Carbs with the added Snacks
+	s.decode(s.request(http.MethodPatch, "/api/groups/"+s.groupID, map[string]any{"categories": categories, "categoryPolicy": "rename"}), http.StatusOK, nil)
+	if renamed := s.items()[item.ID]; renamed.Category != "Snacks" {
+		t.Errorf("item after renaming its category is in %s, want Snacks", renamed.Category)
+	}
+
+	categories[3] = "Treats"
+	s.decode(s.request(http.MethodPatch, "/api/groups/"+s.groupID, map[string]any{"categories": categories, "categoryPolicy": "reject"}), http.StatusBadRequest, nil)
+	if kept := s.items()[item.ID]; kept.Category != "Snacks" {
+		t.Errorf("rejected change moved the item to %s", kept.Category)
+	}
+
+	s.decode(s.request(http.MethodPatch, "/api/groups/"+s.groupID, map[string]any{"categories": categories}), http.StatusOK, nil)
+	if moved := s.items()[item.ID]; moved.Category != models.FallbackCategory {
+		t.Errorf("item after removing its category is in %s, want %s", moved.Category, models.FallbackCategory)
+	}
+}
+
+func TestRenameCategory(t *testing.T) {
+	s := newTestServer(t)
+	item := s.createItem("Crisps", "Carbs", nil)
+	rename := func(from, to string) map[string]any {
+		return map[string]any{"renameCategory": map[string]any{"from": from, "to": to}}
+	}
+
+	var response struct {
+		RejectedFields map[string]string `json:"rejectedFields"`
+	}
+	s.decode(s.request(http.MethodPatch, "/api/groups/"+s.groupID, rename("Carbs", "protein")), http.StatusBadRequest, &response)
+	if _, rejected := response.RejectedFields["renameCategory.to"]; !rejected {
+		t.Errorf("rejected fields = %v, want renameCategory.to", response.RejectedFields)
+	}
+
+	s.decode(s.request(http.MethodPatch, "/api/groups/"+s.groupID, rename("carbs", "Snacks")), http.StatusOK, nil)
+	if renamed := s.items()[item.ID]; renamed.Category != "Snacks" {
+		t.Errorf("item after renaming its category is in %s, want Snacks", renamed.Category)
+	}
+}
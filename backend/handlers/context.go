@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lebensmittel/backend/models"
+)
+
+// requestGroupKey is the gin context key ResolveGroup stores the request's
+// group under.
+const requestGroupKey = "group"
+
+// groupCacheTTL is how long a looked up group is reused. Changes made through
+// this instance evict it right away, but other instances sharing the
+// database keep their copy until it expires, so access checks don't rely on
+// it.
+const groupCacheTTL = 5 * time.Second
+
+// groupCacheSweepSize is the number of cached groups past which expired
+// entries are dropped.
+const groupCacheSweepSize = 1024
+
+type cachedGroup struct {
+	group   *models.Group
+	expires time.Time
+}
+
+// groupCache remembers recently loaded groups for a short while, since every
+// request looks its group up at least once.
+type groupCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedGroup
+}
+
+func newGroupCache() *groupCache {
+	return &groupCache{entries: map[string]cachedGroup{}}
+}
+
+func (gc *groupCache) get(groupID string) *models.Group {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	entry, ok := gc.entries[groupID]
+	if !ok || time.Now().After(entry.expires) {
+		return nil
+	}
+	return entry.group
+}
+
+func (gc *groupCache) put(group *models.Group) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	now := time.Now()
+	if len(gc.entries) >= groupCacheSweepSize {
+		for id, entry := range gc.entries {
+			if now.After(entry.expires) {
+				delete(gc.entries, id)
+			}
+		}
+	}
+	gc.entries[group.ID] = cachedGroup{group: group, expires: now.Add(groupCacheTTL)}
+}
+
+func (gc *groupCache) evict(groupID string) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	delete(gc.entries, groupID)
+}
+
+// ResolveGroup loads the group named by the group_id path parameter or the
+// X-Group-ID header and stores it in the context for the handlers. Unknown
+// groups get a 404, so nothing is ever written to a group that doesn't exist.
+func (h *Handler) ResolveGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := strings.TrimSpace(c.Param("group_id"))
+		if groupID == "" {
+			var err error
+			if groupID, err = getRequestedGroupID(c); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		group, err := h.loadGroup(c.Request.Context(), groupID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if group == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+
+		c.Set(requestGroupKey, group)
+		c.Next()
+	}
+}
+
+// requestedGroup returns the group ResolveGroup loaded for the request. It is
+// shared with other requests and must not be modified.
+func requestedGroup(c *gin.Context) *models.Group {
+	return c.MustGet(requestGroupKey).(*models.Group)
+}
+
+// loadGroup returns the group with the given ID, from the cache if it was
+// looked up recently, or nil if it doesn't exist.
+func (h *Handler) loadGroup(ctx context.Context, groupID string) (*models.Group, error) {
+	if group := h.groups.get(groupID); group != nil {
+		return group, nil
+	}
+	group, err := h.store.GetGroupByID(ctx, groupID)
+	if err != nil || group == nil {
+		return nil, err
+	}
+	h.groups.put(group)
+	return group, nil
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lebensmittel/backend/database"
@@ -9,14 +13,10 @@ import (
 	"github.com/lebensmittel/backend/websocket"
 )
 
-func GetGroceryItems(c *gin.Context) {
-	groupID, err := getRequestedGroupID(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+func (h *Handler) GetGroceryItems(c *gin.Context) {
+	groupID := requestedGroup(c).ID
 
-	items, err := database.GetAllGroceryItems(c.Request.Context(), groupID)
+	items, err := h.store.GetAllGroceryItems(c.Request.Context(), groupID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -32,22 +32,28 @@ func GetGroceryItems(c *gin.Context) {
 	})
 }
 
-func CreateGroceryItem(c *gin.Context) {
+func (h *Handler) CreateGroceryItem(c *gin.Context) {
 	var data struct {
-		Name              string `json:"name" binding:"required"`
-		Category          string `json:"category" binding:"required"`
-		IsNeeded          *bool  `json:"isNeeded"`
-		IsShoppingChecked *bool  `json:"isShoppingChecked"`
+		Name              string             `json:"name" binding:"required"`
+		Category          *string            `json:"category"`
+		CategoryID        *string            `json:"categoryId"`
+		IsNeeded          *bool              `json:"isNeeded"`
+		IsShoppingChecked *bool              `json:"isShoppingChecked"`
+		Quantity          *float64           `json:"quantity"`
+		Unit              *models.Unit       `json:"unit"`
+		Note              *string            `json:"note"`
+		Recurrence        *models.Recurrence `json:"recurrence"`
 	}
 
-	if err := c.ShouldBindJSON(&data); err != nil {
+	if err := c.ShouldBindJSON(&data); err != nil || (data.Category == nil && data.CategoryID == nil) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and category are required"})
 		return
 	}
 
-	groupID, err := getRequestedGroupID(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	groupID := requestedGroup(c).ID
+
+	category := h.resolveCategory(c, groupID, data.CategoryID, data.Category)
+	if category == nil {
 		return
 	}
 
@@ -62,62 +68,126 @@ func CreateGroceryItem(c *gin.Context) {
 		isShoppingChecked = *data.IsShoppingChecked
 	}
 
-	newItem := models.NewGroceryItem(data.Name, data.Category, isNeeded, isShoppingChecked, groupID)
+	newItem := models.NewGroceryItem(data.Name, *category, isNeeded, isShoppingChecked, groupID)
+	newItem.Quantity, newItem.Unit, newItem.Note = data.Quantity, data.Unit, data.Note
+	newItem.Recurrence = data.Recurrence
+	if err := newItem.Validate(); err != nil {
+		var rejected models.FieldErrors
+		errors.As(err, &rejected)
+		respondRejectedFields(c, rejected)
+		return
+	}
+	newItem.NextDueAt = newItem.Recurrence.FirstDue(time.Now())
 
-	if err := database.CreateGroceryItem(c.Request.Context(), newItem); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Adding an item the list already has, maybe with another amount in its
+	// name, puts that item back on the list instead
+	existing, ok := h.findGroceryItem(c, groupID, newItem.Name)
+	if !ok {
+		return
+	}
+	if existing == nil {
+		err := h.store.CreateGroceryItem(c.Request.Context(), newItem)
+		if errors.Is(err, database.ErrGroceryItemExists) {
+			// Another request added it since the lookup
+			if existing, ok = h.findGroceryItem(c, groupID, newItem.Name); !ok {
+				return
+			}
+			if existing == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "The item was changed at the same time, try again"})
+				return
+			}
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if existing != nil {
+		h.readdGroceryItem(c, existing, newItem, data.IsShoppingChecked != nil)
 		return
 	}
 
 	// Emit websocket event
-	websocket.EmitEvent("grocery_item_created", newItem, groupID)
+	websocket.EmitEvent(c.Request.Context(), websocket.EventGroceryItemCreated, newItem, groupID)
 
+	setETag(c, newItem.Version)
 	c.JSON(http.StatusCreated, newItem)
 }
 
-func UpdateGroceryItem(c *gin.Context) {
+func (h *Handler) UpdateGroceryItem(c *gin.Context) {
 	itemID := c.Param("item_id")
 
-	var data map[string]any
-	if err := c.ShouldBindJSON(&data); err != nil || len(data) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No data provided"})
+	var patch models.GroceryItemPatch
+	if !bindPatch(c, &patch) {
 		return
 	}
 
-	groupID, err := getRequestedGroupID(c)
-	if err != nil {
+	groupID := requestedGroup(c).ID
+
+	var err error
+	if patch.Version, err = expectedVersion(c, patch.Version); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if patch.Category != nil || patch.CategoryID != nil {
+		category := h.resolveCategory(c, groupID, patch.CategoryID, patch.Category)
+		if category == nil {
+			return
+		}
+		patch.CategoryID, patch.Category = &category.ID, &category.Name
+	}
 
-	item, err := database.UpdateGroceryItem(c.Request.Context(), itemID, groupID, data)
-	if err != nil {
-		if item == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Grocery item not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Shopping links may check items off and nothing else
+	if access := requestAccess(c); !access.FullAccess() {
+		other := patch
+		other.IsShoppingChecked = nil
+		if access.Scope != models.ScopeShopping || !other.IsEmpty() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Share links can only check items off the shopping list"})
+			return
 		}
+	}
+
+	item, err := h.store.UpdateGroceryItem(c.Request.Context(), itemID, groupID, patch)
+	if errors.Is(err, database.ErrVersionConflict) {
+		h.groceryItemConflict(c, itemID, groupID)
+		return
+	}
+	var rejected models.FieldErrors
+	if errors.As(err, &rejected) {
+		respondRejectedFields(c, rejected)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if item == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grocery item not found"})
 		return
 	}
 
 	// Emit websocket event
-	websocket.EmitEvent("grocery_item_updated", item, item.GroupID)
+	websocket.EmitEvent(c.Request.Context(), websocket.EventGroceryItemUpdated, item, item.GroupID)
 
+	setETag(c, item.Version)
 	c.JSON(http.StatusOK, item)
 }
 
-func DeleteGroceryItem(c *gin.Context) {
+func (h *Handler) DeleteGroceryItem(c *gin.Context) {
 	itemID := c.Param("item_id")
 
-	groupID, err := getRequestedGroupID(c)
+	groupID := requestedGroup(c).ID
+
+	version, err := deleteVersion(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := database.DeleteGroceryItem(c.Request.Context(), itemID, groupID); err != nil {
-		if err.Error() == "grocery item not found" {
+	if err := h.store.DeleteGroceryItem(c.Request.Context(), itemID, groupID, version); err != nil {
+		if errors.Is(err, database.ErrGroceryItemNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Grocery item not found"})
+		} else if errors.Is(err, database.ErrVersionConflict) {
+			h.groceryItemConflict(c, itemID, groupID)
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
@@ -125,7 +195,137 @@ func DeleteGroceryItem(c *gin.Context) {
 	}
 
 	// Emit websocket event
-	websocket.EmitEvent("grocery_item_deleted", gin.H{"id": itemID}, groupID)
+	websocket.EmitEvent(c.Request.Context(), websocket.EventGroceryItemDeleted, websocket.DeletedPayload{ID: itemID, Version: version}, groupID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Grocery item deleted successfully"})
 }
+
+// maxSuggestions is the most suggestions a client may ask for.
+const maxSuggestions = 50
+
+// GetGrocerySuggestions returns the items that aren't on the list but will
+// likely be needed soon, going by how often they are usually bought. `limit`
+// caps how many are returned, 10 by default.
+func (h *Handler) GetGrocerySuggestions(c *gin.Context) {
+	groupID := requestedGroup(c).ID
+
+	limit := 10
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSuggestions {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number from 1 to 50"})
+			return
+		}
+		limit = parsed
+	}
+
+	items, err := h.store.GetAllGroceryItems(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	purchases, err := h.store.GetGroceryPurchases(c.Request.Context(), groupID, now.Add(-models.SuggestionHistory))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	suggestions := models.SuggestGroceryItems(items, purchases, now)
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+	})
+}
+
+// findGroceryItem returns the group's item that name refers to, or nil. It
+// writes an error and returns false if the items can't be loaded.
+func (h *Handler) findGroceryItem(c *gin.Context, groupID, name string) (*models.GroceryItem, bool) {
+	items, err := h.store.GetAllGroceryItems(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return models.FindGroceryItem(items, name), true
+}
+
+// readdGroceryItem marks an existing item as needed again in place of
+// creating added, a duplicate of it. The category, quantity, unit, note and
+// recurrence sent along replace the item's own.
+func (h *Handler) readdGroceryItem(c *gin.Context, existing, added *models.GroceryItem, setChecked bool) {
+	patch := models.GroceryItemPatch{
+		IsNeeded:   &added.IsNeeded,
+		CategoryID: &added.CategoryID,
+		Category:   &added.Category,
+	}
+	if setChecked {
+		patch.IsShoppingChecked = &added.IsShoppingChecked
+	}
+	if added.Quantity != nil || added.Unit != nil {
+		patch.Quantity = &models.Nullable[float64]{Value: added.Quantity}
+		patch.Unit = &models.Nullable[models.Unit]{Value: added.Unit}
+	}
+	if added.Note != nil {
+		patch.Note = &models.Nullable[string]{Value: added.Note}
+	}
+	if added.Recurrence != nil {
+		patch.Recurrence = &models.Nullable[models.Recurrence]{Value: added.Recurrence}
+	}
+
+	item, err := h.store.UpdateGroceryItem(c.Request.Context(), existing.ID, existing.GroupID, patch)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if item == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grocery item not found"})
+		return
+	}
+
+	// Emit websocket event
+	websocket.EmitEvent(c.Request.Context(), websocket.EventGroceryItemUpdated, item, item.GroupID)
+
+	setETag(c, item.Version)
+	c.JSON(http.StatusOK, item)
+}
+
+// groceryItemConflict responds to a failed version precondition with the current grocery item.
+func (h *Handler) groceryItemConflict(c *gin.Context, id, groupID string) {
+	current, err := h.store.GetGroceryItemByID(c.Request.Context(), id, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grocery item not found"})
+		return
+	}
+	respondConflict(c, current, current.Version)
+}
+
+// resolveCategory looks up the category a grocery item goes in, by ID if one
+// is given and otherwise by name in any case. It writes an error and returns
+// nil if the group has no such category.
+func (h *Handler) resolveCategory(c *gin.Context, groupID string, id, name *string) *models.Category {
+	categories, err := h.store.GetCategories(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	for i, category := range categories {
+		if id != nil && category.ID == *id || id == nil && strings.EqualFold(category.Name, strings.TrimSpace(*name)) {
+			return &categories[i]
+		}
+	}
+
+	field := "category"
+	if id != nil {
+		field = "categoryId"
+	}
+	respondRejectedFields(c, models.FieldErrors{field: "must be one of the group's categories"})
+	return nil
+}
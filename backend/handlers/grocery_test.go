@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestUpdateGroceryItemVersions(t *testing.T) {
+	s := newTestServer(t)
+	item := s.createItem("Oat milk", "Essentials", nil)
+	if item.Version != 1 {
+		t.Fatalf("new item has version %d, want 1", item.Version)
+	}
+
+	rec := s.request(http.MethodPatch, "/api/grocery-items/"+item.ID, map[string]any{"isNeeded": false}, "If-Match", `"1"`)
+	var updated testItem
+	s.decode(rec, http.StatusOK, &updated)
+	if updated.Version != 2 || updated.IsNeeded {
+		t.Errorf("updated item = %+v, want version 2 and not needed", updated)
+	}
+	if updated.ChangeSeq <= item.ChangeSeq {
+		t.Errorf("change seq went from %d to %d, want it to grow", item.ChangeSeq, updated.ChangeSeq)
+	}
+	if etag := rec.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("ETag = %s, want \"2\"", etag)
+	}
+
+	// A client still holding version 1 gets the current item back
+	var conflict struct {
+		Current testItem `json:"current"`
+	}
+	s.decode(s.request(http.MethodPatch, "/api/grocery-items/"+item.ID, map[string]any{"isNeeded": true}, "If-Match", `"1"`), http.StatusConflict, &conflict)
+	if conflict.Current.Version != 2 || conflict.Current.IsNeeded {
+		t.Errorf("conflict returned %+v, want the item at version 2", conflict.Current)
+	}
+
+	// The version field of the body works like If-Match
+	s.decode(s.request(http.MethodPatch, "/api/grocery-items/"+item.ID, map[string]any{"isNeeded": true, "version": 1}), http.StatusConflict, nil)
+	s.decode(s.request(http.MethodPatch, "/api/grocery-items/"+item.ID, map[string]any{"isNeeded": true, "version": 2}), http.StatusOK, nil)
+
+	s.decode(s.request(http.MethodDelete, "/api/grocery-items/"+item.ID+"?version=2", nil), http.StatusConflict, nil)
+	s.decode(s.request(http.MethodDelete, "/api/grocery-items/"+item.ID+"?version=3", nil), http.StatusOK, nil)
+	if _, exists := s.items()[item.ID]; exists {
+		t.Error("deleted item is still listed")
+	}
+}
+
+func TestUpdateGroceryItemRejectsUnknownFields(t *testing.T) {
+	s := newTestServer(t)
+	item := s.createItem("Oat milk", "Essentials", nil)
+
+	var response struct {
+		RejectedFields map[string]string `json:"rejectedFields"`
+	}
+	s.decode(s.request(http.MethodPatch, "/api/grocery-items/"+item.ID, map[string]any{"isNeeded": false, "groupId": "other"}), http.StatusBadRequest, &response)
+	if _, rejected := response.RejectedFields["groupId"]; !rejected || len(response.RejectedFields) != 1 {
+		t.Errorf("rejected fields = %v, want only groupId", response.RejectedFields)
+	}
+	if current := s.items()[item.ID]; current.Version != 1 || !current.IsNeeded {
+		t.Errorf("item changed to %+v by a rejected patch", current)
+	}
+}
+
+func TestCreateGroceryItemReaddsExistingItem(t *testing.T) {
+	s := newTestServer(t)
+	item := s.createItem("Oat milk", "Essentials", map[string]any{"isNeeded": false})
+	count := len(s.items())
+
+	var readded testItem
+	s.decode(s.request(http.MethodPost, "/api/grocery-items", map[string]any{"name": "2x oat milk", "category": "Protein"}), http.StatusOK, &readded)
+	if readded.ID != item.ID || !readded.IsNeeded || readded.Version != 2 {
+		t.Errorf("re-adding gave %+v, want item %s needed again at version 2", readded, item.ID)
+	}
+	if readded.Category != "Protein" {
+		t.Errorf("re-added item is in %s, want the requested Protein", readded.Category)
+	}
+	if items := s.items(); len(items) != count {
+		t.Errorf("the list has %d items after re-adding one, want %d", len(items), count)
+	}
+}
+
+func TestRenameGroceryItemToExistingItem(t *testing.T) {
+	s := newTestServer(t)
+	s.createItem("Oat milk", "Essentials", nil)
+	rice := s.createItem("Rice", "Carbs", nil)
+
+	rec := s.request(http.MethodPatch, "/api/grocery-items/"+rice.ID, map[string]any{"name": "Oat Milk 1L"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("renaming to an item on the list = %d, want 400; body: %s", rec.Code, rec.Body.String())
+	}
+	if name := s.items()[rice.ID].Name; name != "Rice" {
+		t.Errorf("the item was renamed to %s", name)
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
@@ -10,9 +12,11 @@ import (
 	"github.com/lebensmittel/backend/websocket"
 )
 
-func CreateGroup(c *gin.Context) {
+func (h *Handler) CreateGroup(c *gin.Context) {
 	var data struct {
-		Name string `json:"name" binding:"required"`
+		Name       string `json:"name" binding:"required"`
+		Currency   string `json:"currency"`
+		DeviceName string `json:"deviceName"`
 	}
 
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -21,122 +25,136 @@ func CreateGroup(c *gin.Context) {
 	}
 
 	newGroup := models.NewGroup(data.Name)
-	if err := database.CreateGroup(c.Request.Context(), newGroup); err != nil {
+	if data.Currency != "" {
+		currency, ok := models.NormalizeCurrency(data.Currency)
+		if !ok {
+			respondRejectedFields(c, models.FieldErrors{"currency": models.InvalidCurrencyReason})
+			return
+		}
+		newGroup.Currency = currency
+	}
+	// The creating device becomes the group's first admin
+	token, secret := models.NewAccessToken(newGroup.ID, data.DeviceName, true)
+	if err := h.store.CreateGroup(c.Request.Context(), newGroup, token); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// seed example data for that group
-	if err := GenerateExampleData(c, newGroup.ID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	// seed example data for that group. The group is usable without it, and
+	// failing now would withhold the only token for it.
+	if err := h.GenerateExampleData(c, newGroup); err != nil {
+		log.Printf("Failed to seed example data for group %s: %v", newGroup.ID, err)
 	}
 
-	c.JSON(http.StatusCreated, newGroup)
+	setETag(c, newGroup.Version)
+	c.JSON(http.StatusCreated, groupWithToken{Group: newGroup, AccessToken: secret, AccessTokenID: token.ID})
 }
 
-func GetGroup(c *gin.Context) {
-	groupID := c.Param("group_id")
-
-	group, err := database.GetGroupByID(c.Request.Context(), groupID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if group == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
-		return
-	}
+func (h *Handler) GetGroup(c *gin.Context) {
+	group := requestedGroup(c)
 
+	setETag(c, group.Version)
 	c.JSON(http.StatusOK, group)
 }
 
-func UpdateGroup(c *gin.Context) {
+func (h *Handler) UpdateGroup(c *gin.Context) {
 	groupID := c.Param("group_id")
 
-	var data struct {
-		Name       *string   `json:"name"`
-		Categories *[]string `json:"categories"`
-		Members    *[]string `json:"members"`
-	}
-	if err := c.ShouldBindJSON(&data); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group update payload"})
+	var patch models.GroupPatch
+	if !bindPatch(c, &patch) {
 		return
 	}
 
-	updates := map[string]any{}
-	if data.Name != nil {
-		name := strings.TrimSpace(*data.Name)
-		if name == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Group name cannot be empty"})
-			return
-		}
-		updates["name"] = name
-	}
-	if data.Categories != nil {
-		updates["categories"] = normalizeGroupValues(*data.Categories)
+	var err error
+	if patch.Version, err = expectedVersion(c, patch.Version); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if data.Members != nil {
-		updates["members"] = normalizeGroupValues(*data.Members)
+
+	group, movedItems, err := h.store.UpdateGroup(c.Request.Context(), groupID, patch)
+	if errors.Is(err, database.ErrVersionConflict) {
+		h.groupConflict(c, groupID)
+		return
 	}
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No data provided"})
+	var rejected models.FieldErrors
+	if errors.As(err, &rejected) {
+		respondRejectedFields(c, rejected)
 		return
 	}
-
-	group, err := database.UpdateGroup(c.Request.Context(), groupID, updates)
 	if err != nil {
-		if group == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
 		return
 	}
 
-	websocket.EmitEvent("group_updated", group, groupID)
-
-	c.JSON(http.StatusOK, group)
-}
+	h.groups.put(group)
 
-func normalizeGroupValues(values []string) []string {
-	normalized := make([]string, 0, len(values))
-	for _, value := range values {
-		trimmed := strings.TrimSpace(value)
-		if trimmed != "" {
-			normalized = append(normalized, trimmed)
-		}
+	websocket.EmitEvent(c.Request.Context(), websocket.EventGroupUpdated, group, groupID)
+	if len(movedItems) > 0 {
+		websocket.EmitEvent(c.Request.Context(), websocket.EventGroceryItemsUpdated, movedItems, groupID)
+	}
+	if patch.Members != nil || patch.Currency != nil {
+		h.emitBalancesUpdated(c.Request.Context(), groupID)
 	}
-	return normalized
+
+	setETag(c, group.Version)
+	c.JSON(http.StatusOK, group)
 }
 
-func DeleteGroup(c *gin.Context) {
+func (h *Handler) DeleteGroup(c *gin.Context) {
 	groupID := c.Param("group_id")
 
-	if err := database.DeleteGroup(c.Request.Context(), groupID); err != nil {
-		if err.Error() == "group not found" {
+	version, err := deleteVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.store.DeleteGroup(c.Request.Context(), groupID, version); err != nil {
+		if errors.Is(err, database.ErrGroupNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		} else if errors.Is(err, database.ErrVersionConflict) {
+			h.groupConflict(c, groupID)
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
 		return
 	}
 
-	websocket.EmitEvent("group_deleted", gin.H{"id": groupID}, groupID)
+	h.groups.evict(groupID)
+
+	websocket.EmitEvent(c.Request.Context(), websocket.EventGroupDeleted, websocket.DeletedPayload{ID: groupID, Version: version}, groupID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Group deleted successfully"})
 }
 
+// groupConflict responds to a failed version precondition with the current group.
+func (h *Handler) groupConflict(c *gin.Context, groupID string) {
+	current, err := h.store.GetGroupByID(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+	respondConflict(c, current, current.Version)
+}
+
 // GetGroupsFromLegacyUserID is a temporary migration endpoint used to recover
 // group memberships from the legacy user_groups table based on a stored user ID.
-func GetGroupsFromLegacyUserID(c *gin.Context) {
+func (h *Handler) GetGroupsFromLegacyUserID(c *gin.Context) {
 	userID := strings.TrimSpace(c.Param("user_id"))
 	if userID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
 		return
 	}
 
-	groups, err := database.GetGroupsFromID(c.Request.Context(), userID)
+	groups, err := h.store.GetGroupsFromID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,14 @@
+package handlers
+
+import "github.com/lebensmittel/backend/database"
+
+// Handler serves the REST API on top of an injected Store.
+type Handler struct {
+	store  database.Store
+	groups *groupCache
+}
+
+// NewHandler creates a Handler that reads and writes through store.
+func NewHandler(store database.Store) *Handler {
+	return &Handler{store: store, groups: newGroupCache()}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lebensmittel/backend/database"
+)
+
+// testServer serves the API on a MemoryStore with one freshly created group,
+// whose admin token every request sends.
+type testServer struct {
+	t       *testing.T
+	store   *database.MemoryStore
+	handler *Handler
+	router  *gin.Engine
+	groupID string
+	token   string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := database.NewMemoryStore()
+	h := NewHandler(store)
+
+	// The routes under test, behind the same middleware as in main.go
+	r := gin.New()
+	api := r.Group("/api")
+	api.POST("/groups", h.CreateGroup)
+	api.POST("/groups/:group_id/claim", h.ClaimGroup)
+	api.POST("/invites/redeem", h.RedeemInvite)
+	shared := api.Group("", h.ResolveGroup(), h.RequireGroupAccess())
+	member := shared.Group("", h.RequireFullAccess())
+
+	shared.GET("/grocery-items", h.GetGroceryItems)
+	member.POST("/grocery-items", h.CreateGroceryItem)
+	shared.PATCH("/grocery-items/:item_id", h.UpdateGroceryItem)
+	member.DELETE("/grocery-items/:item_id", h.DeleteGroceryItem)
+
+	shared.GET("/categories", h.GetCategories)
+	member.POST("/categories", h.CreateCategory)
+	member.DELETE("/categories/:category_id", h.DeleteCategory)
+
+	shared.GET("/members", h.GetMembers)
+	member.PATCH("/members/:member_id", h.UpdateMember)
+
+	shared.GET("/receipts", h.GetReceipts)
+	member.POST("/receipts", h.CreateReceipt)
+
+	member.GET("/sync", h.GetChanges)
+
+	shared.GET("/groups/:group_id", h.GetGroup)
+	member.PATCH("/groups/:group_id", h.UpdateGroup)
+
+	s := &testServer{t: t, store: store, handler: h, router: r}
+
+	var created struct {
+		ID          string `json:"id"`
+		AccessToken string `json:"accessToken"`
+	}
+	s.decode(s.request(http.MethodPost, "/api/groups", map[string]any{"name": "Test"}), http.StatusCreated, &created)
+	s.groupID, s.token = created.ID, created.AccessToken
+	return s
+}
+
+// request sends a request for the server's group with its admin token. A
+// non-nil body is sent as JSON; headers are name, value pairs.
+func (s *testServer) request(method, path string, body any, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("encoding request body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if s.groupID != "" {
+		req.Header.Set("X-Group-ID", s.groupID)
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode checks a response's status and decodes its body into dst, if given.
+func (s *testServer) decode(rec *httptest.ResponseRecorder, status int, dst any) {
+	s.t.Helper()
+	if rec.Code != status {
+		s.t.Fatalf("status = %d, want %d; body: %s", rec.Code, status, rec.Body.String())
+	}
+	if dst == nil {
+		return
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), dst); err != nil {
+		s.t.Fatalf("decoding response %s: %v", rec.Body.String(), err)
+	}
+}
+
+// testItem is the part of a grocery item the tests look at.
+type testItem struct {
+	ID                string  `json:"id"`
+	Name              string  `json:"name"`
+	Category          string  `json:"category"`
+	CategoryID        string  `json:"categoryId"`
+	IsNeeded          bool    `json:"isNeeded"`
+	IsShoppingChecked bool    `json:"isShoppingChecked"`
+	NextDueAt         *string `json:"nextDueAt"`
+	ChangeSeq         int64   `json:"changeSeq"`
+	Version           int64   `json:"version"`
+}
+
+// createItem adds a grocery item with the given fields on top of a name and
+// category.
+func (s *testServer) createItem(name, category string, fields map[string]any) testItem {
+	s.t.Helper()
+	body := map[string]any{"name": name, "category": category}
+	for field, value := range fields {
+		body[field] = value
+	}
+	var item testItem
+	s.decode(s.request(http.MethodPost, "/api/grocery-items", body), http.StatusCreated, &item)
+	return item
+}
+
+// items returns the group's grocery items by ID.
+func (s *testServer) items() map[string]testItem {
+	s.t.Helper()
+	var response struct {
+		GroceryItems []testItem `json:"groceryItems"`
+	}
+	s.decode(s.request(http.MethodGet, "/api/grocery-items", nil), http.StatusOK, &response)
+	items := map[string]testItem{}
+	for _, item := range response.GroceryItems {
+		items[item.ID] = item
+	}
+	return items
+}
+
+func TestGroceryItemsOnMemoryStore(t *testing.T) {
+	s := newTestServer(t)
+	seeded := len(s.items())
+
+	item := s.createItem("Oat milk", "Essentials", nil)
+	if !item.IsNeeded || item.IsShoppingChecked {
+		t.Errorf("created %+v, want it needed and unchecked by default", item)
+	}
+
+	var updated testItem
+	s.decode(s.request(http.MethodPatch, "/api/grocery-items/"+item.ID, map[string]any{"isShoppingChecked": true}), http.StatusOK, &updated)
+	if listed := s.items()[item.ID]; !updated.IsShoppingChecked || listed != updated {
+		t.Errorf("updated %+v, listed %+v", updated, listed)
+	}
+
+	// Other groups don't see the item
+	group, token := s.groupID, s.token
+	var other struct {
+		ID          string `json:"id"`
+		AccessToken string `json:"accessToken"`
+	}
+	s.decode(s.request(http.MethodPost, "/api/groups", map[string]any{"name": "Other"}), http.StatusCreated, &other)
+	s.groupID, s.token = other.ID, other.AccessToken
+	if _, ok := s.items()[item.ID]; ok {
+		t.Error("another group lists the item")
+	}
+	if rec := s.request(http.MethodDelete, "/api/grocery-items/"+item.ID, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("deleting from another group = %d, want 404", rec.Code)
+	}
+	s.groupID, s.token = group, token
+
+	s.decode(s.request(http.MethodDelete, "/api/grocery-items/"+item.ID, nil), http.StatusOK, nil)
+	if items := s.items(); len(items) != seeded {
+		t.Errorf("%d items after deleting the new one, want the %d seeded", len(items), seeded)
+	}
+}
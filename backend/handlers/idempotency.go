@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// responseRecorder keeps a copy of everything the handler writes.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent lets clients safely retry create requests by sending an
+// Idempotency-Key header. The first successful response for a key is stored
+// per group and replayed for later requests with the same key, marked with an
+// Idempotent-Replayed header. Requests without the header pass straight through.
+func (h *Handler) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		groupID := requestedGroup(c).ID
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		io.WriteString(hash, c.Request.Method+" "+c.FullPath()+"\n")
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		ctx := c.Request.Context()
+		existing, err := h.store.ReserveIdempotencyKey(ctx, groupID, key, requestHash)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case existing.StatusCode == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.Response)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Finish the bookkeeping even if the client has gone away, so the key
+		// isn't left reserved
+		ctx = context.WithoutCancel(ctx)
+		status := recorder.Status()
+		if status >= 200 && status < 300 {
+			err = h.store.CompleteIdempotencyKey(ctx, groupID, key, status, recorder.body.Bytes())
+		} else {
+			// Failed requests may be retried with the same key
+			err = h.store.ReleaseIdempotencyKey(ctx, groupID, key)
+		}
+		if err != nil {
+			log.Printf("Failed to record idempotency key %q for group %s: %v", key, groupID, err)
+		}
+	}
+}
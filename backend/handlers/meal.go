@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -10,14 +11,10 @@ import (
 	"github.com/lebensmittel/backend/websocket"
 )
 
-func GetMealPlans(c *gin.Context) {
-	groupID, err := getRequestedGroupID(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+func (h *Handler) GetMealPlans(c *gin.Context) {
+	groupID := requestedGroup(c).ID
 
-	meals, err := database.GetAllMealPlans(c.Request.Context(), groupID)
+	meals, err := h.store.GetAllMealPlans(c.Request.Context(), groupID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -33,7 +30,7 @@ func GetMealPlans(c *gin.Context) {
 	})
 }
 
-func CreateMealPlan(c *gin.Context) {
+func (h *Handler) CreateMealPlan(c *gin.Context) {
 	var data struct {
 		Date            string `json:"date" binding:"required"`
 		MealDescription string `json:"mealDescription" binding:"required"`
@@ -44,11 +41,7 @@ func CreateMealPlan(c *gin.Context) {
 		return
 	}
 
-	groupID, err := getRequestedGroupID(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+	groupID := requestedGroup(c).ID
 
 	// Parse date
 	date, err := time.Parse("2006-01-02", data.Date)
@@ -59,70 +52,71 @@ func CreateMealPlan(c *gin.Context) {
 
 	newMeal := models.NewMealPlan(date, data.MealDescription, groupID)
 
-	if err := database.CreateMealPlan(c.Request.Context(), newMeal); err != nil {
+	if err := h.store.CreateMealPlan(c.Request.Context(), newMeal); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Emit websocket event
-	websocket.EmitEvent("meal_plan_created", newMeal, groupID)
+	websocket.EmitEvent(c.Request.Context(), websocket.EventMealPlanCreated, newMeal, groupID)
 
+	setETag(c, newMeal.Version)
 	c.JSON(http.StatusCreated, newMeal)
 }
 
-func UpdateMealPlan(c *gin.Context) {
+func (h *Handler) UpdateMealPlan(c *gin.Context) {
 	mealID := c.Param("meal_id")
 
-	var data map[string]any
-	if err := c.ShouldBindJSON(&data); err != nil || len(data) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No data provided"})
+	var patch models.MealPlanPatch
+	if !bindPatch(c, &patch) {
 		return
 	}
 
-	// Handle date parsing if provided
-	if dateStr, ok := data["date"].(string); ok {
-		date, err := time.Parse("2006-01-02", dateStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
-			return
-		}
-		data["date"] = date
-	}
+	groupID := requestedGroup(c).ID
 
-	groupID, err := getRequestedGroupID(c)
-	if err != nil {
+	var err error
+	if patch.Version, err = expectedVersion(c, patch.Version); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	meal, err := database.UpdateMealPlan(c.Request.Context(), mealID, groupID, data)
+	meal, err := h.store.UpdateMealPlan(c.Request.Context(), mealID, groupID, patch)
+	if errors.Is(err, database.ErrVersionConflict) {
+		h.mealPlanConflict(c, mealID, groupID)
+		return
+	}
 	if err != nil {
-		if meal == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Meal plan not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if meal == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Meal plan not found"})
 		return
 	}
 
 	// Emit websocket event
-	websocket.EmitEvent("meal_plan_updated", meal, meal.GroupID)
+	websocket.EmitEvent(c.Request.Context(), websocket.EventMealPlanUpdated, meal, meal.GroupID)
 
+	setETag(c, meal.Version)
 	c.JSON(http.StatusOK, meal)
 }
 
-func DeleteMealPlan(c *gin.Context) {
+func (h *Handler) DeleteMealPlan(c *gin.Context) {
 	mealID := c.Param("meal_id")
 
-	groupID, err := getRequestedGroupID(c)
+	groupID := requestedGroup(c).ID
+
+	version, err := deleteVersion(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := database.DeleteMealPlan(c.Request.Context(), mealID, groupID); err != nil {
-		if err.Error() == "meal plan not found" {
+	if err := h.store.DeleteMealPlan(c.Request.Context(), mealID, groupID, version); err != nil {
+		if errors.Is(err, database.ErrMealPlanNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Meal plan not found"})
+		} else if errors.Is(err, database.ErrVersionConflict) {
+			h.mealPlanConflict(c, mealID, groupID)
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
@@ -130,7 +124,21 @@ func DeleteMealPlan(c *gin.Context) {
 	}
 
 	// Emit websocket event
-	websocket.EmitEvent("meal_plan_deleted", gin.H{"id": mealID}, groupID)
+	websocket.EmitEvent(c.Request.Context(), websocket.EventMealPlanDeleted, websocket.DeletedPayload{ID: mealID, Version: version}, groupID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Meal plan deleted successfully"})
 }
+
+// mealPlanConflict responds to a failed version precondition with the current meal plan.
+func (h *Handler) mealPlanConflict(c *gin.Context, id, groupID string) {
+	current, err := h.store.GetMealPlanByID(c.Request.Context(), id, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Meal plan not found"})
+		return
+	}
+	respondConflict(c, current, current.Version)
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lebensmittel/backend/database"
+	"github.com/lebensmittel/backend/models"
+	"github.com/lebensmittel/backend/websocket"
+)
+
+// GetMembers returns all of the group's members, archived ones last.
+func (h *Handler) GetMembers(c *gin.Context) {
+	groupID := requestedGroup(c).ID
+
+	members, err := h.store.GetMembers(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if members == nil { // ensure JSON never returns null
+		members = []models.Member{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"members": members,
+		"count":   len(members),
+	})
+}
+
+func (h *Handler) CreateMember(c *gin.Context) {
+	var data struct {
+		DisplayName string  `json:"displayName" binding:"required"`
+		Color       *string `json:"color"`
+		SortOrder   *int    `json:"sortOrder"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "displayName is required"})
+		return
+	}
+
+	groupID := requestedGroup(c).ID
+
+	members, ok := h.groupMembers(c, groupID)
+	if !ok {
+		return
+	}
+
+	// New members go at the end of the list unless the client says otherwise
+	sortOrder := 0
+	if data.SortOrder != nil {
+		sortOrder = *data.SortOrder
+	} else {
+		for _, member := range members {
+			sortOrder = max(sortOrder, member.SortOrder+1)
+		}
+	}
+
+	newMember := models.NewMember(data.DisplayName, sortOrder, groupID)
+	newMember.Color = data.Color
+	if err := newMember.Validate(); err != nil {
+		var rejected models.FieldErrors
+		errors.As(err, &rejected)
+		respondRejectedFields(c, rejected)
+		return
+	}
+
+	err := h.store.CreateMember(c.Request.Context(), newMember)
+	var rejected models.FieldErrors
+	if errors.As(err, &rejected) {
+		respondRejectedFields(c, rejected)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventMemberCreated, newMember, groupID)
+	h.emitGroupUpdated(c.Request.Context(), groupID)
+	h.emitBalancesUpdated(c.Request.Context(), groupID)
+
+	setETag(c, newMember.Version)
+	c.JSON(http.StatusCreated, newMember)
+}
+
+// UpdateMember renames, recolors, reorders, archives or restores a member.
+// Their receipts and settlements keep pointing at them.
+func (h *Handler) UpdateMember(c *gin.Context) {
+	memberID := c.Param("member_id")
+
+	var patch models.MemberPatch
+	if !bindPatch(c, &patch) {
+		return
+	}
+
+	groupID := requestedGroup(c).ID
+
+	var err error
+	if patch.Version, err = expectedVersion(c, patch.Version); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, receipts, settlements, err := h.store.UpdateMember(c.Request.Context(), memberID, groupID, patch)
+	if errors.Is(err, database.ErrVersionConflict) {
+		h.memberConflict(c, memberID, groupID)
+		return
+	}
+	var rejected models.FieldErrors
+	if errors.As(err, &rejected) {
+		respondRejectedFields(c, rejected)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventMemberUpdated, member, groupID)
+	if patch.DisplayName != nil || patch.SortOrder != nil || patch.Archived != nil {
+		h.emitGroupUpdated(c.Request.Context(), groupID)
+	}
+	if len(receipts) > 0 {
+		websocket.EmitEvent(c.Request.Context(), websocket.EventReceiptsUpdated, receipts, groupID)
+	}
+	if len(settlements) > 0 {
+		websocket.EmitEvent(c.Request.Context(), websocket.EventSettlementsUpdated, settlements, groupID)
+	}
+	if patch.DisplayName != nil || patch.Archived != nil {
+		h.emitBalancesUpdated(c.Request.Context(), groupID)
+	}
+
+	setETag(c, member.Version)
+	c.JSON(http.StatusOK, member)
+}
+
+// memberConflict responds to a failed version precondition with the current member.
+func (h *Handler) memberConflict(c *gin.Context, id, groupID string) {
+	current, err := h.store.GetMemberByID(c.Request.Context(), id, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	respondConflict(c, current, current.Version)
+}
+
+// groupMembers loads the group's members to resolve the members a request
+// names. It writes a 500 and returns false if that fails.
+func (h *Handler) groupMembers(c *gin.Context, groupID string) ([]models.Member, bool) {
+	members, err := h.store.GetMembers(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return members, true
+}
+
+// memberRef is a request field naming a member, by ID in the field called
+// name+"Id" or by display name in any case in the field called name.
+type memberRef struct {
+	name        string
+	id, display *string
+}
+
+// resolveMemberRefs looks up the member each ref names and returns them in
+// the same order, skipping refs that name nobody. It writes a 400 and returns
+// false if one isn't a member of the group.
+func resolveMemberRefs(c *gin.Context, members []models.Member, refs ...memberRef) ([]*models.Member, bool) {
+	resolved := make([]*models.Member, len(refs))
+	rejected := models.FieldErrors{}
+	for i, ref := range refs {
+		switch {
+		case ref.id != nil:
+			if resolved[i] = models.FindMember(members, *ref.id); resolved[i] == nil {
+				rejected[ref.name+"Id"] = "must be a member of the group"
+			}
+		case ref.display != nil:
+			if resolved[i] = models.FindMemberByName(members, *ref.display); resolved[i] == nil {
+				rejected[ref.name] = "must be a member of the group"
+			}
+		}
+	}
+	if len(rejected) > 0 {
+		respondRejectedFields(c, rejected)
+		return nil, false
+	}
+	return resolved, true
+}
+
+// resolveSplitMembers fills in the member ID and display name of each split.
+// It writes a 400 and returns false if a split doesn't name a member of the
+// group or names one twice.
+func resolveSplitMembers(c *gin.Context, members []models.Member, splits []models.ReceiptSplit) bool {
+	seen := map[string]bool{}
+	for i, split := range splits {
+		member, named := models.FindMemberByName(members, split.Member), split.Member
+		if split.MemberID != "" {
+			member, named = models.FindMember(members, split.MemberID), split.MemberID
+		}
+		if member == nil {
+			respondRejectedFields(c, models.FieldErrors{"splits": named + " is not a member of the group"})
+			return false
+		}
+		if seen[member.ID] {
+			respondRejectedFields(c, models.FieldErrors{"splits": "must not list a member twice"})
+			return false
+		}
+		seen[member.ID] = true
+		splits[i].MemberID, splits[i].Member = member.ID, member.DisplayName
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+// testMember is the part of a member the tests look at.
+type testMember struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	Archived    bool   `json:"archived"`
+	Version     int64  `json:"version"`
+}
+
+func (s *testServer) members() []testMember {
+	s.t.Helper()
+	var response struct {
+		Members []testMember `json:"members"`
+	}
+	s.decode(s.request(http.MethodGet, "/api/members", nil), http.StatusOK, &response)
+	return response.Members
+}
+
+func TestRenameMemberUpdatesHistory(t *testing.T) {
+	s := newTestServer(t)
+	members := s.members()
+	if len(members) != 1 {
+		t.Fatalf("new group has members %+v, want just the default one", members)
+	}
+	member := members[0]
+
+	var receipt struct {
+		ID          string `json:"id"`
+		PurchasedBy string `json:"purchasedBy"`
+	}
+	s.decode(s.request(http.MethodPost, "/api/receipts", map[string]any{"date": "2026-03-01", "totalAmount": 1250, "purchasedById": member.ID}), http.StatusCreated, &receipt)
+
+	var renamed testMember
+	s.decode(s.request(http.MethodPatch, "/api/members/"+member.ID, map[string]any{"displayName": " Alex "}, "If-Match", `"1"`), http.StatusOK, &renamed)
+	if renamed.DisplayName != "Alex" || renamed.Version != 2 || renamed.ID != member.ID {
+		t.Errorf("renamed member = %+v, want Alex at version 2", renamed)
+	}
+
+	var receipts struct {
+		Receipts []struct {
+			ID            string `json:"id"`
+			PurchasedBy   string `json:"purchasedBy"`
+			PurchasedByID string `json:"purchasedById"`
+		} `json:"receipts"`
+	}
+	s.decode(s.request(http.MethodGet, "/api/receipts", nil), http.StatusOK, &receipts)
+	for _, r := range receipts.Receipts {
+		if r.PurchasedByID == member.ID && r.PurchasedBy != "Alex" {
+			t.Errorf("receipt %s is still by %s after the rename", r.ID, r.PurchasedBy)
+		}
+	}
+
+	var group struct {
+		Members []string `json:"members"`
+	}
+	s.decode(s.request(http.MethodGet, "/api/groups/"+s.groupID, nil), http.StatusOK, &group)
+	if len(group.Members) != 1 || group.Members[0] != "Alex" {
+		t.Errorf("group members = %v, want [Alex]", group.Members)
+	}
+
+	s.decode(s.request(http.MethodPatch, "/api/members/"+member.ID, map[string]any{"displayName": "Sam"}, "If-Match", `"1"`), http.StatusConflict, nil)
+}
+
+func TestRenameMemberRejectsTakenName(t *testing.T) {
+	s := newTestServer(t)
+	// Older clients add members through the group's members list
+	s.decode(s.request(http.MethodPatch, "/api/groups/"+s.groupID, map[string]any{"members": []string{"Default", "Sam"}}), http.StatusOK, nil)
+	members := s.members()
+	if len(members) != 2 {
+		t.Fatalf("members = %+v, want Default and Sam", members)
+	}
+
+	var response struct {
+		RejectedFields map[string]string `json:"rejectedFields"`
+	}
+	s.decode(s.request(http.MethodPatch, "/api/members/"+members[0].ID, map[string]any{"displayName": "sam"}), http.StatusBadRequest, &response)
+	if _, rejected := response.RejectedFields["displayName"]; !rejected {
+		t.Errorf("rejected fields = %v, want displayName", response.RejectedFields)
+	}
+
+	// Archived members free up their name
+	s.decode(s.request(http.MethodPatch, "/api/members/"+members[1].ID, map[string]any{"archived": true}), http.StatusOK, nil)
+	s.decode(s.request(http.MethodPatch, "/api/members/"+members[0].ID, map[string]any{"displayName": "Sam"}), http.StatusOK, nil)
+}
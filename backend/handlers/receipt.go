@@ -1,8 +1,8 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,14 +12,10 @@ import (
 	"github.com/lebensmittel/backend/websocket"
 )
 
-func GetReceipts(c *gin.Context) {
-	groupID, err := getRequestedGroupID(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+func (h *Handler) GetReceipts(c *gin.Context) {
+	groupID := requestedGroup(c).ID
 
-	receipts, err := database.GetAllReceipts(c.Request.Context(), groupID)
+	receipts, err := h.store.GetAllReceipts(c.Request.Context(), groupID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -35,16 +31,21 @@ func GetReceipts(c *gin.Context) {
 	})
 }
 
-func CreateReceipt(c *gin.Context) {
+func (h *Handler) CreateReceipt(c *gin.Context) {
 	var data struct {
-		Date        string   `json:"date" binding:"required"`
-		TotalAmount *float64 `json:"totalAmount" binding:"required"`
-		PurchasedBy string   `json:"purchasedBy" binding:"required"`
-		Notes       *string  `json:"notes"`
-		Items       []string `json:"items"`
+		Date        string         `json:"date" binding:"required"`
+		TotalAmount *models.Amount `json:"totalAmount" binding:"required"`
+		Currency    string         `json:"currency"`
+		PurchasedBy *string        `json:"purchasedBy"`
+		// PurchasedByID names the purchaser by ID instead of by display name
+		PurchasedByID *string                  `json:"purchasedById"`
+		Notes         *string                  `json:"notes"`
+		Items         []string                 `json:"items"`
+		LineItems     []models.ReceiptLineItem `json:"lineItems"`
+		Splits        []models.ReceiptSplit    `json:"splits"`
 	}
 
-	if err := c.ShouldBindJSON(&data); err != nil {
+	if err := c.ShouldBindJSON(&data); err != nil || (data.PurchasedBy == nil && data.PurchasedByID == nil) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "date, totalAmount, and purchasedBy are required"})
 		return
 	}
@@ -53,13 +54,14 @@ func CreateReceipt(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "totalAmount is required"})
 		return
 	}
-
-	groupID, err := getRequestedGroupID(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if *data.TotalAmount < 0 {
+		respondRejectedFields(c, models.FieldErrors{"totalAmount": "must not be negative"})
 		return
 	}
 
+	group := requestedGroup(c)
+	groupID := group.ID
+
 	// Parse date
 	date, err := time.Parse("2006-01-02", data.Date)
 	if err != nil {
@@ -67,101 +69,207 @@ func CreateReceipt(c *gin.Context) {
 		return
 	}
 
+	// Receipts are in the group's currency unless the client says otherwise
+	currency := data.Currency
+	if currency == "" {
+		currency = group.Currency
+	}
+	currency, ok := models.NormalizeCurrency(currency)
+	if !ok {
+		respondRejectedFields(c, models.FieldErrors{"currency": models.InvalidCurrencyReason})
+		return
+	}
+
+	if reason := models.NormalizeSplits(data.Splits); reason != "" {
+		respondRejectedFields(c, models.FieldErrors{"splits": reason})
+		return
+	}
+	members, ok := h.groupMembers(c, groupID)
+	if !ok {
+		return
+	}
+	purchaser, ok := resolveMemberRefs(c, members, memberRef{"purchasedBy", data.PurchasedByID, data.PurchasedBy})
+	if !ok || !resolveSplitMembers(c, members, data.Splits) {
+		return
+	}
+
 	newReceipt := &models.Receipt{
-		ID:          uuid.New().String(),
-		Date:        date,
-		TotalAmount: *data.TotalAmount,
-		PurchasedBy: data.PurchasedBy,
-		ItemsList:   data.Items,
-		Notes:       data.Notes,
-		GroupID:     groupID,
+		ID:            uuid.New().String(),
+		Date:          date,
+		TotalAmount:   *data.TotalAmount,
+		Currency:      currency,
+		PurchasedByID: purchaser[0].ID,
+		PurchasedBy:   purchaser[0].DisplayName,
+		Splits:        data.Splits,
+		Notes:         data.Notes,
+		GroupID:       groupID,
+	}
+	if data.LineItems != nil {
+		if data.Items != nil {
+			respondRejectedFields(c, models.FieldErrors{"items": "cannot be combined with lineItems"})
+			return
+		}
+		if reason := models.NormalizeLineItems(data.LineItems); reason != "" {
+			respondRejectedFields(c, models.FieldErrors{"lineItems": reason})
+			return
+		}
+		if !h.resolveLineItemLinks(c, groupID, data.LineItems) {
+			return
+		}
+		newReceipt.SetLineItems(data.LineItems)
+	} else {
+		newReceipt.SetItems(data.Items)
+	}
+	if err := newReceipt.ValidateLineItems(); err != nil {
+		var rejected models.FieldErrors
+		errors.As(err, &rejected)
+		respondRejectedFields(c, rejected)
+		return
 	}
 
-	updatedItems, err := database.CreateReceipt(c.Request.Context(), newReceipt)
+	updatedItems, err := h.store.CreateReceipt(c.Request.Context(), newReceipt)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Emit websocket events
-	websocket.EmitEvent("receipt_created", newReceipt, groupID)
+	websocket.EmitEvent(c.Request.Context(), websocket.EventReceiptCreated, newReceipt, groupID)
 	if len(updatedItems) > 0 {
-		websocket.EmitEvent("grocery_items_updated", updatedItems, groupID)
+		websocket.EmitEvent(c.Request.Context(), websocket.EventGroceryItemsUpdated, updatedItems, groupID)
 	}
+	h.emitBalancesUpdated(c.Request.Context(), groupID)
 
+	setETag(c, newReceipt.Version)
 	c.JSON(http.StatusCreated, newReceipt)
 }
 
-func UpdateReceipt(c *gin.Context) {
+func (h *Handler) UpdateReceipt(c *gin.Context) {
 	receiptID := c.Param("receipt_id")
 
-	var data map[string]any
-	if err := c.ShouldBindJSON(&data); err != nil || len(data) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No data provided"})
+	var patch models.ReceiptPatch
+	if !bindPatch(c, &patch) {
 		return
 	}
 
-	// Handle date parsing if provided
-	if dateStr, ok := data["date"].(string); ok {
-		date, err := time.Parse("2006-01-02", dateStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+	groupID := requestedGroup(c).ID
+
+	var err error
+	if patch.Version, err = expectedVersion(c, patch.Version); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if patch.LineItems != nil && !h.resolveLineItemLinks(c, groupID, *patch.LineItems) {
+		return
+	}
+	if patch.PurchasedBy != nil || patch.PurchasedByID != nil || patch.Splits != nil {
+		members, ok := h.groupMembers(c, groupID)
+		if !ok {
 			return
 		}
-		data["date"] = date
-	}
-
-	// Handle total amount conversion
-	if totalAmount, ok := data["totalAmount"].(float64); ok {
-		data["totalAmount"] = totalAmount
-	} else if totalAmountStr, ok := data["totalAmount"].(string); ok {
-		if totalAmount, err := strconv.ParseFloat(totalAmountStr, 64); err == nil {
-			data["totalAmount"] = totalAmount
+		purchaser, ok := resolveMemberRefs(c, members, memberRef{"purchasedBy", patch.PurchasedByID, patch.PurchasedBy})
+		if !ok {
+			return
+		}
+		if purchaser[0] != nil {
+			patch.PurchasedByID, patch.PurchasedBy = &purchaser[0].ID, &purchaser[0].DisplayName
+		}
+		if patch.Splits != nil && !resolveSplitMembers(c, members, *patch.Splits) {
+			return
 		}
 	}
 
-	groupID, err := getRequestedGroupID(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	receipt, err := h.store.UpdateReceipt(c.Request.Context(), receiptID, groupID, patch)
+	if errors.Is(err, database.ErrVersionConflict) {
+		h.receiptConflict(c, receiptID, groupID)
+		return
+	}
+	var rejected models.FieldErrors
+	if errors.As(err, &rejected) {
+		respondRejectedFields(c, rejected)
 		return
 	}
-
-	receipt, err := database.UpdateReceipt(c.Request.Context(), receiptID, groupID, data)
 	if err != nil {
-		if receipt == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if receipt == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
 		return
 	}
 
-	// Emit websocket event
-	websocket.EmitEvent("receipt_updated", receipt, receipt.GroupID)
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventReceiptUpdated, receipt, receipt.GroupID)
+	h.emitBalancesUpdated(c.Request.Context(), groupID)
 
+	setETag(c, receipt.Version)
 	c.JSON(http.StatusOK, receipt)
 }
 
-func DeleteReceipt(c *gin.Context) {
+func (h *Handler) DeleteReceipt(c *gin.Context) {
 	receiptID := c.Param("receipt_id")
 
-	groupID, err := getRequestedGroupID(c)
+	groupID := requestedGroup(c).ID
+
+	version, err := deleteVersion(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := database.DeleteReceipt(c.Request.Context(), receiptID, groupID); err != nil {
-		if err.Error() == "receipt not found" {
+	if err := h.store.DeleteReceipt(c.Request.Context(), receiptID, groupID, version); err != nil {
+		if errors.Is(err, database.ErrReceiptNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		} else if errors.Is(err, database.ErrVersionConflict) {
+			h.receiptConflict(c, receiptID, groupID)
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
 		return
 	}
 
-	// Emit websocket event
-	websocket.EmitEvent("receipt_deleted", gin.H{"id": receiptID}, groupID)
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventReceiptDeleted, websocket.DeletedPayload{ID: receiptID, Version: version}, groupID)
+	h.emitBalancesUpdated(c.Request.Context(), groupID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Receipt deleted successfully"})
 }
+
+// receiptConflict responds to a failed version precondition with the current receipt.
+func (h *Handler) receiptConflict(c *gin.Context, id, groupID string) {
+	current, err := h.store.GetReceiptByID(c.Request.Context(), id, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	}
+	respondConflict(c, current, current.Version)
+}
+
+// resolveLineItemLinks checks that linked grocery items belong to the group
+// and fills in a missing category from the linked item. It writes a 400 and
+// returns false if a link is invalid.
+func (h *Handler) resolveLineItemLinks(c *gin.Context, groupID string, lines []models.ReceiptLineItem) bool {
+	for i, line := range lines {
+		if line.GroceryItemID == nil {
+			continue
+		}
+		item, err := h.store.GetGroceryItemByID(c.Request.Context(), *line.GroceryItemID, groupID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if item == nil {
+			respondRejectedFields(c, models.FieldErrors{"lineItems": "grocery item " + *line.GroceryItemID + " not found"})
+			return false
+		}
+		if line.Category == nil {
+			category := item.Category
+			lines[i].Category = &category
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/lebensmittel/backend/websocket"
+)
+
+// RunRecurrenceScheduler marks recurring grocery items as needed again when
+// they come due, checking every interval until ctx is done.
+func (h *Handler) RunRecurrenceScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		h.renewRecurringItems(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Handler) renewRecurringItems(ctx context.Context) {
+	items, err := h.store.RenewRecurringGroceryItems(ctx, time.Now())
+	if err != nil {
+		log.Printf("Failed to renew recurring grocery items: %v", err)
+		return
+	}
+
+	// Emit websocket events
+	for _, item := range items {
+		websocket.EmitEvent(ctx, websocket.EventGroceryItemUpdated, item, item.GroupID)
+	}
+}
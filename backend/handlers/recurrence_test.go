@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRenewRecurringItems(t *testing.T) {
+	s := newTestServer(t)
+	weekly := map[string]any{"everyDays": 7, "timeZone": "Europe/Berlin"}
+	bought := s.createItem("Oat milk", "Essentials", map[string]any{"isNeeded": false, "isShoppingChecked": true, "recurrence": weekly})
+	needed := s.createItem("Coffee", "Essentials", map[string]any{"recurrence": weekly})
+	plain := s.createItem("Rye bread", "Carbs", map[string]any{"isNeeded": false})
+	if bought.NextDueAt == nil || needed.NextDueAt == nil {
+		t.Fatalf("recurring items have no due date: %+v, %+v", bought, needed)
+	}
+
+	// Nothing is due yet
+	renewed, err := s.store.RenewRecurringGroceryItems(context.Background(), time.Now())
+	if err != nil || len(renewed) != 0 {
+		t.Fatalf("renewing before the due date = %d items, %v; want none", len(renewed), err)
+	}
+
+	renewed, err = s.store.RenewRecurringGroceryItems(context.Background(), time.Now().AddDate(0, 0, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renewed) != 1 || renewed[0].ID != bought.ID {
+		t.Fatalf("renewed %+v, want only the item that wasn't needed", renewed)
+	}
+
+	items := s.items()
+	if item := items[bought.ID]; !item.IsNeeded || item.IsShoppingChecked || item.Version != bought.Version+1 || item.ChangeSeq <= bought.ChangeSeq {
+		t.Errorf("renewed item = %+v, want it needed and unchecked with a new version", item)
+	}
+	if item := items[bought.ID]; item.NextDueAt == nil || *item.NextDueAt == *bought.NextDueAt {
+		t.Errorf("renewed item is still due at %v", item.NextDueAt)
+	}
+
+	// An item already on the list only moves its due date on, which isn't a
+	// change clients need to see
+	item := items[needed.ID]
+	if item.Version != needed.Version || item.ChangeSeq != needed.ChangeSeq {
+		t.Errorf("already needed item went from version %d, seq %d to %d, %d; want them unchanged", needed.Version, needed.ChangeSeq, item.Version, item.ChangeSeq)
+	}
+	if item.NextDueAt == nil || *item.NextDueAt == *needed.NextDueAt {
+		t.Errorf("already needed item is still due at %v", item.NextDueAt)
+	}
+
+	if item := items[plain.ID]; item.IsNeeded || item.Version != plain.Version {
+		t.Errorf("item without a recurrence changed to %+v", item)
+	}
+
+	// Renewing again before the new due date does nothing
+	renewed, err = s.store.RenewRecurringGroceryItems(context.Background(), time.Now().AddDate(0, 0, 8))
+	if err != nil || len(renewed) != 0 {
+		t.Errorf("renewing twice = %d items, %v; want none the second time", len(renewed), err)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lebensmittel/backend/models"
+)
+
+// GetSpendingReport returns the group's receipt totals, counts and averages
+// bucketed by month, member or weekday. `from` and `to` are optional,
+// inclusive YYYY-MM-DD dates.
+func (h *Handler) GetSpendingReport(c *gin.Context) {
+	group := requestedGroup(c)
+
+	groupBy := c.DefaultQuery("groupBy", models.SpendingByMonth)
+	switch groupBy {
+	case models.SpendingByMonth, models.SpendingByMember, models.SpendingByWeekday:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "groupBy must be month, member or weekday"})
+		return
+	}
+
+	from, ok := optionalDateQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := optionalDateQuery(c, "to")
+	if !ok {
+		return
+	}
+	if from != nil && to != nil && from.After(*to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+	if from != nil && to != nil && from.AddDate(models.MaxSpendingReportYears, 0, 0).Before(*to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("from and to must be at most %d years apart", models.MaxSpendingReportYears)})
+		return
+	}
+
+	totals, err := h.store.GetSpendingTotals(c.Request.Context(), group.ID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	members, ok := h.groupMembers(c, group.ID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.BuildSpendingReport(group, members, groupBy, from, to, totals))
+}
+
+// optionalDateQuery parses a YYYY-MM-DD query parameter. It writes a 400 and
+// returns false if the value is malformed.
+func optionalDateQuery(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a date formatted as YYYY-MM-DD"})
+		return nil, false
+	}
+	return &date, true
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lebensmittel/backend/database"
+	"github.com/lebensmittel/backend/models"
+	"github.com/lebensmittel/backend/websocket"
+)
+
+func (h *Handler) GetSettlements(c *gin.Context) {
+	groupID := requestedGroup(c).ID
+
+	settlements, err := h.store.GetAllSettlements(c.Request.Context(), groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if settlements == nil { // ensure JSON never returns null
+		settlements = []models.Settlement{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settlements": settlements,
+		"count":       len(settlements),
+	})
+}
+
+func (h *Handler) CreateSettlement(c *gin.Context) {
+	var data struct {
+		Date     string         `json:"date" binding:"required"`
+		PaidBy   *string        `json:"paidBy"`
+		PaidTo   *string        `json:"paidTo"`
+		PaidByID *string        `json:"paidById"`
+		PaidToID *string        `json:"paidToId"`
+		Amount   *models.Amount `json:"amount" binding:"required"`
+		Currency string         `json:"currency"`
+		Notes    *string        `json:"notes"`
+	}
+
+	err := c.ShouldBindJSON(&data)
+	if err != nil || (data.PaidBy == nil && data.PaidByID == nil) || (data.PaidTo == nil && data.PaidToID == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date, paidBy, paidTo, and amount are required"})
+		return
+	}
+
+	group := requestedGroup(c)
+	groupID := group.ID
+
+	date, err := time.Parse("2006-01-02", data.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	members, ok := h.groupMembers(c, groupID)
+	if !ok {
+		return
+	}
+	parties, ok := resolveMemberRefs(c, members,
+		memberRef{"paidBy", data.PaidByID, data.PaidBy},
+		memberRef{"paidTo", data.PaidToID, data.PaidTo})
+	if !ok {
+		return
+	}
+
+	// Settlements are in the group's currency unless the client says otherwise
+	currency := group.Currency
+	if data.Currency != "" {
+		var ok bool
+		if currency, ok = models.NormalizeCurrency(data.Currency); !ok {
+			respondRejectedFields(c, models.FieldErrors{"currency": models.InvalidCurrencyReason})
+			return
+		}
+	}
+
+	newSettlement := models.NewSettlement(date, *parties[0], *parties[1], *data.Amount, currency, data.Notes, groupID)
+	if err := newSettlement.Validate(); err != nil {
+		var rejected models.FieldErrors
+		errors.As(err, &rejected)
+		respondRejectedFields(c, rejected)
+		return
+	}
+
+	if err := h.store.CreateSettlement(c.Request.Context(), newSettlement); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventSettlementCreated, newSettlement, groupID)
+	h.emitBalancesUpdated(c.Request.Context(), groupID)
+
+	setETag(c, newSettlement.Version)
+	c.JSON(http.StatusCreated, newSettlement)
+}
+
+func (h *Handler) UpdateSettlement(c *gin.Context) {
+	settlementID := c.Param("settlement_id")
+
+	var patch models.SettlementPatch
+	if !bindPatch(c, &patch) {
+		return
+	}
+
+	groupID := requestedGroup(c).ID
+
+	var err error
+	if patch.Version, err = expectedVersion(c, patch.Version); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if patch.PaidBy != nil || patch.PaidTo != nil || patch.PaidByID != nil || patch.PaidToID != nil {
+		members, ok := h.groupMembers(c, groupID)
+		if !ok {
+			return
+		}
+		parties, ok := resolveMemberRefs(c, members,
+			memberRef{"paidBy", patch.PaidByID, patch.PaidBy},
+			memberRef{"paidTo", patch.PaidToID, patch.PaidTo})
+		if !ok {
+			return
+		}
+		if parties[0] != nil {
+			patch.PaidByID, patch.PaidBy = &parties[0].ID, &parties[0].DisplayName
+		}
+		if parties[1] != nil {
+			patch.PaidToID, patch.PaidTo = &parties[1].ID, &parties[1].DisplayName
+		}
+	}
+
+	settlement, err := h.store.UpdateSettlement(c.Request.Context(), settlementID, groupID, patch)
+	if errors.Is(err, database.ErrVersionConflict) {
+		h.settlementConflict(c, settlementID, groupID)
+		return
+	}
+	var rejected models.FieldErrors
+	if errors.As(err, &rejected) {
+		respondRejectedFields(c, rejected)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if settlement == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Settlement not found"})
+		return
+	}
+
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventSettlementUpdated, settlement, settlement.GroupID)
+	h.emitBalancesUpdated(c.Request.Context(), groupID)
+
+	setETag(c, settlement.Version)
+	c.JSON(http.StatusOK, settlement)
+}
+
+func (h *Handler) DeleteSettlement(c *gin.Context) {
+	settlementID := c.Param("settlement_id")
+
+	groupID := requestedGroup(c).ID
+
+	version, err := deleteVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.store.DeleteSettlement(c.Request.Context(), settlementID, groupID, version); err != nil {
+		if errors.Is(err, database.ErrSettlementNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Settlement not found"})
+		} else if errors.Is(err, database.ErrVersionConflict) {
+			h.settlementConflict(c, settlementID, groupID)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// Emit websocket events
+	websocket.EmitEvent(c.Request.Context(), websocket.EventSettlementDeleted, websocket.DeletedPayload{ID: settlementID, Version: version}, groupID)
+	h.emitBalancesUpdated(c.Request.Context(), groupID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Settlement deleted successfully"})
+}
+
+// settlementConflict responds to a failed version precondition with the current settlement.
+func (h *Handler) settlementConflict(c *gin.Context, id, groupID string) {
+	current, err := h.store.GetSettlementByID(c.Request.Context(), id, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Settlement not found"})
+		return
+	}
+	respondConflict(c, current, current.Version)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChanges returns everything in the group that changed after the `since`
+// cursor, plus the cursor to send next time. Omitting `since` returns the
+// group's full state.
+func (h *Handler) GetChanges(c *gin.Context) {
+	groupID := requestedGroup(c).ID
+
+	var since int64
+	var err error
+	if raw := c.Query("since"); raw != "" {
+		since, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || since < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a cursor returned by a previous sync"})
+			return
+		}
+	}
+
+	changes, err := h.store.GetChangesSince(c.Request.Context(), groupID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if changes == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"groceryItems": changes.GroceryItems,
+		"mealPlans":    changes.MealPlans,
+		"receipts":     changes.Receipts,
+		"settlements":  changes.Settlements,
+		"categories":   changes.Categories,
+		"members":      changes.Members,
+		"deleted":      changes.Deleted,
+		"reset":        changes.Reset,
+		"cursor":       strconv.FormatInt(changes.Cursor, 10),
+	})
+}
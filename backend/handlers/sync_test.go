@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/lebensmittel/backend/models"
+)
+
+// testChanges is the part of a sync response the tests look at.
+type testChanges struct {
+	GroceryItems []testItem         `json:"groceryItems"`
+	Deleted      []models.Tombstone `json:"deleted"`
+	Reset        bool               `json:"reset"`
+	Cursor       string             `json:"cursor"`
+}
+
+func (s *testServer) sync(since string) testChanges {
+	s.t.Helper()
+	path := "/api/sync"
+	if since != "" {
+		path += "?since=" + since
+	}
+	var changes testChanges
+	s.decode(s.request(http.MethodGet, path, nil), http.StatusOK, &changes)
+	return changes
+}
+
+func TestGetChangesSinceCursor(t *testing.T) {
+	s := newTestServer(t)
+	kept := s.createItem("Oat milk", "Essentials", nil)
+	deleted := s.createItem("Rye bread", "Carbs", nil)
+
+	full := s.sync("")
+	if full.Reset || len(full.Deleted) != 0 {
+		t.Errorf("full sync = reset %v with %d tombstones, want neither", full.Reset, len(full.Deleted))
+	}
+	cursor, err := strconv.ParseInt(full.Cursor, 10, 64)
+	if err != nil || cursor < deleted.ChangeSeq {
+		t.Fatalf("cursor = %q, want at least the last change %d", full.Cursor, deleted.ChangeSeq)
+	}
+
+	if changes := s.sync(full.Cursor); len(changes.GroceryItems) != 0 || len(changes.Deleted) != 0 || changes.Cursor != full.Cursor {
+		t.Errorf("sync without changes = %+v, want nothing new at cursor %s", changes, full.Cursor)
+	}
+
+	s.decode(s.request(http.MethodPatch, "/api/grocery-items/"+kept.ID, map[string]any{"isShoppingChecked": true}), http.StatusOK, nil)
+	s.decode(s.request(http.MethodDelete, "/api/grocery-items/"+deleted.ID, nil), http.StatusOK, nil)
+
+	changes := s.sync(full.Cursor)
+	if len(changes.GroceryItems) != 1 || changes.GroceryItems[0].ID != kept.ID || !changes.GroceryItems[0].IsShoppingChecked {
+		t.Errorf("changed items = %+v, want only the checked item", changes.GroceryItems)
+	}
+	if len(changes.Deleted) != 1 || changes.Deleted[0].ID != deleted.ID || changes.Deleted[0].EntityType != models.EntityGroceryItem {
+		t.Errorf("tombstones = %+v, want one for item %s", changes.Deleted, deleted.ID)
+	}
+	next, _ := strconv.ParseInt(changes.Cursor, 10, 64)
+	if next <= cursor || changes.Deleted[0].ChangeSeq > next {
+		t.Errorf("cursor moved from %d to %d past tombstone %d, want it to cover every change", cursor, next, changes.Deleted[0].ChangeSeq)
+	}
+
+	// A full sync lists what exists now, so it needs no tombstones
+	if full := s.sync(""); len(full.Deleted) != 0 {
+		t.Errorf("full sync returned tombstones %+v", full.Deleted)
+	}
+}
+
+func TestGetChangesResetsUnknownCursor(t *testing.T) {
+	s := newTestServer(t)
+	s.createItem("Oat milk", "Essentials", nil)
+
+	changes := s.sync("1000000")
+	if !changes.Reset || len(changes.GroceryItems) == 0 {
+		t.Errorf("sync from a cursor ahead of the group = reset %v with %d items, want a reset with everything", changes.Reset, len(changes.GroceryItems))
+	}
+
+	s.decode(s.request(http.MethodGet, "/api/sync?since=abc", nil), http.StatusBadRequest, nil)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/lebensmittel/backend/database"
 	"github.com/lebensmittel/backend/models"
 
 	"github.com/gin-gonic/gin"
@@ -20,7 +23,9 @@ func getRequestedGroupID(c *gin.Context) (string, error) {
 }
 
 // GenerateExampleData creates example grocery items, a receipt, and a meal plan for a new group.
-func GenerateExampleData(c *gin.Context, groupID string) error {
+func (h *Handler) GenerateExampleData(c *gin.Context, group *models.Group) error {
+	groupID := group.ID
+
 	groceryItems := []struct {
 		Name     string
 		Category string
@@ -53,9 +58,23 @@ func GenerateExampleData(c *gin.Context, groupID string) error {
 		{"Pickles", "Veggies"},
 	}
 
+	categories, err := h.store.GetCategories(c, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to load categories: %w", err)
+	}
+	byName := map[string]models.Category{}
+	for _, category := range categories {
+		byName[strings.ToLower(category.Name)] = category
+	}
+
 	for _, item := range groceryItems {
-		newItem := models.NewGroceryItem(item.Name, item.Category, false, false, groupID)
-		if err := database.CreateGroceryItem(c, newItem); err != nil {
+		// Groups created with their own categories skip the examples that fit none
+		category, ok := byName[strings.ToLower(item.Category)]
+		if !ok {
+			continue
+		}
+		newItem := models.NewGroceryItem(item.Name, category, false, false, groupID)
+		if err := h.store.CreateGroceryItem(c, newItem); err != nil {
 			return fmt.Errorf("failed to create grocery item %s: %w", item.Name, err)
 		}
 	}
@@ -68,15 +87,126 @@ func GenerateExampleData(c *gin.Context, groupID string) error {
 		"Tomatoes", "Avocados",
 	}
 	notes := "Example receipt, feel free to delete me!"
-	receipt := models.NewReceipt(now, 42.67, "Default", receiptItems, &notes, groupID)
-	if _, err := database.CreateReceipt(c, receipt); err != nil {
-		return fmt.Errorf("failed to create receipt: %w", err)
+	members, err := h.store.GetMembers(c, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to load members: %w", err)
+	}
+	if len(members) > 0 {
+		receipt := models.NewReceipt(now, 4267, group.Currency, members[0], receiptItems, &notes, groupID)
+		if _, err := h.store.CreateReceipt(c, receipt); err != nil {
+			return fmt.Errorf("failed to create receipt: %w", err)
+		}
 	}
 
 	mealPlan := models.NewMealPlan(now, "Example Meal", groupID)
-	if err := database.CreateMealPlan(c, mealPlan); err != nil {
+	if err := h.store.CreateMealPlan(c, mealPlan); err != nil {
 		return fmt.Errorf("failed to create meal plan: %w", err)
 	}
 
 	return nil
 }
+
+// patchRequest is implemented by the typed PATCH payloads in models.
+type patchRequest interface {
+	Validate() error
+	IsEmpty() bool
+}
+
+// bindPatch decodes the request body into patch, accepting only the fields the
+// patch type declares. It writes a 400 listing the rejected fields and returns
+// false when the body isn't acceptable.
+func bindPatch(c *gin.Context, patch patchRequest) bool {
+	body, err := c.GetRawData()
+	if err != nil || len(body) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No data provided"})
+		return false
+	}
+
+	rejected := models.FieldErrors{}
+	if err := models.DecodePatch(body, patch); err != nil {
+		var decodeErrors models.FieldErrors
+		if !errors.As(err, &decodeErrors) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		maps.Copy(rejected, decodeErrors)
+	}
+	// Validate the fields that did decode so the client sees every problem at once
+	if err := patch.Validate(); err != nil {
+		var validationErrors models.FieldErrors
+		if !errors.As(err, &validationErrors) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		for field, reason := range validationErrors {
+			if _, exists := rejected[field]; !exists {
+				rejected[field] = reason
+			}
+		}
+	}
+	if len(rejected) > 0 {
+		respondRejectedFields(c, rejected)
+		return false
+	}
+
+	if patch.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No data provided"})
+		return false
+	}
+	return true
+}
+
+// respondRejectedFields writes a 400 listing the fields that can't be applied.
+func respondRejectedFields(c *gin.Context, rejected models.FieldErrors) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":          "Request contains fields that cannot be applied",
+		"rejectedFields": rejected,
+	})
+}
+
+// expectedVersion reads the client's version precondition. The If-Match header
+// takes priority over fallback, which is the version field of a PATCH body or
+// the version query parameter of a DELETE. "*" and a missing precondition both
+// mean any version.
+func expectedVersion(c *gin.Context, fallback *int64) (*int64, error) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" {
+		return fallback, nil
+	}
+	if value == "*" {
+		return nil, nil
+	}
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 1 {
+		return nil, fmt.Errorf("If-Match must be an entity version")
+	}
+	return &version, nil
+}
+
+// deleteVersion is expectedVersion for DELETE requests, which can't carry a body.
+func deleteVersion(c *gin.Context) (*int64, error) {
+	value := c.Query("version")
+	if value == "" {
+		return expectedVersion(c, nil)
+	}
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 1 {
+		return nil, fmt.Errorf("version must be a positive integer")
+	}
+	return expectedVersion(c, &version)
+}
+
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// respondConflict answers a failed version precondition with the server's
+// current copy so the client can resolve the conflict.
+func respondConflict(c *gin.Context, current any, version int64) {
+	setETag(c, version)
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "The entity was modified by someone else",
+		"current": current,
+	})
+}
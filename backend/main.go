@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -17,12 +18,31 @@ import (
 )
 
 func main() {
-	if err := database.InitDB(); err != nil {
+	cfg := LoadConfig()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "claim-code" {
+		if err := runClaimCodeCommand(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("Creating a claim code failed: %v", err)
+		}
+		return
+	}
+
+	store, err := newStore(cfg)
+	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer database.CloseDB()
+	defer store.Close()
 
-	websocket.InitWebSocketManager()
+	h := handlers.NewHandler(store)
+
+	websocket.InitWebSocketManager(h.AuthorizeGroup, newBus(store))
 
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
@@ -30,8 +50,10 @@ func main() {
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowMethods = []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "X-Group-ID"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Group-ID", "If-Match", "Idempotency-Key", websocket.ClientIDHeader}
+	config.ExposeHeaders = []string{"ETag", "Idempotent-Replayed"}
 	r.Use(cors.New(config))
+	r.Use(websocket.TagClientID())
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -40,44 +62,99 @@ func main() {
 		})
 	})
 
+	// Outbound websocket queue depths, for spotting clients that fall behind
+	r.GET("/metrics/websocket", func(c *gin.Context) {
+		c.JSON(http.StatusOK, websocket.GetStats())
+	})
+
 	r.GET("/ws", websocket.HandleWebSocket)
 
+	// JSON Schema of the messages sent over /ws
+	r.GET("/ws/schema", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/schema+json", websocket.Schema)
+	})
+
 	api := r.Group("/api")
 
-	api.GET("/grocery-items", handlers.GetGroceryItems)
-	api.POST("/grocery-items", handlers.CreateGroceryItem)
-	api.PATCH("/grocery-items/:item_id", handlers.UpdateGroceryItem)
-	api.DELETE("/grocery-items/:item_id", handlers.DeleteGroceryItem)
+	api.POST("/groups", h.CreateGroup)
+	// Claiming needs no token, but a legacy member's user ID or a claim code
+	// from `backend claim-code`
+	api.POST("/groups/:group_id/claim", h.ClaimGroup)
+	api.POST("/invites/redeem", h.RedeemInvite)
+
+	// temporary migration endpoint for recovering legacy user group memberships
+	api.GET("/migration/users/:user_id/groups", h.GetGroupsFromLegacyUserID)
 
-	api.GET("/meal-plans", handlers.GetMealPlans)
-	api.POST("/meal-plans", handlers.CreateMealPlan)
-	api.PATCH("/meal-plans/:meal_id", handlers.UpdateMealPlan)
-	api.DELETE("/meal-plans/:meal_id", handlers.DeleteMealPlan)
+	// Everything else is for an existing group and needs an access token for
+	// it. Share links may only read the lists, meal plan and receipts, and
+	// check items off.
+	shared := api.Group("", h.ResolveGroup(), h.RequireGroupAccess())
+	member := shared.Group("", h.RequireFullAccess())
+	admin := member.Group("", h.RequireGroupAdmin())
 
-	api.GET("/receipts", handlers.GetReceipts)
-	api.POST("/receipts", handlers.CreateReceipt)
-	api.PATCH("/receipts/:receipt_id", handlers.UpdateReceipt)
-	api.DELETE("/receipts/:receipt_id", handlers.DeleteReceipt)
+	shared.GET("/grocery-items", h.GetGroceryItems)
+	member.GET("/grocery-items/suggestions", h.GetGrocerySuggestions)
+	member.POST("/grocery-items", h.Idempotent(), h.CreateGroceryItem)
+	shared.PATCH("/grocery-items/:item_id", h.UpdateGroceryItem)
+	member.DELETE("/grocery-items/:item_id", h.DeleteGroceryItem)
 
-	api.POST("/groups", handlers.CreateGroup)
-	api.GET("/groups/:group_id", handlers.GetGroup)
-	api.PATCH("/groups/:group_id", handlers.UpdateGroup)
-	api.DELETE("/groups/:group_id", handlers.DeleteGroup)
+	shared.GET("/categories", h.GetCategories)
+	member.POST("/categories", h.Idempotent(), h.CreateCategory)
+	member.PATCH("/categories/:category_id", h.UpdateCategory)
+	member.DELETE("/categories/:category_id", h.DeleteCategory)
 
-	// temporary migration endpoint for recovering legacy user group memberships
-	api.GET("/migration/users/:user_id/groups", handlers.GetGroupsFromLegacyUserID)
+	shared.GET("/members", h.GetMembers)
+	member.POST("/members", h.Idempotent(), h.CreateMember)
+	member.PATCH("/members/:member_id", h.UpdateMember)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
-	}
+	shared.GET("/meal-plans", h.GetMealPlans)
+	member.POST("/meal-plans", h.Idempotent(), h.CreateMealPlan)
+	member.PATCH("/meal-plans/:meal_id", h.UpdateMealPlan)
+	member.DELETE("/meal-plans/:meal_id", h.DeleteMealPlan)
+
+	shared.GET("/receipts", h.GetReceipts)
+	member.POST("/receipts", h.Idempotent(), h.CreateReceipt)
+	member.PATCH("/receipts/:receipt_id", h.UpdateReceipt)
+	member.DELETE("/receipts/:receipt_id", h.DeleteReceipt)
 
-	log.Printf("Server starting on port %s", port)
+	member.GET("/settlements", h.GetSettlements)
+	member.POST("/settlements", h.Idempotent(), h.CreateSettlement)
+	member.PATCH("/settlements/:settlement_id", h.UpdateSettlement)
+	member.DELETE("/settlements/:settlement_id", h.DeleteSettlement)
+
+	member.GET("/balances", h.GetBalances)
+
+	member.GET("/sync", h.GetChanges)
+
+	member.GET("/reports/spending", h.GetSpendingReport)
+
+	shared.GET("/groups/:group_id", h.GetGroup)
+	member.PATCH("/groups/:group_id", h.UpdateGroup)
+	admin.DELETE("/groups/:group_id", h.DeleteGroup)
+
+	member.POST("/groups/:group_id/invites", h.CreateInvite)
+	admin.GET("/groups/:group_id/invites", h.GetInvites)
+	admin.DELETE("/groups/:group_id/invites/:code", h.RevokeInvite)
+
+	// Any device that can share the group can also take a leaked link back
+	member.POST("/groups/:group_id/share-links", h.CreateShareLink)
+	member.GET("/groups/:group_id/share-links", h.GetShareLinks)
+	member.DELETE("/groups/:group_id/share-links/:token_id", h.RevokeShareLink)
+
+	admin.GET("/groups/:group_id/tokens", h.GetAccessTokens)
+	member.DELETE("/groups/:group_id/tokens/:token_id", h.RevokeAccessToken)
+	admin.POST("/groups/:group_id/rotate", h.RotateGroupAccess)
+
+	log.Printf("Server starting on port %s", cfg.Port)
 	srv := &http.Server{
-		Addr:    ":" + port,
+		Addr:    ":" + cfg.Port,
 		Handler: r,
 	}
 
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go h.RunRecurrenceScheduler(schedulerCtx, cfg.RecurrenceInterval)
+
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %s\n", err)
@@ -88,6 +165,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+	stopScheduler()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -97,3 +175,30 @@ func main() {
 
 	log.Println("Server exiting")
 }
+
+// newStore creates the Store selected by cfg.Storage.
+func newStore(cfg *Config) (database.Store, error) {
+	switch cfg.Storage {
+	case "postgres":
+		store, err := database.InitDB(cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "memory":
+		log.Println("Using in-memory storage; data will not survive a restart")
+		return database.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE %q, expected postgres or memory", cfg.Storage)
+	}
+}
+
+// newBus picks how websocket events reach every instance: through Postgres
+// when the data lives there, so replicas sharing a database see each other's
+// changes, and in process otherwise.
+func newBus(store database.Store) websocket.Bus {
+	if postgres, ok := store.(*database.PostgresStore); ok {
+		return websocket.NewPostgresBus(postgres.Pool())
+	}
+	return websocket.NewLocalBus()
+}
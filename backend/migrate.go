@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lebensmittel/backend/database"
+)
+
+const migrateUsage = `usage: backend migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down [steps]  revert the last migration, or the last <steps> migrations
+  status        list migrations and whether they have been applied`
+
+// runMigrateCommand handles `backend migrate ...` without starting the server.
+func runMigrateCommand(cfg *Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	ctx := context.Background()
+	pool, err := database.Connect(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(ctx, pool)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive integer")
+			}
+		}
+		reverted, err := database.MigrateDown(ctx, pool, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migration(s)\n", reverted)
+
+	case "status":
+		states, err := database.GetMigrationStatus(ctx, pool)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, state := range states {
+			status := "pending"
+			switch {
+			case state.Unknown:
+				status = "applied (unknown to this binary)"
+			case state.Modified:
+				status = "applied (modified since)"
+			case state.Applied:
+				status = "applied"
+			}
+			appliedAt := "-"
+			if state.AppliedAt != nil {
+				appliedAt = state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", state.Version, state.Name, status, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccessToken lets one device read and write a group. Only a hash of the
+// secret is stored; the secret itself is handed out once.
+type AccessToken struct {
+	ID         string     `json:"id" db:"id"`
+	GroupID    string     `json:"groupId" db:"group_id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	DeviceName string     `json:"deviceName" db:"device_name"`
+	Admin      bool       `json:"admin" db:"is_admin"`
+	Scope      Scope      `json:"scope" db:"scope"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+}
+
+// Scope limits what an access token may do. Tokens handed to devices that
+// joined the group have full access; share links have one of the others.
+type Scope string
+
+const (
+	ScopeFull Scope = "full"
+	// ScopeReadOnly may read the group's lists, meal plan and receipts.
+	ScopeReadOnly Scope = "read"
+	// ScopeShopping may also check grocery items off while shopping.
+	ScopeShopping Scope = "shopping"
+)
+
+// IsShareScope reports whether s is one of the scopes share links may have.
+func (s Scope) IsShareScope() bool {
+	return s == ScopeReadOnly || s == ScopeShopping
+}
+
+// sharedEventPrefixes are the websocket events share links receive. They
+// match what share links can read over HTTP.
+var sharedEventPrefixes = []string{"grocery_item", "category_", "member_", "meal_plan_", "receipt", "group_"}
+
+// AllowsEvent reports whether a subscription made with a token of this scope
+// receives the websocket event.
+func (s Scope) AllowsEvent(event string) bool {
+	if !s.IsShareScope() {
+		return true
+	}
+	for _, prefix := range sharedEventPrefixes {
+		if strings.HasPrefix(event, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAccessToken creates a full access token for a device and returns it
+// together with its secret.
+func NewAccessToken(groupID, deviceName string, admin bool) (*AccessToken, string) {
+	return NewScopedAccessToken(groupID, deviceName, admin, ScopeFull)
+}
+
+// NewScopedAccessToken is NewAccessToken for a token limited to scope.
+func NewScopedAccessToken(groupID, deviceName string, admin bool, scope Scope) (*AccessToken, string) {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return &AccessToken{
+		ID:         uuid.New().String(),
+		GroupID:    groupID,
+		TokenHash:  HashAccessToken(encoded),
+		DeviceName: strings.TrimSpace(deviceName),
+		Admin:      admin,
+		Scope:      scope,
+	}, encoded
+}
+
+// FullAccess reports whether the token may change the group. A nil token,
+// used for groups that don't require one yet, has full access.
+func (t *AccessToken) FullAccess() bool {
+	return t == nil || !t.Scope.IsShareScope()
+}
+
+// HashAccessToken returns the hex encoded SHA-256 of a token secret.
+func HashAccessToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Invite is a short code that can be exchanged for an access token to a group
+// until it expires, is used up or is revoked.
+type Invite struct {
+	Code      string     `json:"code" db:"code"`
+	GroupID   string     `json:"groupId" db:"group_id"`
+	CreatedBy *string    `json:"createdBy" db:"created_by"` // access token ID
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	ExpiresAt time.Time  `json:"expiresAt" db:"expires_at"`
+	MaxUses   *int       `json:"maxUses" db:"max_uses"` // nil means unlimited
+	Uses      int        `json:"uses" db:"uses"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+}
+
+// Invite lifetimes. Clients may ask for anything up to MaxInviteTTL.
+const (
+	DefaultInviteTTL = 48 * time.Hour
+	MaxInviteTTL     = 7 * 24 * time.Hour
+)
+
+// inviteAlphabet leaves out characters that are easy to confuse (0/O, 1/I/L).
+const inviteAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+const inviteCodeLength = 8
+
+// NewInvite creates an invite with a random code that expires after ttl.
+func NewInvite(groupID string, createdBy *string, ttl time.Duration, maxUses *int) *Invite {
+	code := make([]byte, 0, inviteCodeLength)
+	random := make([]byte, 1)
+	for len(code) < inviteCodeLength {
+		rand.Read(random)
+		// Skip bytes past the last full multiple of the alphabet to avoid bias
+		if int(random[0]) >= 256-256%len(inviteAlphabet) {
+			continue
+		}
+		code = append(code, inviteAlphabet[int(random[0])%len(inviteAlphabet)])
+	}
+	now := time.Now().UTC()
+	return &Invite{
+		Code:      string(code),
+		GroupID:   groupID,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		MaxUses:   maxUses,
+	}
+}
+
+// NormalizeInviteCode upper-cases a code as typed by a user and drops the
+// spaces and dashes they may have added.
+func NormalizeInviteCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// Usable reports whether the invite can still be redeemed at now.
+func (i Invite) Usable(now time.Time) bool {
+	return i.RevokedAt == nil && now.Before(i.ExpiresAt) && (i.MaxUses == nil || i.Uses < *i.MaxUses)
+}
@@ -0,0 +1,323 @@
+package models
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Category is one of a group's grocery categories. The group's categories
+// list is their names in sort order.
+type Category struct {
+	ID        string  `json:"id" db:"id"`
+	Name      string  `json:"name" db:"name"`
+	SortOrder int     `json:"sortOrder" db:"sort_order"`
+	Color     *string `json:"color" db:"color"` // #RRGGBB
+	// Icon is an emoji or a short icon name chosen by the client
+	Icon      *string   `json:"icon" db:"icon"`
+	GroupID   string    `json:"groupId" db:"group_id"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	ChangeSeq int64     `json:"changeSeq" db:"change_seq"`
+	Version   int64     `json:"version" db:"version"`
+}
+
+// NewCategory creates a new category with a generated UUID
+func NewCategory(name string, sortOrder int, groupID string) *Category {
+	return &Category{
+		ID:        uuid.New().String(),
+		Name:      name,
+		SortOrder: sortOrder,
+		GroupID:   groupID,
+	}
+}
+
+// maxIconLength is the longest icon accepted, in characters. Emoji made of
+// several code points still fit.
+const maxIconLength = 32
+
+var colorPattern = regexp.MustCompile(`^#[0-9A-F]{6}$`)
+
+// Validate normalizes the category and reports invalid values.
+func (c *Category) Validate() error {
+	rejected := FieldErrors{}
+	trimRequired(&c.Name, "name", rejected)
+	validateColor(c.Color, rejected)
+	validateIcon(c.Icon, rejected)
+	return rejected.orNil()
+}
+
+// validateColor upper-cases an optional color in place and rejects it if it
+// isn't a #RRGGBB hex color.
+func validateColor(color *string, rejected FieldErrors) {
+	if color == nil {
+		return
+	}
+	*color = strings.ToUpper(strings.TrimSpace(*color))
+	if !colorPattern.MatchString(*color) {
+		rejected["color"] = "must be a hex color like #4CAF50"
+	}
+}
+
+// validateIcon trims an optional icon in place and rejects it if it's too long.
+func validateIcon(icon *string, rejected FieldErrors) {
+	if icon == nil {
+		return
+	}
+	*icon = strings.TrimSpace(*icon)
+	if utf8.RuneCountInString(*icon) > maxIconLength {
+		rejected["icon"] = "must be at most 32 characters"
+	}
+}
+
+// CategoryNames returns the names of categories in the order given.
+func CategoryNames(categories []Category) []string {
+	names := make([]string, len(categories))
+	for i, category := range categories {
+		names[i] = category.Name
+	}
+	return names
+}
+
+// SortCategories orders categories the way the group lists them.
+func SortCategories(categories []Category) {
+	slices.SortFunc(categories, func(a, b Category) int {
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder - b.SortOrder
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// FallbackCategory is where grocery items go when their category is removed
+// from the group.
+const FallbackCategory = "Other"
+
+// CategoryPolicy decides what happens to grocery items when their category is
+// removed from the group's list.
+type CategoryPolicy string
+
+const (
+	// CategoryPolicyOther moves the items to FallbackCategory, adding it to
+	// the list if it's missing. This is the default.
+	CategoryPolicyOther CategoryPolicy = "other"
+	// CategoryPolicyRename treats the categories that were removed and added
+	// as renames, paired up in list order, and moves the items along.
+	CategoryPolicyRename CategoryPolicy = "rename"
+	// CategoryPolicyReject refuses to remove categories that items still use.
+	CategoryPolicyReject CategoryPolicy = "reject"
+)
+
+func (p CategoryPolicy) valid() bool {
+	return p == CategoryPolicyOther || p == CategoryPolicyRename || p == CategoryPolicyReject
+}
+
+// CategoryRename renames a group's category.
+type CategoryRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// CategoryChanges is how a patch changes a group's categories.
+type CategoryChanges struct {
+	// Categories is the group's new list of category names, in order
+	Categories []string
+	// Renamed maps current categories that keep their ID to their new name
+	Renamed map[string]string
+	// Moved maps removed categories to the category their items move to.
+	// Removed categories without items aren't listed.
+	Moved map[string]string
+}
+
+// ChangesCategories reports whether the patch changes the group's categories.
+func (p GroupPatch) ChangesCategories() bool {
+	return p.Categories != nil || p.RenameCategory != nil
+}
+
+// Recategorize works out how the patch changes the group's categories, given
+// the names of the categories grocery items are in. Under the default policy
+// it may add FallbackCategory to the new list.
+func (p *GroupPatch) Recategorize(group Group, used []string) (*CategoryChanges, error) {
+	if p.RenameCategory != nil {
+		return p.renameCategory(group)
+	}
+	if p.Categories == nil {
+		return nil, nil
+	}
+	next := Group{Categories: *p.Categories}
+	changes := &CategoryChanges{Categories: *p.Categories, Renamed: map[string]string{}, Moved: map[string]string{}}
+
+	var removed []string
+	for _, category := range used {
+		if _, kept := next.CanonicalCategory(category); !kept {
+			removed = append(removed, category)
+		}
+	}
+	slices.Sort(removed)
+
+	policy := CategoryPolicyOther
+	if p.CategoryPolicy != nil {
+		policy = *p.CategoryPolicy
+	}
+
+	switch policy {
+	case CategoryPolicyReject:
+		if len(removed) > 0 {
+			return nil, FieldErrors{"categories": "still used by grocery items: " + strings.Join(removed, ", ")}
+		}
+
+	case CategoryPolicyRename:
+		renames, ok := categoryRenames(group.Categories, next)
+		if !ok {
+			return nil, FieldErrors{"categories": "renamed categories must be replaced one for one"}
+		}
+		changes.Renamed = renames
+
+	default:
+		if len(removed) == 0 {
+			break
+		}
+		fallback, ok := next.CanonicalCategory(FallbackCategory)
+		if !ok {
+			changes.Categories = append(changes.Categories, FallbackCategory)
+		}
+		for _, category := range removed {
+			changes.Moved[category] = fallback
+		}
+	}
+	return changes, nil
+}
+
+// categoryRenames pairs the categories missing from next with the ones new in
+// it, in list order. It fails if their numbers differ.
+func categoryRenames(current []string, next Group) (map[string]string, bool) {
+	previous := Group{Categories: current}
+	var from, to []string
+	for _, category := range current {
+		if _, ok := next.CanonicalCategory(category); !ok {
+			from = append(from, category)
+		}
+	}
+	for _, category := range next.Categories {
+		if _, ok := previous.CanonicalCategory(category); !ok {
+			to = append(to, category)
+		}
+	}
+	if len(from) != len(to) {
+		return nil, false
+	}
+	renames := make(map[string]string, len(from))
+	for i := range from {
+		renames[from[i]] = to[i]
+	}
+	return renames, true
+}
+
+// renameCategory renames one of the group's categories in place.
+func (p *GroupPatch) renameCategory(group Group) (*CategoryChanges, error) {
+	rename := *p.RenameCategory
+	from, ok := group.CanonicalCategory(rename.From)
+	if !ok {
+		return nil, FieldErrors{"renameCategory.from": "must be one of the group's categories"}
+	}
+	// Changing only the case of a category is fine, taking another one's name isn't
+	if existing, taken := group.CanonicalCategory(rename.To); taken && existing != from {
+		return nil, FieldErrors{"renameCategory.to": "is already one of the group's categories"}
+	}
+
+	categories := slices.Clone(group.Categories)
+	categories[slices.Index(categories, from)] = rename.To
+	return &CategoryChanges{
+		Categories: categories,
+		Renamed:    map[string]string{from: rename.To},
+		Moved:      map[string]string{},
+	}, nil
+}
+
+// CategoryPlan is what CategoryChanges do to a group's stored categories.
+type CategoryPlan struct {
+	Created []Category
+	// Updated lists categories with a new name or sort order
+	Updated []Category
+	// Renamed lists the IDs of the updated categories whose name changed
+	Renamed []string
+	// Moved maps deleted category IDs to the ID of the category their
+	// grocery items move to
+	Moved   map[string]string
+	Deleted []string
+}
+
+// PlanCategoryChanges matches changes against the group's current
+// categories. Categories that stay or are renamed keep their IDs and take
+// their position in the new list as sort order.
+func PlanCategoryChanges(current []Category, changes *CategoryChanges, groupID string) CategoryPlan {
+	plan := CategoryPlan{Moved: map[string]string{}}
+	byName := map[string]*Category{}
+	for i := range current {
+		byName[strings.ToLower(current[i].Name)] = &current[i]
+	}
+	renamedFrom := map[string]string{}
+	for from, to := range changes.Renamed {
+		renamedFrom[strings.ToLower(to)] = from
+	}
+
+	idsByName := map[string]string{}
+	kept := map[string]bool{}
+	for i, name := range changes.Categories {
+		existing := byName[strings.ToLower(name)]
+		if from, ok := renamedFrom[strings.ToLower(name)]; ok {
+			existing = byName[strings.ToLower(from)]
+		}
+		if existing == nil || kept[existing.ID] {
+			created := NewCategory(name, i, groupID)
+			plan.Created = append(plan.Created, *created)
+			idsByName[strings.ToLower(name)] = created.ID
+			continue
+		}
+
+		kept[existing.ID] = true
+		idsByName[strings.ToLower(name)] = existing.ID
+		if existing.Name == name && existing.SortOrder == i {
+			continue
+		}
+		if existing.Name != name {
+			plan.Renamed = append(plan.Renamed, existing.ID)
+		}
+		updated := *existing
+		updated.Name, updated.SortOrder = name, i
+		plan.Updated = append(plan.Updated, updated)
+	}
+
+	for _, category := range current {
+		if kept[category.ID] {
+			continue
+		}
+		plan.Deleted = append(plan.Deleted, category.ID)
+		if to, ok := changes.Moved[category.Name]; ok {
+			plan.Moved[category.ID] = idsByName[strings.ToLower(to)]
+		}
+	}
+	return plan
+}
+
+// DeleteCategoryChanges works out the changes for deleting one of the group's
+// categories, given the names of the categories grocery items are in.
+func DeleteCategoryChanges(group Group, name string, policy CategoryPolicy, used []string) (*CategoryChanges, error) {
+	if len(group.Categories) <= 1 {
+		return nil, FieldErrors{"categories": "the group's last category can't be deleted"}
+	}
+	remaining := slices.DeleteFunc(slices.Clone(group.Categories), func(category string) bool { return category == name })
+	patch := GroupPatch{Categories: &remaining, CategoryPolicy: &policy}
+	changes, err := patch.Recategorize(group, used)
+	if err != nil {
+		return nil, err
+	}
+	// The fallback category can't take in its own items
+	if _, kept := (Group{Categories: changes.Categories}).CanonicalCategory(name); kept {
+		return nil, FieldErrors{"categories": FallbackCategory + " can't be deleted while grocery items are in it"}
+	}
+	return changes, nil
+}
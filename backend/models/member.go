@@ -0,0 +1,165 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Member is someone in a group. Receipts and settlements reference members by
+// ID, so renaming a member carries their history along. The group's members
+// list is the display names of its active members in sort order.
+type Member struct {
+	ID          string  `json:"id" db:"id"`
+	DisplayName string  `json:"displayName" db:"display_name"`
+	Color       *string `json:"color" db:"color"` // #RRGGBB
+	// Archived members are kept for the receipts and settlements that
+	// reference them, but are left out of the members list and equal splits
+	Archived  bool      `json:"archived" db:"archived"`
+	SortOrder int       `json:"sortOrder" db:"sort_order"`
+	GroupID   string    `json:"groupId" db:"group_id"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	ChangeSeq int64     `json:"changeSeq" db:"change_seq"`
+	Version   int64     `json:"version" db:"version"`
+}
+
+// NewMember creates a new member with a generated UUID
+func NewMember(displayName string, sortOrder int, groupID string) *Member {
+	return &Member{
+		ID:          uuid.New().String(),
+		DisplayName: displayName,
+		SortOrder:   sortOrder,
+		GroupID:     groupID,
+	}
+}
+
+// Validate normalizes the member and reports invalid values.
+func (m *Member) Validate() error {
+	rejected := FieldErrors{}
+	trimRequired(&m.DisplayName, "displayName", rejected)
+	validateColor(m.Color, rejected)
+	return rejected.orNil()
+}
+
+// SortMembers orders members the way the group lists them: active members by
+// sort order, then archived ones by name.
+func SortMembers(members []Member) {
+	slices.SortFunc(members, func(a, b Member) int {
+		switch {
+		case a.Archived && !b.Archived:
+			return 1
+		case !a.Archived && b.Archived:
+			return -1
+		case !a.Archived:
+			return cmp.Or(cmp.Compare(a.SortOrder, b.SortOrder), strings.Compare(a.DisplayName, b.DisplayName))
+		}
+		return cmp.Or(strings.Compare(a.DisplayName, b.DisplayName), strings.Compare(a.ID, b.ID))
+	})
+}
+
+// ActiveMembers returns the members that aren't archived, keeping their order.
+func ActiveMembers(members []Member) []Member {
+	active := []Member{}
+	for _, member := range members {
+		if !member.Archived {
+			active = append(active, member)
+		}
+	}
+	return active
+}
+
+// MemberNames returns the display names of members in the order given.
+func MemberNames(members []Member) []string {
+	names := make([]string, len(members))
+	for i, member := range members {
+		names[i] = member.DisplayName
+	}
+	return names
+}
+
+// FindMember returns the member with the given ID, or nil.
+func FindMember(members []Member, id string) *Member {
+	for i := range members {
+		if members[i].ID == id {
+			return &members[i]
+		}
+	}
+	return nil
+}
+
+// FindMemberByName returns the member with the given display name, matching
+// case-insensitively and preferring active members, or nil.
+func FindMemberByName(members []Member, name string) *Member {
+	name = strings.TrimSpace(name)
+	var archived *Member
+	for i := range members {
+		if !strings.EqualFold(members[i].DisplayName, name) {
+			continue
+		}
+		if !members[i].Archived {
+			return &members[i]
+		}
+		if archived == nil {
+			archived = &members[i]
+		}
+	}
+	return archived
+}
+
+// NameTaken reports whether an active member other than the one with id is
+// already called name, ignoring case.
+func NameTaken(members []Member, name, id string) bool {
+	return slices.ContainsFunc(members, func(member Member) bool {
+		return !member.Archived && member.ID != id && strings.EqualFold(member.DisplayName, name)
+	})
+}
+
+// PlanMemberChanges matches a members list, as older clients send it, against
+// the group's members. Listed names keep the ID of the member with that name
+// and take their position as sort order, new names become new members, and
+// members left out are archived rather than deleted. Updated lists the
+// existing members that changed.
+func PlanMemberChanges(current []Member, names []string, groupID string) (created, updated []Member) {
+	current = slices.Clone(current)
+	SortMembers(current)
+
+	kept := map[string]bool{}
+	for i, name := range names {
+		match := -1
+		for j, member := range current {
+			if !kept[member.ID] && strings.EqualFold(member.DisplayName, name) {
+				match = j
+				break
+			}
+		}
+		if match < 0 {
+			created = append(created, *NewMember(name, i, groupID))
+			continue
+		}
+
+		member := current[match]
+		kept[member.ID] = true
+		if member.DisplayName == name && member.SortOrder == i && !member.Archived {
+			continue
+		}
+		member.DisplayName, member.SortOrder, member.Archived = name, i, false
+		updated = append(updated, member)
+	}
+
+	for _, member := range current {
+		if !kept[member.ID] && !member.Archived {
+			member.Archived = true
+			updated = append(updated, member)
+		}
+	}
+	return created, updated
+}
+
+// ChangesMembersList reports whether the member differs from previous in a
+// way that shows in the group's members list.
+func (m Member) ChangesMembersList(previous Member) bool {
+	return m.DisplayName != previous.DisplayName || m.SortOrder != previous.SortOrder || m.Archived != previous.Archived
+}
@@ -2,6 +2,9 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,29 +15,57 @@ type GroceryItem struct {
 	ID                string `json:"id" db:"id"`
 	Name              string `json:"name" db:"name"`
 	Category          string `json:"category" db:"category"`
+	CategoryID        string `json:"categoryId" db:"category_id"`
 	IsNeeded          bool   `json:"isNeeded" db:"is_needed"`
 	IsShoppingChecked bool   `json:"isShoppingChecked" db:"is_shopping_checked"`
-	GroupID           string `json:"groupId" db:"group_id"`
+	// Quantity is how much of the item is needed, counted in Unit if set
+	Quantity *float64 `json:"quantity" db:"quantity"`
+	Unit     *Unit    `json:"unit" db:"unit"`
+	Note     *string  `json:"note" db:"note"`
+	// Recurrence makes a staple needed again on a schedule, next at NextDueAt
+	Recurrence *Recurrence `json:"recurrence" db:"recurrence"`
+	NextDueAt  *time.Time  `json:"nextDueAt" db:"next_due_at"`
+	GroupID    string      `json:"groupId" db:"group_id"`
+	UpdatedAt  time.Time   `json:"updatedAt" db:"updated_at"`
+	ChangeSeq  int64       `json:"changeSeq" db:"change_seq"`
+	Version    int64       `json:"version" db:"version"`
 }
 
 // NewGroceryItem creates a new grocery item with a generated UUID
-func NewGroceryItem(name, category string, isNeeded, isShoppingChecked bool, groupID string) *GroceryItem {
+func NewGroceryItem(name string, category Category, isNeeded, isShoppingChecked bool, groupID string) *GroceryItem {
 	return &GroceryItem{
 		ID:                uuid.New().String(),
 		Name:              name,
-		Category:          category,
+		Category:          category.Name,
+		CategoryID:        category.ID,
 		IsNeeded:          isNeeded,
 		IsShoppingChecked: isShoppingChecked,
 		GroupID:           groupID,
 	}
 }
 
+// Validate normalizes the grocery item and reports invalid values.
+func (i *GroceryItem) Validate() error {
+	rejected := FieldErrors{}
+	trimRequired(&i.Name, "name", rejected)
+	normalizeQuantity(i.Quantity, rejected)
+	normalizeUnit(i.Unit, rejected)
+	normalizeNote(&i.Note)
+	if i.Recurrence != nil {
+		i.Recurrence.Validate(rejected)
+	}
+	return rejected.orNil()
+}
+
 // MealPlan represents a meal plan for a specific date
 type MealPlan struct {
 	ID              string    `json:"id" db:"id"`
 	Date            time.Time `json:"date" db:"date"`
 	MealDescription string    `json:"mealDescription" db:"meal_description"`
 	GroupID         string    `json:"groupId" db:"group_id"`
+	UpdatedAt       time.Time `json:"updatedAt" db:"updated_at"`
+	ChangeSeq       int64     `json:"changeSeq" db:"change_seq"`
+	Version         int64     `json:"version" db:"version"`
 }
 
 // MarshalJSON customizes JSON serialization to format date as YYYY-MM-DD
@@ -63,12 +94,24 @@ func NewMealPlan(date time.Time, mealDescription, groupID string) *MealPlan {
 type Receipt struct {
 	ID          string    `json:"id" db:"id"`
 	Date        time.Time `json:"date" db:"date"`
-	TotalAmount float64   `json:"totalAmount" db:"total_amount"`
-	PurchasedBy string    `json:"purchasedBy" db:"purchased_by"`
-	Items       string    `json:"-" db:"items"` // JSON string in database
-	ItemsList   []string  `json:"items" db:"-"` // For JSON serialization
-	Notes       *string   `json:"notes" db:"notes"`
-	GroupID     string    `json:"groupId" db:"group_id"`
+	TotalAmount Amount    `json:"totalAmount" db:"total_cents"`
+	Currency    string    `json:"currency" db:"currency"`
+	// PurchasedBy is the display name of the member PurchasedByID references
+	PurchasedByID string   `json:"purchasedById" db:"purchased_by_id"`
+	PurchasedBy   string   `json:"purchasedBy" db:"-"`
+	Items         string   `json:"-" db:"items"` // JSON string in database
+	ItemsList     []string `json:"items" db:"-"` // For JSON serialization
+	// LineItems is the itemized receipt. Items holds the same names and is kept
+	// for older clients.
+	LineItems []ReceiptLineItem `json:"lineItems" db:"-"`
+	// Splits weights how the total is shared between members. Empty means an
+	// equal split between the group's active members.
+	Splits    []ReceiptSplit `json:"splits" db:"splits"`
+	Notes     *string        `json:"notes" db:"notes"`
+	GroupID   string         `json:"groupId" db:"group_id"`
+	UpdatedAt time.Time      `json:"updatedAt" db:"updated_at"`
+	ChangeSeq int64          `json:"changeSeq" db:"change_seq"`
+	Version   int64          `json:"version" db:"version"`
 }
 
 // MarshalJSON customizes JSON serialization for Receipt
@@ -83,41 +126,113 @@ func (r Receipt) MarshalJSON() ([]byte, error) {
 	if items == nil {
 		items = []string{}
 	}
+	lineItems := r.LineItems
+	if lineItems == nil {
+		lineItems = []ReceiptLineItem{}
+	}
+	splits := r.Splits
+	if splits == nil {
+		splits = []ReceiptSplit{}
+	}
 
 	return json.Marshal(&struct {
-		Date  string   `json:"date"`
-		Items []string `json:"items"`
+		Date             string            `json:"date"`
+		Items            []string          `json:"items"`
+		LineItems        []ReceiptLineItem `json:"lineItems"`
+		Splits           []ReceiptSplit    `json:"splits"`
+		UnitemizedAmount Amount            `json:"unitemizedAmount"`
+		TotalAmountCents int64             `json:"totalAmountCents"`
 		*Alias
 	}{
-		Date:  r.Date.Format("2006-01-02"),
-		Items: items,
-		Alias: (*Alias)(&r),
+		Date:             r.Date.Format("2006-01-02"),
+		Items:            items,
+		LineItems:        lineItems,
+		Splits:           splits,
+		UnitemizedAmount: r.UnitemizedAmount(),
+		TotalAmountCents: int64(r.TotalAmount),
+		Alias:            (*Alias)(&r),
 	})
 }
 
-// NewReceipt creates a new receipt with a generated UUID
-func NewReceipt(date time.Time, totalAmount float64, purchasedBy string, items []string, notes *string, groupID string) *Receipt {
-	itemsJSON, _ := json.Marshal(items)
-	return &Receipt{
-		ID:          uuid.New().String(),
-		Date:        date,
-		TotalAmount: totalAmount,
-		PurchasedBy: purchasedBy,
-		Items:       string(itemsJSON),
-		ItemsList:   items,
-		Notes:       notes,
-		GroupID:     groupID,
+// NewReceipt creates a new receipt with a generated UUID and an unpriced line
+// item for each name in items
+func NewReceipt(date time.Time, totalAmount Amount, currency string, purchasedBy Member, items []string, notes *string, groupID string) *Receipt {
+	receipt := &Receipt{
+		ID:            uuid.New().String(),
+		Date:          date,
+		TotalAmount:   totalAmount,
+		Currency:      currency,
+		PurchasedByID: purchasedBy.ID,
+		PurchasedBy:   purchasedBy.DisplayName,
+		Notes:         notes,
+		GroupID:       groupID,
 	}
+	receipt.SetItems(items)
+	return receipt
 }
 
-// SetItems sets the items for a receipt (converts slice to JSON string)
+// SetItems sets the receipt's items from bare names, as sent by older clients.
+// Existing line items with a matching name are kept so their prices survive;
+// other names become unpriced line items.
 func (r *Receipt) SetItems(items []string) error {
-	itemsJSON, err := json.Marshal(items)
+	unused := slices.Clone(r.LineItems)
+	lines := make([]ReceiptLineItem, 0, len(items))
+	for _, name := range items {
+		if i := slices.IndexFunc(unused, func(line ReceiptLineItem) bool { return line.Name == name }); i >= 0 {
+			lines = append(lines, unused[i])
+			unused = slices.Delete(unused, i, i+1)
+			continue
+		}
+		lines = append(lines, ReceiptLineItem{Name: name, Quantity: 1})
+	}
+	return r.SetLineItems(lines)
+}
+
+// SetLineItems replaces the receipt's line items and the item names derived
+// from them. Lines without an ID get a new one.
+func (r *Receipt) SetLineItems(lines []ReceiptLineItem) error {
+	names := make([]string, len(lines))
+	for i := range lines {
+		if lines[i].ID == "" {
+			lines[i].ID = uuid.New().String()
+		}
+		if lines[i].Quantity == 0 {
+			lines[i].Quantity = 1
+		}
+		names[i] = lines[i].Name
+	}
+	itemsJSON, err := json.Marshal(names)
 	if err != nil {
 		return err
 	}
 	r.Items = string(itemsJSON)
-	r.ItemsList = items
+	r.ItemsList = names
+	r.LineItems = lines
+	return nil
+}
+
+// ItemizedAmount is the sum of the priced line items.
+func (r Receipt) ItemizedAmount() Amount {
+	var sum Amount
+	for _, line := range r.LineItems {
+		if line.UnitPrice != nil {
+			sum += Amount(line.Quantity) * *line.UnitPrice
+		}
+	}
+	return sum
+}
+
+// UnitemizedAmount is the part of the total not accounted for by priced line
+// items, e.g. deposits or items the client didn't itemize.
+func (r Receipt) UnitemizedAmount() Amount {
+	return r.TotalAmount - r.ItemizedAmount()
+}
+
+// ValidateLineItems rejects line items that add up to more than the total.
+func (r Receipt) ValidateLineItems() error {
+	if r.UnitemizedAmount() < 0 {
+		return FieldErrors{"lineItems": fmt.Sprintf("add up to %s, more than the total amount of %s", r.ItemizedAmount(), r.TotalAmount)}
+	}
 	return nil
 }
 
@@ -131,20 +246,62 @@ func (r *Receipt) GetItems() ([]string, error) {
 	return items, err
 }
 
+// ReceiptLineItem is one purchased item on a receipt. UnitPrice is nil when
+// only the name was recorded.
+type ReceiptLineItem struct {
+	ID            string  `json:"id" db:"id"`
+	Name          string  `json:"name" db:"name"`
+	Quantity      int     `json:"quantity" db:"quantity"`
+	UnitPrice     *Amount `json:"unitPrice" db:"unit_price_cents"`
+	GroceryItemID *string `json:"groceryItemId" db:"grocery_item_id"`
+	Category      *string `json:"category" db:"category"`
+}
+
+// ReceiptSplit is one member's weight in how a receipt's total is shared.
+// Clients may name the member by ID or by display name.
+type ReceiptSplit struct {
+	MemberID string `json:"memberId"`
+	// Member is the display name of the member MemberID references
+	Member string `json:"member"`
+	Weight int    `json:"weight"`
+}
+
 // Group represents a shared household or planning group
 type Group struct {
-	ID         string   `json:"id" db:"id"`
-	Name       string   `json:"name" db:"name"`
+	ID   string `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	// Categories are the names of the group's categories in sort order
 	Categories []string `json:"categories" db:"categories"`
-	Members    []string `json:"members" db:"members"`
+	// Members are the display names of the group's active members in order
+	Members  []string `json:"members" db:"members"`
+	Currency string   `json:"currency" db:"currency"`
+	// RequiresToken is false for groups from before access tokens that no
+	// device has claimed yet; those still accept a bare group ID.
+	RequiresToken bool  `json:"requiresToken" db:"requires_token"`
+	Version       int64 `json:"version" db:"version"`
 }
 
 // NewGroup creates a new group with a generated UUID
 func NewGroup(name string) *Group {
 	return &Group{
-		ID:         uuid.New().String(),
-		Name:       name,
-		Categories: []string{"Essentials", "Protein", "Veggies", "Carbs", "Household", "Other"},
-		Members:    []string{"Default"},
+		ID:            uuid.New().String(),
+		Name:          name,
+		Categories:    []string{"Essentials", "Protein", "Veggies", "Carbs", "Household", "Other"},
+		Members:       []string{"Default"},
+		Currency:      DefaultCurrency,
+		RequiresToken: true,
+		Version:       1,
+	}
+}
+
+// CanonicalCategory returns the spelling of name in the group's categories
+// list, matching case-insensitively, and whether it is one of them at all.
+func (g Group) CanonicalCategory(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	for _, value := range g.Categories {
+		if strings.EqualFold(value, name) {
+			return value, true
+		}
 	}
+	return name, false
 }
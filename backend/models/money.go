@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// DefaultCurrency is the currency of groups that haven't picked one.
+const DefaultCurrency = "EUR"
+
+// Amount is a sum of money in cents, i.e. hundredths of the currency unit.
+// Currencies are limited to those with two decimal places to match.
+// JSON carries it as a decimal number of whole units (12.5 for 1250 cents),
+// which is parsed exactly instead of going through float64.
+type Amount int64
+
+// ParseAmount parses a decimal number of whole units with at most two decimal
+// places.
+func ParseAmount(value string) (Amount, error) {
+	units, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return 0, fmt.Errorf("must be an amount of money")
+	}
+	cents := units.Mul(units, big.NewRat(100, 1))
+	if !cents.IsInt() {
+		return 0, fmt.Errorf("must not have more than two decimal places")
+	}
+	if !cents.Num().IsInt64() {
+		return 0, fmt.Errorf("is too large")
+	}
+	return Amount(cents.Num().Int64()), nil
+}
+
+// String formats the amount as whole units with two decimal places.
+func (a Amount) String() string {
+	sign := ""
+	cents := int64(a)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "" || value[0] == '"' {
+		return fmt.Errorf("must be a number")
+	}
+	parsed, err := ParseAmount(value)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// InvalidCurrencyReason is the FieldErrors reason for an unsupported currency code.
+const InvalidCurrencyReason = "must be an ISO 4217 currency code with two decimal places, like EUR"
+
+// centCurrencies are the ISO 4217 currencies divided into hundredths, the
+// only ones Amount can hold. Currencies without a minor unit, like JPY, or
+// with three decimal places, like KWD, aren't supported.
+var centCurrencies = map[string]struct{}{
+	"AED": {}, "AFN": {}, "ALL": {}, "AMD": {}, "ANG": {}, "AOA": {}, "ARS": {}, "AUD": {}, "AWG": {}, "AZN": {},
+	"BAM": {}, "BBD": {}, "BDT": {}, "BGN": {}, "BMD": {}, "BND": {}, "BOB": {}, "BOV": {}, "BRL": {}, "BSD": {},
+	"BTN": {}, "BWP": {}, "BYN": {}, "BZD": {}, "CAD": {}, "CDF": {}, "CHE": {}, "CHF": {}, "CHW": {}, "CNY": {},
+	"COP": {}, "COU": {}, "CRC": {}, "CUP": {}, "CVE": {}, "CZK": {}, "DKK": {}, "DOP": {}, "DZD": {}, "EGP": {},
+	"ERN": {}, "ETB": {}, "EUR": {}, "FJD": {}, "FKP": {}, "GBP": {}, "GEL": {}, "GHS": {}, "GIP": {}, "GMD": {},
+	"GTQ": {}, "GYD": {}, "HKD": {}, "HNL": {}, "HTG": {}, "HUF": {}, "IDR": {}, "ILS": {}, "INR": {}, "IRR": {},
+	"JMD": {}, "KES": {}, "KGS": {}, "KHR": {}, "KPW": {}, "KYD": {}, "KZT": {}, "LAK": {}, "LBP": {}, "LKR": {},
+	"LRD": {}, "LSL": {}, "MAD": {}, "MDL": {}, "MGA": {}, "MKD": {}, "MMK": {}, "MNT": {}, "MOP": {}, "MRU": {},
+	"MUR": {}, "MVR": {}, "MWK": {}, "MXN": {}, "MXV": {}, "MYR": {}, "MZN": {}, "NAD": {}, "NGN": {}, "NIO": {},
+	"NOK": {}, "NPR": {}, "NZD": {}, "PAB": {}, "PEN": {}, "PGK": {}, "PHP": {}, "PKR": {}, "PLN": {}, "QAR": {},
+	"RON": {}, "RSD": {}, "RUB": {}, "SAR": {}, "SBD": {}, "SCR": {}, "SDG": {}, "SEK": {}, "SGD": {}, "SHP": {},
+	"SLE": {}, "SOS": {}, "SRD": {}, "SSP": {}, "STN": {}, "SVC": {}, "SYP": {}, "SZL": {}, "THB": {}, "TJS": {},
+	"TMT": {}, "TOP": {}, "TRY": {}, "TTD": {}, "TWD": {}, "TZS": {}, "UAH": {}, "USD": {}, "USN": {}, "UYU": {},
+	"UZS": {}, "VED": {}, "VES": {}, "WST": {}, "XCD": {}, "XCG": {}, "YER": {}, "ZAR": {}, "ZMW": {}, "ZWG": {},
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 currency code and reports whether
+// it is one amounts can be kept in.
+func NormalizeCurrency(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	_, ok := centCurrencies[code]
+	return code, ok
+}
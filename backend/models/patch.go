@@ -0,0 +1,634 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldErrors maps a rejected JSON field name to the reason it was rejected.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return "invalid fields: " + strings.Join(fields, ", ")
+}
+
+// orNil lets Validate methods return a nil error when nothing was rejected.
+func (e FieldErrors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Date is a calendar date that clients send as YYYY-MM-DD.
+type Date struct {
+	time.Time
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("must be a date formatted as YYYY-MM-DD")
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return fmt.Errorf("must be a date formatted as YYYY-MM-DD")
+	}
+	d.Time = parsed
+	return nil
+}
+
+// Nullable marks a patch field that may be cleared by sending null. A present
+// field with a nil Value means "set to NULL".
+type Nullable[T any] struct {
+	Value *T
+}
+
+func (Nullable[T]) nullable() {}
+
+type nullableField interface{ nullable() }
+
+// DecodePatch decodes a JSON object into dst, which must point to a patch
+// struct whose fields are all pointers tagged with their JSON name. Only those
+// names are accepted: unknown keys and values of the wrong type are collected
+// into FieldErrors instead of being passed through to the database.
+func DecodePatch(body []byte, dst any) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil || raw == nil {
+		return fmt.Errorf("request body must be a JSON object")
+	}
+
+	target := reflect.ValueOf(dst).Elem()
+	fieldsByName := map[string]reflect.Value{}
+	for i := 0; i < target.NumField(); i++ {
+		name, _, _ := strings.Cut(target.Type().Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fieldsByName[name] = target.Field(i)
+		}
+	}
+
+	rejected := FieldErrors{}
+	for key, value := range raw {
+		field, ok := fieldsByName[key]
+		if !ok {
+			rejected[key] = "field cannot be updated"
+			continue
+		}
+
+		elemType := field.Type().Elem()
+		decoded := reflect.New(elemType)
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			if _, ok := decoded.Interface().(nullableField); !ok {
+				rejected[key] = "must not be null"
+				continue
+			}
+			field.Set(decoded)
+			continue
+		}
+
+		if _, ok := decoded.Interface().(nullableField); ok {
+			// Decode into Nullable[T].Value
+			inner := reflect.New(elemType.Field(0).Type.Elem())
+			if err := json.Unmarshal(value, inner.Interface()); err != nil {
+				rejected[key] = describeDecodeError(err)
+				continue
+			}
+			decoded.Elem().Field(0).Set(inner)
+		} else if err := json.Unmarshal(value, decoded.Interface()); err != nil {
+			rejected[key] = describeDecodeError(err)
+			continue
+		}
+		field.Set(decoded)
+	}
+
+	return rejected.orNil()
+}
+
+func describeDecodeError(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		switch typeErr.Type.Kind() {
+		case reflect.String:
+			return "must be a string"
+		case reflect.Bool:
+			return "must be a boolean"
+		case reflect.Int, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+			return "must be a number"
+		case reflect.Slice:
+			return "must be a list"
+		}
+		return "has the wrong type"
+	}
+	return err.Error()
+}
+
+// trimRequired trims a patched string in place and rejects it if it ends up empty.
+func trimRequired(value *string, field string, rejected FieldErrors) {
+	if value == nil {
+		return
+	}
+	*value = strings.TrimSpace(*value)
+	if *value == "" {
+		rejected[field] = "must not be empty"
+	}
+}
+
+// normalizeCurrency upper-cases a patched currency code in place and rejects
+// it if it isn't an ISO 4217 code.
+func normalizeCurrency(value *string, rejected FieldErrors) {
+	if value == nil {
+		return
+	}
+	var ok bool
+	if *value, ok = NormalizeCurrency(*value); !ok {
+		rejected["currency"] = InvalidCurrencyReason
+	}
+}
+
+// GroceryItemPatch lists the grocery item fields a client may change.
+type GroceryItemPatch struct {
+	Name              *string               `json:"name"`
+	Category          *string               `json:"category"`
+	CategoryID        *string               `json:"categoryId"`
+	IsNeeded          *bool                 `json:"isNeeded"`
+	IsShoppingChecked *bool                 `json:"isShoppingChecked"`
+	Quantity          *Nullable[float64]    `json:"quantity"`
+	Unit              *Nullable[Unit]       `json:"unit"`
+	Note              *Nullable[string]     `json:"note"`
+	Recurrence        *Nullable[Recurrence] `json:"recurrence"`
+	// Version is the optimistic concurrency precondition rather than a field
+	// to set, here and on the other patch types. IsEmpty ignores it.
+	Version *int64 `json:"version"`
+}
+
+// Validate normalizes the patch and reports invalid values.
+func (p *GroceryItemPatch) Validate() error {
+	rejected := FieldErrors{}
+	trimRequired(p.Name, "name", rejected)
+	trimRequired(p.Category, "category", rejected)
+	trimRequired(p.CategoryID, "categoryId", rejected)
+	if p.Quantity != nil {
+		normalizeQuantity(p.Quantity.Value, rejected)
+	}
+	if p.Unit != nil {
+		normalizeUnit(p.Unit.Value, rejected)
+	}
+	if p.Note != nil {
+		normalizeNote(&p.Note.Value)
+	}
+	if p.Recurrence != nil && p.Recurrence.Value != nil {
+		p.Recurrence.Value.Validate(rejected)
+	}
+	return rejected.orNil()
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p GroceryItemPatch) IsEmpty() bool {
+	p.Version = nil
+	return p == GroceryItemPatch{}
+}
+
+// Apply copies the patched fields onto item. A new recurrence counts from now.
+func (p GroceryItemPatch) Apply(item *GroceryItem) {
+	if p.Name != nil {
+		item.Name = *p.Name
+	}
+	if p.Category != nil {
+		item.Category = *p.Category
+	}
+	if p.CategoryID != nil {
+		item.CategoryID = *p.CategoryID
+	}
+	if p.IsNeeded != nil {
+		item.IsNeeded = *p.IsNeeded
+	}
+	if p.IsShoppingChecked != nil {
+		item.IsShoppingChecked = *p.IsShoppingChecked
+	}
+	if p.Quantity != nil {
+		item.Quantity = p.Quantity.Value
+	}
+	if p.Unit != nil {
+		item.Unit = p.Unit.Value
+	}
+	if p.Note != nil {
+		item.Note = p.Note.Value
+	}
+	if p.Recurrence != nil {
+		item.Recurrence = p.Recurrence.Value
+		item.NextDueAt = p.Recurrence.Value.FirstDue(time.Now())
+	}
+}
+
+// MealPlanPatch lists the meal plan fields a client may change.
+type MealPlanPatch struct {
+	Date            *Date   `json:"date"`
+	MealDescription *string `json:"mealDescription"`
+	Version         *int64  `json:"version"`
+}
+
+// Validate normalizes the patch and reports invalid values.
+func (p *MealPlanPatch) Validate() error {
+	rejected := FieldErrors{}
+	trimRequired(p.MealDescription, "mealDescription", rejected)
+	return rejected.orNil()
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p MealPlanPatch) IsEmpty() bool {
+	p.Version = nil
+	return p == MealPlanPatch{}
+}
+
+// Apply copies the patched fields onto meal.
+func (p MealPlanPatch) Apply(meal *MealPlan) {
+	if p.Date != nil {
+		meal.Date = p.Date.Time
+	}
+	if p.MealDescription != nil {
+		meal.MealDescription = *p.MealDescription
+	}
+}
+
+// ReceiptPatch lists the receipt fields a client may change.
+type ReceiptPatch struct {
+	Date        *Date   `json:"date"`
+	TotalAmount *Amount `json:"totalAmount"`
+	Currency    *string `json:"currency"`
+	PurchasedBy *string `json:"purchasedBy"`
+	// PurchasedByID names the purchaser by ID instead of by display name
+	PurchasedByID *string            `json:"purchasedById"`
+	Items         *[]string          `json:"items"`
+	LineItems     *[]ReceiptLineItem `json:"lineItems"`
+	Splits        *[]ReceiptSplit    `json:"splits"`
+	Notes         *Nullable[string]  `json:"notes"`
+	Version       *int64             `json:"version"`
+}
+
+// Validate normalizes the patch and reports invalid values.
+func (p *ReceiptPatch) Validate() error {
+	rejected := FieldErrors{}
+	if p.TotalAmount != nil && *p.TotalAmount < 0 {
+		rejected["totalAmount"] = "must not be negative"
+	}
+	normalizeCurrency(p.Currency, rejected)
+	trimRequired(p.PurchasedBy, "purchasedBy", rejected)
+	trimRequired(p.PurchasedByID, "purchasedById", rejected)
+	if p.Items != nil {
+		for i, name := range *p.Items {
+			(*p.Items)[i] = strings.TrimSpace(name)
+			if (*p.Items)[i] == "" {
+				rejected["items"] = "must not contain empty names"
+			}
+		}
+	}
+	if p.LineItems != nil {
+		if p.Items != nil {
+			rejected["items"] = "cannot be combined with lineItems"
+		}
+		if reason := NormalizeLineItems(*p.LineItems); reason != "" {
+			rejected["lineItems"] = reason
+		}
+	}
+	if p.Splits != nil {
+		if reason := NormalizeSplits(*p.Splits); reason != "" {
+			rejected["splits"] = reason
+		}
+	}
+	return rejected.orNil()
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p ReceiptPatch) IsEmpty() bool {
+	p.Version = nil
+	return p == ReceiptPatch{}
+}
+
+// Apply copies the patched fields onto receipt.
+func (p ReceiptPatch) Apply(receipt *Receipt) error {
+	if p.Date != nil {
+		receipt.Date = p.Date.Time
+	}
+	if p.TotalAmount != nil {
+		receipt.TotalAmount = *p.TotalAmount
+	}
+	if p.Currency != nil {
+		receipt.Currency = *p.Currency
+	}
+	if p.PurchasedByID != nil {
+		receipt.PurchasedByID = *p.PurchasedByID
+	}
+	if p.PurchasedBy != nil {
+		receipt.PurchasedBy = *p.PurchasedBy
+	}
+	if p.Items != nil {
+		if err := receipt.SetItems(*p.Items); err != nil {
+			return err
+		}
+	}
+	if p.LineItems != nil {
+		if err := receipt.SetLineItems(slices.Clone(*p.LineItems)); err != nil {
+			return err
+		}
+	}
+	if p.Splits != nil {
+		receipt.Splits = slices.Clone(*p.Splits)
+	}
+	if p.Notes != nil {
+		receipt.Notes = p.Notes.Value
+	}
+	return receipt.ValidateLineItems()
+}
+
+// NormalizeLineItems trims and defaults client supplied line items in place
+// and returns the reason they are invalid, if any. IDs are cleared because
+// line items are always replaced as a whole.
+func NormalizeLineItems(lines []ReceiptLineItem) string {
+	for i := range lines {
+		line := &lines[i]
+		line.ID = ""
+		line.Name = strings.TrimSpace(line.Name)
+		if line.Name == "" {
+			return "must not contain empty names"
+		}
+		if line.Quantity < 0 {
+			return "quantities must be positive"
+		}
+		if line.UnitPrice != nil && *line.UnitPrice < 0 {
+			return "unit prices must not be negative"
+		}
+		line.GroceryItemID = trimOptional(line.GroceryItemID)
+		line.Category = trimOptional(line.Category)
+	}
+	return ""
+}
+
+// NormalizeSplits trims the member IDs and names of client supplied splits in
+// place and returns the reason they are invalid, if any. Whether a member is
+// listed twice can only be told once the names are resolved to members.
+func NormalizeSplits(splits []ReceiptSplit) string {
+	for i := range splits {
+		split := &splits[i]
+		split.MemberID = strings.TrimSpace(split.MemberID)
+		split.Member = strings.TrimSpace(split.Member)
+		if split.MemberID == "" && split.Member == "" {
+			return "must not contain empty members"
+		}
+		if split.Weight <= 0 {
+			return "weights must be positive"
+		}
+	}
+	return ""
+}
+
+// trimOptional trims an optional string, treating blank as absent.
+func trimOptional(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// SettlementPatch lists the settlement fields a client may change.
+type SettlementPatch struct {
+	Date     *Date             `json:"date"`
+	PaidBy   *string           `json:"paidBy"`
+	PaidTo   *string           `json:"paidTo"`
+	PaidByID *string           `json:"paidById"`
+	PaidToID *string           `json:"paidToId"`
+	Amount   *Amount           `json:"amount"`
+	Currency *string           `json:"currency"`
+	Notes    *Nullable[string] `json:"notes"`
+	Version  *int64            `json:"version"`
+}
+
+// Validate normalizes the patch and reports invalid values.
+func (p *SettlementPatch) Validate() error {
+	rejected := FieldErrors{}
+	trimRequired(p.PaidBy, "paidBy", rejected)
+	trimRequired(p.PaidTo, "paidTo", rejected)
+	trimRequired(p.PaidByID, "paidById", rejected)
+	trimRequired(p.PaidToID, "paidToId", rejected)
+	if p.Amount != nil && *p.Amount <= 0 {
+		rejected["amount"] = "must be positive"
+	}
+	normalizeCurrency(p.Currency, rejected)
+	return rejected.orNil()
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p SettlementPatch) IsEmpty() bool {
+	p.Version = nil
+	return p == SettlementPatch{}
+}
+
+// Apply copies the patched fields onto settlement and checks the result.
+func (p SettlementPatch) Apply(settlement *Settlement) error {
+	if p.Date != nil {
+		settlement.Date = p.Date.Time
+	}
+	if p.PaidBy != nil {
+		settlement.PaidBy = *p.PaidBy
+	}
+	if p.PaidTo != nil {
+		settlement.PaidTo = *p.PaidTo
+	}
+	if p.PaidByID != nil {
+		settlement.PaidByID = *p.PaidByID
+	}
+	if p.PaidToID != nil {
+		settlement.PaidToID = *p.PaidToID
+	}
+	if p.Amount != nil {
+		settlement.Amount = *p.Amount
+	}
+	if p.Currency != nil {
+		settlement.Currency = *p.Currency
+	}
+	if p.Notes != nil {
+		settlement.Notes = p.Notes.Value
+	}
+	return settlement.Validate()
+}
+
+// MemberPatch lists the member fields a client may change.
+type MemberPatch struct {
+	DisplayName *string           `json:"displayName"`
+	Color       *Nullable[string] `json:"color"`
+	Archived    *bool             `json:"archived"`
+	SortOrder   *int              `json:"sortOrder"`
+	Version     *int64            `json:"version"`
+}
+
+// Validate normalizes the patch and reports invalid values.
+func (p *MemberPatch) Validate() error {
+	rejected := FieldErrors{}
+	trimRequired(p.DisplayName, "displayName", rejected)
+	if p.Color != nil {
+		validateColor(p.Color.Value, rejected)
+	}
+	return rejected.orNil()
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p MemberPatch) IsEmpty() bool {
+	p.Version = nil
+	return p == MemberPatch{}
+}
+
+// Apply copies the patched fields onto member.
+func (p MemberPatch) Apply(member *Member) {
+	if p.DisplayName != nil {
+		member.DisplayName = *p.DisplayName
+	}
+	if p.Color != nil {
+		member.Color = p.Color.Value
+	}
+	if p.Archived != nil {
+		member.Archived = *p.Archived
+	}
+	if p.SortOrder != nil {
+		member.SortOrder = *p.SortOrder
+	}
+}
+
+// GroupPatch lists the group fields a client may change.
+type GroupPatch struct {
+	Name       *string   `json:"name"`
+	Categories *[]string `json:"categories"`
+	Members    *[]string `json:"members"`
+	Currency   *string   `json:"currency"`
+	// RenameCategory renames one category and every grocery item in it.
+	RenameCategory *CategoryRename `json:"renameCategory"`
+	// CategoryPolicy says what happens to grocery items whose category is
+	// removed from Categories. Like Version it isn't a field to set.
+	CategoryPolicy *CategoryPolicy `json:"categoryPolicy"`
+	Version        *int64          `json:"version"`
+}
+
+// Validate normalizes the patch and reports invalid values. Blank categories
+// and members are dropped rather than rejected.
+func (p *GroupPatch) Validate() error {
+	rejected := FieldErrors{}
+	trimRequired(p.Name, "name", rejected)
+	if p.Categories != nil {
+		*p.Categories = uniqueFold(normalizeGroupValues(*p.Categories))
+		if len(*p.Categories) == 0 {
+			rejected["categories"] = "must not be empty"
+		}
+	}
+	if p.RenameCategory != nil {
+		trimRequired(&p.RenameCategory.From, "renameCategory.from", rejected)
+		trimRequired(&p.RenameCategory.To, "renameCategory.to", rejected)
+		if p.Categories != nil {
+			rejected["renameCategory"] = "cannot be combined with categories"
+		}
+	}
+	if p.CategoryPolicy != nil && !p.CategoryPolicy.valid() {
+		rejected["categoryPolicy"] = "must be other, rename or reject"
+	}
+	if p.Members != nil {
+		*p.Members = uniqueFold(normalizeGroupValues(*p.Members))
+	}
+	normalizeCurrency(p.Currency, rejected)
+	return rejected.orNil()
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p GroupPatch) IsEmpty() bool {
+	p.Version, p.CategoryPolicy = nil, nil
+	return p == GroupPatch{}
+}
+
+// Apply copies the patched fields onto group. Categories are changed through
+// Recategorize and members through PlanMemberChanges instead.
+func (p GroupPatch) Apply(group *Group) {
+	if p.Name != nil {
+		group.Name = *p.Name
+	}
+	if p.Currency != nil {
+		group.Currency = *p.Currency
+	}
+}
+
+func normalizeGroupValues(values []string) []string {
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		trimmed := strings.TrimSpace(value)
+		if trimmed != "" {
+			normalized = append(normalized, trimmed)
+		}
+	}
+	return normalized
+}
+
+// uniqueFold drops values that repeat an earlier one, ignoring case.
+func uniqueFold(values []string) []string {
+	unique := values[:0]
+	for _, value := range values {
+		if !slices.ContainsFunc(unique, func(u string) bool { return strings.EqualFold(u, value) }) {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// CategoryPatch lists the category fields a client may change.
+type CategoryPatch struct {
+	Name      *string           `json:"name"`
+	SortOrder *int              `json:"sortOrder"`
+	Color     *Nullable[string] `json:"color"`
+	Icon      *Nullable[string] `json:"icon"`
+	Version   *int64            `json:"version"`
+}
+
+// Validate normalizes the patch and reports invalid values.
+func (p *CategoryPatch) Validate() error {
+	rejected := FieldErrors{}
+	trimRequired(p.Name, "name", rejected)
+	if p.Color != nil {
+		validateColor(p.Color.Value, rejected)
+	}
+	if p.Icon != nil {
+		validateIcon(p.Icon.Value, rejected)
+	}
+	return rejected.orNil()
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p CategoryPatch) IsEmpty() bool {
+	p.Version = nil
+	return p == CategoryPatch{}
+}
+
+// Apply copies the patched fields onto category.
+func (p CategoryPatch) Apply(category *Category) {
+	if p.Name != nil {
+		category.Name = *p.Name
+	}
+	if p.SortOrder != nil {
+		category.SortOrder = *p.SortOrder
+	}
+	if p.Color != nil {
+		category.Color = p.Color.Value
+	}
+	if p.Icon != nil {
+		category.Icon = p.Icon.Value
+	}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestDecodePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		rejected FieldErrors
+		check    func(t *testing.T, patch GroceryItemPatch)
+	}{
+		{
+			name: "known fields",
+			body: `{"name": "Milk", "isNeeded": false, "quantity": 2, "version": 3}`,
+			check: func(t *testing.T, patch GroceryItemPatch) {
+				if *patch.Name != "Milk" || *patch.IsNeeded || *patch.Quantity.Value != 2 || *patch.Version != 3 {
+					t.Errorf("decoded %+v", patch)
+				}
+				if patch.Category != nil || patch.Note != nil {
+					t.Error("fields missing from the body were set")
+				}
+			},
+		},
+		{
+			name: "null clears a nullable field",
+			body: `{"note": null, "unit": null}`,
+			check: func(t *testing.T, patch GroceryItemPatch) {
+				if patch.Note == nil || patch.Note.Value != nil || patch.Unit == nil || patch.Unit.Value != nil {
+					t.Errorf("note = %+v, unit = %+v; want both present and nil", patch.Note, patch.Unit)
+				}
+			},
+		},
+		{
+			name: "nested value",
+			body: `{"recurrence": {"weekdays": ["monday"]}}`,
+			check: func(t *testing.T, patch GroceryItemPatch) {
+				if patch.Recurrence == nil || patch.Recurrence.Value == nil || patch.Recurrence.Value.Weekdays[0] != "monday" {
+					t.Errorf("recurrence = %+v", patch.Recurrence)
+				}
+			},
+		},
+		{
+			name:     "unknown fields",
+			body:     `{"name": "Milk", "groupId": "other", "id": "x"}`,
+			rejected: FieldErrors{"groupId": "field cannot be updated", "id": "field cannot be updated"},
+		},
+		{
+			name:     "null for a field that can't be cleared",
+			body:     `{"name": null}`,
+			rejected: FieldErrors{"name": "must not be null"},
+		},
+		{
+			name:     "wrong types",
+			body:     `{"name": 5, "isNeeded": "yes", "quantity": "two", "version": true}`,
+			rejected: FieldErrors{"name": "must be a string", "isNeeded": "must be a boolean", "quantity": "must be a number", "version": "must be a number"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch GroceryItemPatch
+			err := DecodePatch([]byte(tt.body), &patch)
+
+			var rejected FieldErrors
+			errors.As(err, &rejected)
+			if !maps.Equal(rejected, tt.rejected) {
+				t.Fatalf("rejected = %v, want %v", rejected, tt.rejected)
+			}
+			if tt.check != nil {
+				tt.check(t, patch)
+			}
+		})
+	}
+}
+
+func TestDecodePatchNullable(t *testing.T) {
+	var cleared ReceiptPatch
+	if err := DecodePatch([]byte(`{"notes": null}`), &cleared); err != nil {
+		t.Fatal(err)
+	}
+	if cleared.Notes == nil || cleared.Notes.Value != nil {
+		t.Errorf("notes = %+v, want present and nil", cleared.Notes)
+	}
+
+	var set ReceiptPatch
+	if err := DecodePatch([]byte(`{"notes": "cash"}`), &set); err != nil {
+		t.Fatal(err)
+	}
+	if set.Notes == nil || set.Notes.Value == nil || *set.Notes.Value != "cash" {
+		t.Errorf("notes = %+v, want cash", set.Notes)
+	}
+
+	var rejected FieldErrors
+	err := DecodePatch([]byte(`{"notes": 1, "items": "milk"}`), &set)
+	if !errors.As(err, &rejected) || !maps.Equal(rejected, FieldErrors{"notes": "must be a string", "items": "must be a list"}) {
+		t.Errorf("wrong types were rejected with %v", err)
+	}
+}
+
+func TestDecodePatchRejectsNonObjects(t *testing.T) {
+	for _, body := range []string{``, `null`, `[]`, `"name"`, `{"name":`} {
+		var patch GroceryItemPatch
+		err := DecodePatch([]byte(body), &patch)
+		var rejected FieldErrors
+		if err == nil || errors.As(err, &rejected) {
+			t.Errorf("DecodePatch(%q) = %v, want a plain error", body, err)
+		}
+	}
+}
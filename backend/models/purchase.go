@@ -0,0 +1,135 @@
+package models
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GroceryPurchase records a grocery item being bought, when a receipt marks
+// it as bought.
+type GroceryPurchase struct {
+	ID            string `json:"id" db:"id"`
+	GroceryItemID string `json:"groceryItemId" db:"grocery_item_id"`
+	// ReceiptID is nil once the receipt is deleted
+	ReceiptID   *string   `json:"receiptId" db:"receipt_id"`
+	MemberID    string    `json:"memberId" db:"member_id"`
+	PurchasedAt time.Time `json:"purchasedAt" db:"purchased_at"`
+	GroupID     string    `json:"groupId" db:"group_id"`
+}
+
+// NewGroceryPurchase records that receipt bought the grocery item with the
+// given ID, on the receipt's date. Receipts dated today use the time now is,
+// while receipts entered later only know the day.
+func NewGroceryPurchase(groceryItemID string, receipt Receipt, now time.Time) GroceryPurchase {
+	now = now.UTC()
+	year, month, day := receipt.Date.UTC().Date()
+	purchasedAt := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if nowYear, nowMonth, nowDay := now.Date(); nowYear == year && nowMonth == month && nowDay == day {
+		purchasedAt = now
+	}
+	return GroceryPurchase{
+		ID:            uuid.New().String(),
+		GroceryItemID: groceryItemID,
+		ReceiptID:     &receipt.ID,
+		MemberID:      receipt.PurchasedByID,
+		PurchasedAt:   purchasedAt,
+		GroupID:       receipt.GroupID,
+	}
+}
+
+const (
+	// SuggestionHistory is how far back purchases count towards suggestions.
+	SuggestionHistory = 365 * 24 * time.Hour
+	// minSuggestionPurchases is how often an item must have been bought
+	// before it has a usual interval.
+	minSuggestionPurchases = 3
+	// minPurchaseGap is how far apart purchases must be to count separately,
+	// so an item bought twice on one trip doesn't shorten its interval.
+	minPurchaseGap = 12 * time.Hour
+	// minSuggestionScore is how far into its usual interval an item must be
+	// to be suggested.
+	minSuggestionScore = 0.8
+)
+
+// GrocerySuggestion is a grocery item that will likely be needed soon.
+type GrocerySuggestion struct {
+	Item GroceryItem `json:"item"`
+	// IntervalDays is how many days usually pass between purchases
+	IntervalDays    float64   `json:"intervalDays"`
+	PurchaseCount   int       `json:"purchaseCount"`
+	LastPurchasedAt time.Time `json:"lastPurchasedAt"`
+	// DueAt is when the item would be bought next at its usual interval
+	DueAt time.Time `json:"dueAt"`
+	// Score is the time since the last purchase over the usual interval, so
+	// items past their usual interval score above 1
+	Score float64 `json:"score"`
+}
+
+// SuggestGroceryItems ranks the items that aren't on the list yet but are
+// likely to be needed soon, going by the median interval between their
+// purchases. The most overdue come first.
+func SuggestGroceryItems(items []GroceryItem, purchases []GroceryPurchase, now time.Time) []GrocerySuggestion {
+	times := map[string][]time.Time{}
+	for _, purchase := range purchases {
+		times[purchase.GroceryItemID] = append(times[purchase.GroceryItemID], purchase.PurchasedAt)
+	}
+
+	suggestions := []GrocerySuggestion{}
+	for _, item := range items {
+		if item.IsNeeded {
+			continue
+		}
+		trips := purchaseTrips(times[item.ID])
+		if len(trips) < minSuggestionPurchases {
+			continue
+		}
+
+		intervals := make([]time.Duration, len(trips)-1)
+		for i := range intervals {
+			intervals[i] = trips[i+1].Sub(trips[i])
+		}
+		slices.Sort(intervals)
+		interval := intervals[len(intervals)/2]
+		if len(intervals)%2 == 0 {
+			interval = (intervals[len(intervals)/2-1] + intervals[len(intervals)/2]) / 2
+		}
+
+		last := trips[len(trips)-1]
+		score := float64(now.Sub(last)) / float64(interval)
+		if score < minSuggestionScore {
+			continue
+		}
+		suggestions = append(suggestions, GrocerySuggestion{
+			Item:            item,
+			IntervalDays:    math.Round(interval.Hours()/24*10) / 10,
+			PurchaseCount:   len(trips),
+			LastPurchasedAt: last,
+			DueAt:           last.Add(interval),
+			Score:           math.Round(score*100) / 100,
+		})
+	}
+
+	slices.SortFunc(suggestions, func(a, b GrocerySuggestion) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), strings.Compare(a.Item.Name, b.Item.Name))
+	})
+	return suggestions
+}
+
+// purchaseTrips sorts purchase times and drops the ones that follow the
+// previous one within minPurchaseGap.
+func purchaseTrips(times []time.Time) []time.Time {
+	times = slices.Clone(times)
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	trips := []time.Time{}
+	for _, at := range times {
+		if len(trips) == 0 || at.Sub(trips[len(trips)-1]) >= minPurchaseGap {
+			trips = append(trips, at)
+		}
+	}
+	return trips
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuggestGroceryItems(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days ...float64) []time.Time {
+		times := make([]time.Time, len(days))
+		for i, d := range days {
+			times[i] = now.Add(-time.Duration(d * 24 * float64(time.Hour)))
+		}
+		return times
+	}
+
+	items := []GroceryItem{
+		// Weekly, and due today
+		{ID: "milk", Name: "Milk"},
+		// Every 10 days, and 2 days overdue
+		{ID: "coffee", Name: "Coffee"},
+		// Weekly and due today, like milk
+		{ID: "bread", Name: "Bread"},
+		// Only halfway into its interval
+		{ID: "rice", Name: "Rice"},
+		// Overdue, but already on the list
+		{ID: "eggs", Name: "Eggs", IsNeeded: true},
+		// Three purchases, but two on one trip
+		{ID: "salt", Name: "Salt"},
+		// Never bought
+		{ID: "tea", Name: "Tea"},
+	}
+	bought := map[string][]time.Time{
+		"milk":   daysAgo(7, 14, 21, 28),
+		"coffee": daysAgo(12, 22, 32),
+		"bread":  daysAgo(28, 21, 14, 7),
+		"rice":   daysAgo(10, 30, 50),
+		"eggs":   daysAgo(20, 27, 34),
+		"salt":   daysAgo(60, 60.2, 90),
+	}
+	var purchases []GroceryPurchase
+	for itemID, times := range bought {
+		for _, at := range times {
+			purchases = append(purchases, GroceryPurchase{GroceryItemID: itemID, PurchasedAt: at})
+		}
+	}
+
+	suggestions := SuggestGroceryItems(items, purchases, now)
+
+	want := []struct {
+		id       string
+		interval float64
+		count    int
+		lastAgo  float64
+		score    float64
+	}{
+		{"coffee", 10, 3, 12, 1.2},
+		{"bread", 7, 4, 7, 1},
+		{"milk", 7, 4, 7, 1},
+	}
+	if len(suggestions) != len(want) {
+		t.Fatalf("got %d suggestions %+v, want %d", len(suggestions), suggestions, len(want))
+	}
+	for i, w := range want {
+		s := suggestions[i]
+		if s.Item.ID != w.id || s.IntervalDays != w.interval || s.PurchaseCount != w.count || s.Score != w.score {
+			t.Errorf("suggestion %d = %s every %v days, %d purchases, score %v; want %+v", i, s.Item.ID, s.IntervalDays, s.PurchaseCount, s.Score, w)
+		}
+		if last := daysAgo(w.lastAgo)[0]; !s.LastPurchasedAt.Equal(last) {
+			t.Errorf("%s was last bought at %v, want %v", w.id, s.LastPurchasedAt, last)
+		}
+	}
+	if due := suggestions[0].DueAt; !due.Equal(now.Add(-2 * 24 * time.Hour)) {
+		t.Errorf("coffee is due at %v, want two days ago", due)
+	}
+}
+
+func TestSuggestGroceryItemsUsesMedianInterval(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	purchases := []GroceryPurchase{}
+	// Intervals of 2, 4, 6 and 30 days: an even count, so the median is 5
+	for _, ago := range []int{5, 7, 11, 17, 47} {
+		purchases = append(purchases, GroceryPurchase{GroceryItemID: "milk", PurchasedAt: now.Add(-time.Duration(ago) * day)})
+	}
+
+	suggestions := SuggestGroceryItems([]GroceryItem{{ID: "milk", Name: "Milk"}}, purchases, now)
+	if len(suggestions) != 1 || suggestions[0].IntervalDays != 5 || suggestions[0].Score != 1 {
+		t.Errorf("suggestions = %+v, want milk every 5 days", suggestions)
+	}
+}
+
+func TestNewGroceryPurchase(t *testing.T) {
+	now := time.Date(2026, 3, 31, 18, 30, 0, 0, time.UTC)
+	receipt := Receipt{ID: "r", PurchasedByID: "m", GroupID: "g"}
+
+	receipt.Date = time.Date(2026, 3, 28, 0, 0, 0, 0, time.UTC)
+	purchase := NewGroceryPurchase("milk", receipt, now)
+	if want := receipt.Date; !purchase.PurchasedAt.Equal(want) {
+		t.Errorf("purchase from an older receipt is at %v, want the receipt's date %v", purchase.PurchasedAt, want)
+	}
+	if purchase.GroceryItemID != "milk" || *purchase.ReceiptID != "r" || purchase.MemberID != "m" || purchase.GroupID != "g" {
+		t.Errorf("purchase = %+v", purchase)
+	}
+
+	receipt.Date = time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	if purchase := NewGroceryPurchase("milk", receipt, now); !purchase.PurchasedAt.Equal(now) {
+		t.Errorf("purchase from today's receipt is at %v, want now", purchase.PurchasedAt)
+	}
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Unit is the unit a grocery item's quantity is counted in.
+type Unit string
+
+const (
+	UnitGram       Unit = "g"
+	UnitKilogram   Unit = "kg"
+	UnitMilliliter Unit = "ml"
+	UnitLiter      Unit = "l"
+	UnitPieces     Unit = "pcs"
+	UnitPack       Unit = "pack"
+)
+
+// unitAliases maps the ways people write units, in lower case, to the unit.
+var unitAliases = map[string]Unit{
+	"g": UnitGram, "gr": UnitGram, "gram": UnitGram, "grams": UnitGram, "gramm": UnitGram,
+	"kg": UnitKilogram, "kgs": UnitKilogram, "kilo": UnitKilogram, "kilos": UnitKilogram,
+	"kilogram": UnitKilogram, "kilograms": UnitKilogram, "kilogramm": UnitKilogram,
+	"ml": UnitMilliliter, "milliliter": UnitMilliliter, "milliliters": UnitMilliliter,
+	"millilitre": UnitMilliliter, "millilitres": UnitMilliliter,
+	"l": UnitLiter, "liter": UnitLiter, "liters": UnitLiter, "litre": UnitLiter, "litres": UnitLiter,
+	"pcs": UnitPieces, "pc": UnitPieces, "piece": UnitPieces, "pieces": UnitPieces, "stk": UnitPieces, "stück": UnitPieces,
+	"pack": UnitPack, "packs": UnitPack, "pk": UnitPack, "pkg": UnitPack, "package": UnitPack,
+	"packages": UnitPack, "packung": UnitPack,
+}
+
+// NormalizeUnit maps a unit as typed by a user, like "Grams" or "pkg.", to
+// the unit it stands for and reports whether it is one.
+func NormalizeUnit(unit string) (Unit, bool) {
+	normalized, ok := unitAliases[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(unit)), ".")]
+	return normalized, ok
+}
+
+// maxQuantity is the largest quantity accepted, which the database column
+// can hold with three decimal places.
+const maxQuantity = 1e9
+
+// normalizeQuantity rounds an optional quantity to three decimal places in
+// place and rejects it unless it is positive.
+func normalizeQuantity(quantity *float64, rejected FieldErrors) {
+	if quantity == nil {
+		return
+	}
+	*quantity = math.Round(*quantity*1000) / 1000
+	if !(*quantity > 0 && *quantity < maxQuantity) {
+		rejected["quantity"] = "must be positive"
+	}
+}
+
+// normalizeUnit replaces an optional unit with its normalized form in place
+// and rejects it if it isn't one of the known units.
+func normalizeUnit(unit *Unit, rejected FieldErrors) {
+	if unit == nil {
+		return
+	}
+	normalized, ok := NormalizeUnit(string(*unit))
+	if !ok {
+		rejected["unit"] = "must be one of g, kg, ml, l, pcs or pack"
+		return
+	}
+	*unit = normalized
+}
+
+// normalizeNote trims an optional note in place, clearing it if it's blank.
+func normalizeNote(note **string) {
+	if *note == nil {
+		return
+	}
+	trimmed := strings.TrimSpace(**note)
+	if trimmed == "" {
+		*note = nil
+		return
+	}
+	*note = &trimmed
+}
+
+// quantityPattern matches an amount written before or after an item's name,
+// like "1L", "500 g", "x12", "12x" or "(2 packs)".
+var quantityPattern = func() string {
+	aliases := make([]string, 0, len(unitAliases))
+	for alias := range unitAliases {
+		aliases = append(aliases, regexp.QuoteMeta(alias))
+	}
+	// Longer aliases first so "kg" isn't read as "k" and "g"
+	slices.SortFunc(aliases, func(a, b string) int { return len(b) - len(a) })
+	number := `\d+(?:[.,]\d+)?`
+	return `\(?(?:x\s*` + number + `|` + number + `\s*x|` + number + `\s*(?:(?:` + strings.Join(aliases, "|") + `)\.?)?)\)?`
+}()
+
+var (
+	leadingQuantity  = regexp.MustCompile(`^` + quantityPattern + `\s+`)
+	trailingQuantity = regexp.MustCompile(`\s+` + quantityPattern + `$`)
+	spaces           = regexp.MustCompile(`\s+`)
+)
+
+// GroceryItemKey is what two grocery item names have to share to be the same
+// item: the name in lower case without an amount written before or after it,
+// so "Milk 1L", "milk" and "2x Milk" are all "milk".
+func GroceryItemKey(name string) string {
+	key := spaces.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), " ")
+	if stripped := trailingQuantity.ReplaceAllString(key, ""); stripped != "" {
+		key = stripped
+	}
+	if stripped := leadingQuantity.ReplaceAllString(key, ""); stripped != "" {
+		key = stripped
+	}
+	return key
+}
+
+// FindGroceryItem returns the item among items that name refers to, going by
+// GroceryItemKey, or nil.
+func FindGroceryItem(items []GroceryItem, name string) *GroceryItem {
+	key := GroceryItemKey(name)
+	for i := range items {
+		if GroceryItemKey(items[i].Name) == key {
+			return &items[i]
+		}
+	}
+	return nil
+}
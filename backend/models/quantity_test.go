@@ -0,0 +1,41 @@
+package models
+
+import "testing"
+
+func TestGroceryItemKey(t *testing.T) {
+	tests := map[string]string{
+		"Milk":             "milk",
+		"  Oat   Milk ":    "oat milk",
+		"Milk 1L":          "milk",
+		"milk 1.5 l":       "milk",
+		"2x Milk":          "milk",
+		"Milk x2":          "milk",
+		"Eggs (12)":        "eggs",
+		"500g Flour":       "flour",
+		"Flour 500 g":      "flour",
+		"Butter 250g.":     "butter",
+		"3 Avocados":       "avocados",
+		"Tomatoes 1,5 kg":  "tomatoes",
+		"7up":              "7up",
+		"Coke Zero":        "coke zero",
+		"12":               "12",
+		"Bread and butter": "bread and butter",
+	}
+
+	for name, want := range tests {
+		if got := GroceryItemKey(name); got != want {
+			t.Errorf("GroceryItemKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFindGroceryItem(t *testing.T) {
+	items := []GroceryItem{{ID: "1", Name: "Oat milk"}, {ID: "2", Name: "Milk 1L"}}
+
+	if found := FindGroceryItem(items, "2x milk"); found == nil || found.ID != "2" {
+		t.Errorf("FindGroceryItem(2x milk) = %+v, want item 2", found)
+	}
+	if found := FindGroceryItem(items, "Soy milk"); found != nil {
+		t.Errorf("FindGroceryItem(Soy milk) = %+v, want none", found)
+	}
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Recurrence is how often a staple grocery item becomes needed again: every
+// EveryDays days, or weekly on Weekdays. Items come due at midnight in
+// TimeZone.
+type Recurrence struct {
+	EveryDays int `json:"everyDays,omitempty"`
+	// Weekdays are lower case English day names, like "monday"
+	Weekdays []string `json:"weekdays,omitempty"`
+	// TimeZone is an IANA time zone name, UTC by default
+	TimeZone string `json:"timeZone"`
+}
+
+// maxEveryDays is the longest interval a recurrence may have.
+const maxEveryDays = 366
+
+var weekdayNames = func() map[string]time.Weekday {
+	names := map[string]time.Weekday{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		names[strings.ToLower(day.String())] = day
+	}
+	return names
+}()
+
+// Validate normalizes the rule and reports invalid values. Weekdays may be
+// given in any case and are sorted from Sunday on.
+func (r *Recurrence) Validate(rejected FieldErrors) {
+	if r.EveryDays != 0 && len(r.Weekdays) > 0 || r.EveryDays == 0 && len(r.Weekdays) == 0 {
+		rejected["recurrence"] = "must have either everyDays or weekdays"
+		return
+	}
+	if r.EveryDays < 0 || r.EveryDays > maxEveryDays {
+		rejected["recurrence.everyDays"] = "must be between 1 and 366"
+	}
+
+	weekdays := make([]string, 0, len(r.Weekdays))
+	for _, name := range r.Weekdays {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := weekdayNames[name]; !ok {
+			rejected["recurrence.weekdays"] = "must be day names like monday"
+			return
+		}
+		if !slices.Contains(weekdays, name) {
+			weekdays = append(weekdays, name)
+		}
+	}
+	slices.SortFunc(weekdays, func(a, b string) int { return int(weekdayNames[a]) - int(weekdayNames[b]) })
+	r.Weekdays = weekdays
+
+	r.TimeZone = strings.TrimSpace(r.TimeZone)
+	if r.TimeZone == "" {
+		r.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(r.TimeZone); err != nil {
+		rejected["recurrence.timeZone"] = "must be an IANA time zone like Europe/Berlin"
+	}
+}
+
+// FirstDue returns when an item with the rule next comes due, counting from
+// now, or nil for no rule.
+func (r *Recurrence) FirstDue(now time.Time) *time.Time {
+	if r == nil {
+		return nil
+	}
+	next := r.NextDue(nil, now)
+	return &next
+}
+
+// NextDue returns the first time after now that an item last due at previous
+// comes due again. Every-N-days rules keep their rhythm from previous, even
+// if some due dates were missed; without a previous due date they count from
+// today.
+func (r Recurrence) NextDue(previous *time.Time, now time.Time) time.Time {
+	location, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+
+	if r.EveryDays > 0 {
+		next := today.AddDate(0, 0, r.EveryDays)
+		if previous != nil {
+			next = previous.In(location)
+			for !next.After(now) {
+				next = next.AddDate(0, 0, r.EveryDays)
+			}
+		}
+		return next.UTC()
+	}
+
+	for i := 1; i <= 7; i++ {
+		day := today.AddDate(0, 0, i)
+		if slices.Contains(r.Weekdays, strings.ToLower(day.Weekday().String())) {
+			return day.UTC()
+		}
+	}
+	return today.AddDate(0, 0, 7).UTC()
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceNextDue(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	at := func(year int, month time.Month, day, hour int, location *time.Location) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, location)
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		previous   *time.Time
+		now        time.Time
+		want       time.Time
+	}{
+		{
+			name:       "every days from today",
+			recurrence: Recurrence{EveryDays: 3, TimeZone: "UTC"},
+			now:        at(2026, 3, 10, 15, time.UTC),
+			want:       at(2026, 3, 13, 0, time.UTC),
+		},
+		{
+			name:       "every days at local midnight",
+			recurrence: Recurrence{EveryDays: 1, TimeZone: "Europe/Berlin"},
+			// Already March 11 in Berlin
+			now:  at(2026, 3, 10, 23, time.UTC),
+			want: at(2026, 3, 12, 0, berlin),
+		},
+		{
+			name:       "every days keeps its rhythm after missed dates",
+			recurrence: Recurrence{EveryDays: 7, TimeZone: "UTC"},
+			previous:   ptr(at(2026, 3, 1, 0, time.UTC)),
+			now:        at(2026, 3, 20, 9, time.UTC),
+			want:       at(2026, 3, 22, 0, time.UTC),
+		},
+		{
+			name:       "every days due exactly now moves on",
+			recurrence: Recurrence{EveryDays: 7, TimeZone: "UTC"},
+			previous:   ptr(at(2026, 3, 1, 0, time.UTC)),
+			now:        at(2026, 3, 8, 0, time.UTC),
+			want:       at(2026, 3, 15, 0, time.UTC),
+		},
+		{
+			name:       "every days across a daylight saving change",
+			recurrence: Recurrence{EveryDays: 7, TimeZone: "Europe/Berlin"},
+			previous:   ptr(at(2026, 3, 23, 0, berlin)),
+			now:        at(2026, 3, 28, 12, berlin),
+			want:       at(2026, 3, 30, 0, berlin),
+		},
+		{
+			name:       "next weekday",
+			recurrence: Recurrence{Weekdays: []string{"monday", "thursday"}, TimeZone: "UTC"},
+			// A Tuesday
+			now:  at(2026, 3, 10, 8, time.UTC),
+			want: at(2026, 3, 12, 0, time.UTC),
+		},
+		{
+			name:       "weekday is never today",
+			recurrence: Recurrence{Weekdays: []string{"tuesday"}, TimeZone: "UTC"},
+			now:        at(2026, 3, 10, 0, time.UTC),
+			want:       at(2026, 3, 17, 0, time.UTC),
+		},
+		{
+			name:       "weekday in the rule's time zone",
+			recurrence: Recurrence{Weekdays: []string{"thursday"}, TimeZone: "Europe/Berlin"},
+			// Wednesday in UTC, but already Thursday in Berlin
+			now:  at(2026, 3, 11, 23, time.UTC),
+			want: at(2026, 3, 19, 0, berlin),
+		},
+		{
+			name:       "unknown time zone falls back to UTC",
+			recurrence: Recurrence{EveryDays: 2, TimeZone: "Nowhere/Special"},
+			now:        at(2026, 3, 10, 15, time.UTC),
+			want:       at(2026, 3, 12, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.recurrence.NextDue(tt.previous, tt.now)
+			if !got.Equal(tt.want) {
+				t.Errorf("NextDue = %v, want %v", got, tt.want.UTC())
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("NextDue returned a time in %v, want UTC", got.Location())
+			}
+		})
+	}
+}
+
+func TestRecurrenceValidate(t *testing.T) {
+	recurrence := Recurrence{Weekdays: []string{" Friday", "monday", "MONDAY"}}
+	rejected := FieldErrors{}
+	recurrence.Validate(rejected)
+	if len(rejected) != 0 {
+		t.Fatalf("rejected %v", rejected)
+	}
+	if len(recurrence.Weekdays) != 2 || recurrence.Weekdays[0] != "monday" || recurrence.Weekdays[1] != "friday" || recurrence.TimeZone != "UTC" {
+		t.Errorf("normalized to %+v, want monday and friday in UTC", recurrence)
+	}
+
+	for field, invalid := range map[string]Recurrence{
+		"recurrence":           {},
+		"recurrence.everyDays": {EveryDays: 400},
+		"recurrence.weekdays":  {Weekdays: []string{"someday"}},
+		"recurrence.timeZone":  {EveryDays: 1, TimeZone: "Nowhere/Special"},
+	} {
+		rejected := FieldErrors{}
+		invalid.Validate(rejected)
+		if _, ok := rejected[field]; !ok {
+			t.Errorf("Validate(%+v) rejected %v, want %s", invalid, rejected, field)
+		}
+	}
+}
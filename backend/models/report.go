@@ -0,0 +1,203 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Ways a spending report can bucket receipts.
+const (
+	SpendingByMonth   = "month"
+	SpendingByMember  = "member"
+	SpendingByWeekday = "weekday"
+)
+
+// MaxSpendingReportYears is the longest span a spending report covers.
+// Monthly reports fill in empty months for at most that many years before
+// the last one, however far apart the dates are.
+const MaxSpendingReportYears = 10
+
+// SpendingTotal is the sum of a group's receipts for one date, purchaser and
+// currency. Stores return these and BuildSpendingReport buckets them.
+type SpendingTotal struct {
+	Date          time.Time
+	PurchasedByID string
+	Currency      string
+	Count         int
+	Total         Amount
+}
+
+// SpendingBucket is one row of a spending report. Buckets are split by
+// currency, so a month with receipts in two currencies has two buckets.
+type SpendingBucket struct {
+	// Key is the month, weekday or member's display name
+	Key string `json:"key"`
+	// MemberID is set on member buckets
+	MemberID string `json:"memberId,omitempty"`
+	Currency string `json:"currency"`
+	Total    Amount `json:"total"`
+	Count    int    `json:"count"`
+	Average  Amount `json:"average"`
+	// FormerMember marks member buckets for archived members
+	FormerMember bool `json:"formerMember,omitempty"`
+}
+
+func (b *SpendingBucket) add(total SpendingTotal) {
+	b.Total += total.Total
+	b.Count += total.Count
+}
+
+// SpendingReport is a group's spending between two optional dates.
+type SpendingReport struct {
+	GroupBy string           `json:"groupBy"`
+	Buckets []SpendingBucket `json:"buckets"`
+	// Totals has one bucket per currency, keyed by the currency code.
+	Totals []SpendingBucket `json:"totals"`
+}
+
+// BuildSpendingReport buckets totals by groupBy. Every current member and
+// every weekday gets a bucket, and so does every month between from and to (or
+// between the first and last receipt if they're unset), up to
+// MaxSpendingReportYears back from the last; ones without receipts are empty
+// and in the group's currency. Archived members only get a bucket
+// if they have receipts.
+func BuildSpendingReport(group *Group, members []Member, groupBy string, from, to *time.Time, totals []SpendingTotal) *SpendingReport {
+	type bucketKey struct{ key, currency string }
+	buckets := map[bucketKey]*SpendingBucket{}
+	seenKeys := map[string]bool{}
+	var order []bucketKey
+	bucket := func(key, currency string) *SpendingBucket {
+		k := bucketKey{key, currency}
+		if b, ok := buckets[k]; ok {
+			return b
+		}
+		buckets[k] = &SpendingBucket{Key: key, Currency: currency}
+		seenKeys[key] = true
+		order = append(order, k)
+		return buckets[k]
+	}
+	// fill adds an empty bucket for a key without receipts in any currency
+	fill := func(key string) {
+		if !seenKeys[key] {
+			bucket(key, group.Currency)
+		}
+	}
+
+	switch groupBy {
+	case SpendingByMember:
+		// Buckets are keyed by member ID and get the display name at the end
+		members = slices.Clone(members)
+		SortMembers(members)
+		memberIndex := map[string]int{}
+		for i, member := range members {
+			memberIndex[member.ID] = i
+		}
+		for _, total := range totals {
+			bucket(total.PurchasedByID, total.Currency).add(total)
+		}
+		for _, member := range ActiveMembers(members) {
+			fill(member.ID)
+		}
+		for _, b := range buckets {
+			b.MemberID = b.Key
+			if member := FindMember(members, b.Key); member != nil {
+				b.Key, b.FormerMember = member.DisplayName, member.Archived
+			}
+		}
+		// In the group's order: active members first, then archived ones by name
+		slices.SortFunc(order, func(a, b bucketKey) int {
+			return cmp.Or(cmp.Compare(memberIndex[a.key], memberIndex[b.key]), cmp.Compare(a.currency, b.currency))
+		})
+
+	case SpendingByWeekday:
+		for _, total := range totals {
+			bucket(total.Date.Weekday().String(), total.Currency).add(total)
+		}
+		for day := range 7 {
+			fill(weekdayFromMonday(day).String())
+		}
+		slices.SortFunc(order, func(a, b bucketKey) int {
+			return cmp.Or(cmp.Compare(mondayIndex(a.key), mondayIndex(b.key)), cmp.Compare(a.currency, b.currency))
+		})
+
+	default:
+		first, last := from, to
+		for _, total := range totals {
+			if first == nil || total.Date.Before(*first) {
+				first = &total.Date
+			}
+			if last == nil || total.Date.After(*last) {
+				last = &total.Date
+			}
+			bucket(total.Date.Format("2006-01"), total.Currency).add(total)
+		}
+		if first != nil && last != nil {
+			month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC)
+			if earliest := time.Date(last.Year()-MaxSpendingReportYears, last.Month(), 1, 0, 0, 0, 0, time.UTC); month.Before(earliest) {
+				month = earliest
+			}
+			for !month.After(*last) {
+				fill(month.Format("2006-01"))
+				month = month.AddDate(0, 1, 0)
+			}
+		}
+		slices.SortFunc(order, func(a, b bucketKey) int {
+			return cmp.Or(cmp.Compare(a.key, b.key), cmp.Compare(a.currency, b.currency))
+		})
+	}
+
+	report := &SpendingReport{
+		GroupBy: groupBy,
+		Buckets: make([]SpendingBucket, 0, len(order)),
+		Totals:  []SpendingBucket{},
+	}
+	byCurrency := map[string]*SpendingBucket{}
+	for _, k := range order {
+		b := buckets[k]
+		b.Average = averageAmount(b.Total, b.Count)
+		report.Buckets = append(report.Buckets, *b)
+
+		if b.Count == 0 {
+			continue
+		}
+		if _, ok := byCurrency[b.Currency]; !ok {
+			byCurrency[b.Currency] = &SpendingBucket{Key: b.Currency, Currency: b.Currency}
+		}
+		byCurrency[b.Currency].Total += b.Total
+		byCurrency[b.Currency].Count += b.Count
+	}
+	for _, total := range byCurrency {
+		total.Average = averageAmount(total.Total, total.Count)
+		report.Totals = append(report.Totals, *total)
+	}
+	slices.SortFunc(report.Totals, func(a, b SpendingBucket) int { return cmp.Compare(a.Currency, b.Currency) })
+	return report
+}
+
+// averageAmount divides total by count, rounding half away from zero.
+func averageAmount(total Amount, count int) Amount {
+	if count == 0 {
+		return 0
+	}
+	n := Amount(count)
+	if total < 0 {
+		return -((-total + n/2) / n)
+	}
+	return (total + n/2) / n
+}
+
+// weekdayFromMonday maps 0..6 to Monday..Sunday.
+func weekdayFromMonday(day int) time.Weekday {
+	return time.Weekday((day + 1) % 7)
+}
+
+// mondayIndex is the position of a weekday name in a Monday-first week.
+func mondayIndex(name string) int {
+	for day := range 7 {
+		if weekdayFromMonday(day).String() == name {
+			return day
+		}
+	}
+	return 7
+}
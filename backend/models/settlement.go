@@ -0,0 +1,265 @@
+package models
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Settlement is a settle-up payment from one member to another, outside of
+// any receipt.
+type Settlement struct {
+	ID       string    `json:"id" db:"id"`
+	Date     time.Time `json:"date" db:"date"`
+	PaidByID string    `json:"paidById" db:"paid_by_id"`
+	PaidToID string    `json:"paidToId" db:"paid_to_id"`
+	// PaidBy and PaidTo are the display names of the referenced members
+	PaidBy    string    `json:"paidBy" db:"-"`
+	PaidTo    string    `json:"paidTo" db:"-"`
+	Amount    Amount    `json:"amount" db:"amount_cents"`
+	Currency  string    `json:"currency" db:"currency"`
+	Notes     *string   `json:"notes" db:"notes"`
+	GroupID   string    `json:"groupId" db:"group_id"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	ChangeSeq int64     `json:"changeSeq" db:"change_seq"`
+	Version   int64     `json:"version" db:"version"`
+}
+
+// MarshalJSON customizes JSON serialization to format date as YYYY-MM-DD
+func (s Settlement) MarshalJSON() ([]byte, error) {
+	type Alias Settlement
+	return json.Marshal(&struct {
+		Date        string `json:"date"`
+		AmountCents int64  `json:"amountCents"`
+		*Alias
+	}{
+		Date:        s.Date.Format("2006-01-02"),
+		AmountCents: int64(s.Amount),
+		Alias:       (*Alias)(&s),
+	})
+}
+
+// NewSettlement creates a new settlement with a generated UUID
+func NewSettlement(date time.Time, paidBy, paidTo Member, amount Amount, currency string, notes *string, groupID string) *Settlement {
+	return &Settlement{
+		ID:       uuid.New().String(),
+		Date:     date,
+		PaidByID: paidBy.ID,
+		PaidToID: paidTo.ID,
+		PaidBy:   paidBy.DisplayName,
+		PaidTo:   paidTo.DisplayName,
+		Amount:   amount,
+		Currency: currency,
+		Notes:    notes,
+		GroupID:  groupID,
+	}
+}
+
+// Validate rejects settlements that can't be applied to balances.
+func (s Settlement) Validate() error {
+	rejected := FieldErrors{}
+	if s.Amount <= 0 {
+		rejected["amount"] = "must be positive"
+	}
+	if s.PaidByID == s.PaidToID {
+		rejected["paidTo"] = "must be a different member than paidBy"
+	}
+	return rejected.orNil()
+}
+
+// MemberBalance is where one member stands in one currency. Balance is
+// positive when the member is owed money and negative when they owe it.
+type MemberBalance struct {
+	MemberID string `json:"memberId"`
+	// Member is the member's display name
+	Member   string `json:"member"`
+	Currency string `json:"currency"`
+	// Paid is what the member paid for receipts
+	Paid Amount `json:"paid"`
+	// Share is the member's part of every receipt's total
+	Share Amount `json:"share"`
+	// Settled is what the member paid in settlements minus what they received
+	Settled Amount `json:"settled"`
+	Balance Amount `json:"balance"`
+	// FormerMember marks archived members
+	FormerMember bool `json:"formerMember,omitempty"`
+}
+
+// Transfer is a payment that would settle part of the group's balances.
+type Transfer struct {
+	FromID   string `json:"fromId"`
+	ToID     string `json:"toId"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Amount   Amount `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// Balances is who owes whom in a group.
+type Balances struct {
+	Balances []MemberBalance `json:"balances"`
+	// Transfers is a short list of payments that would bring every balance
+	// to zero.
+	Transfers []Transfer `json:"transfers"`
+}
+
+// ComputeBalances works out every member's balance from the group's receipts
+// and settlements. Receipts without splits are shared equally between the
+// group's active members; each currency is settled separately.
+func ComputeBalances(group *Group, members []Member, receipts []Receipt, settlements []Settlement) *Balances {
+	members = slices.Clone(members)
+	SortMembers(members)
+	memberIndex := map[string]int{}
+	for i, member := range members {
+		memberIndex[member.ID] = i
+	}
+
+	type balanceKey struct{ memberID, currency string }
+	balances := map[balanceKey]*MemberBalance{}
+	balance := func(memberID, currency string) *MemberBalance {
+		k := balanceKey{memberID, currency}
+		if b, exists := balances[k]; exists {
+			return b
+		}
+		balances[k] = &MemberBalance{MemberID: memberID, Currency: currency}
+		if member := FindMember(members, memberID); member != nil {
+			balances[k].Member, balances[k].FormerMember = member.DisplayName, member.Archived
+		}
+		return balances[k]
+	}
+	active := ActiveMembers(members)
+	for _, member := range active {
+		balance(member.ID, group.Currency)
+	}
+
+	for _, receipt := range receipts {
+		balance(receipt.PurchasedByID, receipt.Currency).Paid += receipt.TotalAmount
+
+		splits := receipt.Splits
+		if len(splits) == 0 {
+			for _, member := range active {
+				splits = append(splits, ReceiptSplit{MemberID: member.ID, Weight: 1})
+			}
+		}
+		if len(splits) == 0 {
+			splits = []ReceiptSplit{{MemberID: receipt.PurchasedByID, Weight: 1}}
+		}
+		weights := make([]int, len(splits))
+		for i, split := range splits {
+			weights[i] = split.Weight
+		}
+		for i, share := range splitAmount(receipt.TotalAmount, weights) {
+			balance(splits[i].MemberID, receipt.Currency).Share += share
+		}
+	}
+
+	for _, settlement := range settlements {
+		balance(settlement.PaidByID, settlement.Currency).Settled += settlement.Amount
+		balance(settlement.PaidToID, settlement.Currency).Settled -= settlement.Amount
+	}
+
+	result := &Balances{Balances: make([]MemberBalance, 0, len(balances)), Transfers: []Transfer{}}
+	for _, b := range balances {
+		b.Balance = b.Paid - b.Share + b.Settled
+		result.Balances = append(result.Balances, *b)
+	}
+	// In the group's order: active members first, then archived ones by name
+	slices.SortFunc(result.Balances, func(a, b MemberBalance) int {
+		return cmp.Or(cmp.Compare(memberIndex[a.MemberID], memberIndex[b.MemberID]), cmp.Compare(a.Currency, b.Currency))
+	})
+
+	result.Transfers = settleUp(result.Balances)
+	return result
+}
+
+// splitAmount divides total in proportion to weights. Cents left over from
+// rounding down go to the largest remainders, so the shares add up to total.
+func splitAmount(total Amount, weights []int) []Amount {
+	var sum int64
+	for _, weight := range weights {
+		sum += int64(weight)
+	}
+	shares := make([]Amount, len(weights))
+	if sum == 0 {
+		return shares
+	}
+
+	remainders := make([]int64, len(weights))
+	left := total
+	for i, weight := range weights {
+		exact := int64(total) * int64(weight)
+		shares[i] = Amount(exact / sum)
+		remainders[i] = exact % sum
+		left -= shares[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(remainders[b], remainders[a]) })
+	for i := 0; left > 0; i++ {
+		shares[order[i%len(order)]]++
+		left--
+	}
+	return shares
+}
+
+// settleUp pairs the largest debtor with the largest creditor until every
+// balance in each currency is zero.
+func settleUp(balances []MemberBalance) []Transfer {
+	byCurrency := map[string][]MemberBalance{}
+	var currencies []string
+	for _, b := range balances {
+		if b.Balance == 0 {
+			continue
+		}
+		if _, ok := byCurrency[b.Currency]; !ok {
+			currencies = append(currencies, b.Currency)
+		}
+		byCurrency[b.Currency] = append(byCurrency[b.Currency], b)
+	}
+	slices.Sort(currencies)
+
+	transfers := []Transfer{}
+	for _, currency := range currencies {
+		var debtors, creditors []MemberBalance
+		for _, b := range byCurrency[currency] {
+			if b.Balance < 0 {
+				b.Balance = -b.Balance
+				debtors = append(debtors, b)
+			} else {
+				creditors = append(creditors, b)
+			}
+		}
+		largestFirst := func(a, b MemberBalance) int {
+			return cmp.Or(cmp.Compare(b.Balance, a.Balance), cmp.Compare(a.Member, b.Member), cmp.Compare(a.MemberID, b.MemberID))
+		}
+		slices.SortFunc(debtors, largestFirst)
+		slices.SortFunc(creditors, largestFirst)
+
+		for len(debtors) > 0 && len(creditors) > 0 {
+			debtor, creditor := &debtors[0], &creditors[0]
+			amount := min(debtor.Balance, creditor.Balance)
+			transfers = append(transfers, Transfer{
+				FromID:   debtor.MemberID,
+				ToID:     creditor.MemberID,
+				From:     debtor.Member,
+				To:       creditor.Member,
+				Amount:   amount,
+				Currency: currency,
+			})
+			debtor.Balance -= amount
+			creditor.Balance -= amount
+			if debtor.Balance == 0 {
+				debtors = debtors[1:]
+			}
+			if creditor.Balance == 0 {
+				creditors = creditors[1:]
+			}
+		}
+	}
+	return transfers
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSplitAmount(t *testing.T) {
+	tests := []struct {
+		total   Amount
+		weights []int
+		want    []Amount
+	}{
+		{1000, []int{1, 1}, []Amount{500, 500}},
+		{1000, []int{1, 1, 1}, []Amount{334, 333, 333}},
+		{1001, []int{1, 2}, []Amount{334, 667}},
+		// Leftover cents go to the largest remainders, not the first shares
+		{100, []int{1, 1, 4}, []Amount{17, 17, 66}},
+		{5, []int{3, 0, 1}, []Amount{4, 0, 1}},
+		{0, []int{1, 1}, []Amount{0, 0}},
+		{700, []int{0, 0}, []Amount{0, 0}},
+		{1, []int{1, 1, 1}, []Amount{1, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		got := splitAmount(tt.total, tt.weights)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitAmount(%d, %v) = %v, want %v", tt.total, tt.weights, got, tt.want)
+		}
+	}
+}
+
+func TestSplitAmountAddsUp(t *testing.T) {
+	for total := Amount(0); total < 500; total += 7 {
+		for _, weights := range [][]int{{1}, {1, 1, 1}, {2, 3, 5}, {1, 1, 1, 1, 1, 1, 1}, {0, 9, 1}} {
+			var sum Amount
+			for _, share := range splitAmount(total, weights) {
+				sum += share
+			}
+			if sum != total {
+				t.Errorf("splitAmount(%d, %v) adds up to %d", total, weights, sum)
+			}
+		}
+	}
+}
+
+func TestComputeBalances(t *testing.T) {
+	group := &Group{Currency: "EUR"}
+	alex := Member{ID: "a", DisplayName: "Alex", SortOrder: 0}
+	sam := Member{ID: "s", DisplayName: "Sam", SortOrder: 1}
+	kim := Member{ID: "k", DisplayName: "Kim", SortOrder: 2, Archived: true}
+	members := []Member{kim, sam, alex}
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	receipts := []Receipt{
+		// Shared equally between the active members
+		{TotalAmount: 3001, Currency: "EUR", PurchasedByID: "a", Date: date},
+		// Split unevenly, with a former member
+		{TotalAmount: 900, Currency: "EUR", PurchasedByID: "k", Date: date, Splits: []ReceiptSplit{{MemberID: "s", Weight: 2}, {MemberID: "k", Weight: 1}}},
+		// Another currency is settled on its own
+		{TotalAmount: 2000, Currency: "USD", PurchasedByID: "s", Date: date},
+	}
+	settlements := []Settlement{{PaidByID: "s", PaidToID: "a", Amount: 500, Currency: "EUR", Date: date}}
+
+	balances := ComputeBalances(group, members, receipts, settlements)
+
+	type key struct{ member, currency string }
+	got := map[key]MemberBalance{}
+	var order []key
+	for _, b := range balances.Balances {
+		got[key{b.MemberID, b.Currency}] = b
+		order = append(order, key{b.MemberID, b.Currency})
+	}
+	want := []MemberBalance{
+		{MemberID: "a", Member: "Alex", Currency: "EUR", Paid: 3001, Share: 1501, Settled: -500, Balance: 1000},
+		{MemberID: "a", Member: "Alex", Currency: "USD", Share: 1000, Balance: -1000},
+		{MemberID: "s", Member: "Sam", Currency: "EUR", Share: 1500 + 600, Settled: 500, Balance: -1600},
+		{MemberID: "s", Member: "Sam", Currency: "USD", Paid: 2000, Share: 1000, Balance: 1000},
+		{MemberID: "k", Member: "Kim", Currency: "EUR", Paid: 900, Share: 300, Balance: 600, FormerMember: true},
+	}
+	wantOrder := []key{}
+	for _, w := range want {
+		wantOrder = append(wantOrder, key{w.MemberID, w.Currency})
+		if got[key{w.MemberID, w.Currency}] != w {
+			t.Errorf("balance of %s in %s = %+v, want %+v", w.Member, w.Currency, got[key{w.MemberID, w.Currency}], w)
+		}
+	}
+	if !slices.Equal(order, wantOrder) {
+		t.Errorf("balances are in order %v, want %v", order, wantOrder)
+	}
+
+	// Every currency's balances add up to zero, and the transfers settle them
+	remaining := map[key]Amount{}
+	for k, b := range got {
+		remaining[k] = b.Balance
+	}
+	for _, transfer := range balances.Transfers {
+		if transfer.Amount <= 0 {
+			t.Errorf("transfer %+v isn't positive", transfer)
+		}
+		remaining[key{transfer.FromID, transfer.Currency}] += transfer.Amount
+		remaining[key{transfer.ToID, transfer.Currency}] -= transfer.Amount
+	}
+	for k, amount := range remaining {
+		if amount != 0 {
+			t.Errorf("after the transfers %s still has %d %s", k.member, amount, k.currency)
+		}
+	}
+	if len(balances.Transfers) != 3 {
+		t.Errorf("transfers = %+v, want 3", balances.Transfers)
+	}
+}
+
+func TestComputeBalancesWithoutActiveMembers(t *testing.T) {
+	group := &Group{Currency: "EUR"}
+	receipts := []Receipt{{TotalAmount: 1000, Currency: "EUR", PurchasedByID: "gone"}}
+
+	balances := ComputeBalances(group, nil, receipts, nil)
+	// With nobody to share with, the purchaser bears the whole receipt
+	if len(balances.Balances) != 1 || balances.Balances[0].Balance != 0 || balances.Balances[0].Share != 1000 {
+		t.Errorf("balances = %+v, want the purchaser's share to cover the receipt", balances.Balances)
+	}
+	if len(balances.Transfers) != 0 {
+		t.Errorf("transfers = %+v, want none", balances.Transfers)
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Entity types reported in tombstones.
+const (
+	EntityGroceryItem = "grocery_item"
+	EntityMealPlan    = "meal_plan"
+	EntityReceipt     = "receipt"
+	EntitySettlement  = "settlement"
+	EntityCategory    = "category"
+	EntityMember      = "member"
+)
+
+// Tombstone records that an entity was deleted, so delta syncs can report it.
+type Tombstone struct {
+	EntityType string    `json:"type" db:"entity_type"`
+	ID         string    `json:"id" db:"entity_id"`
+	ChangeSeq  int64     `json:"changeSeq" db:"change_seq"`
+	DeletedAt  time.Time `json:"deletedAt" db:"deleted_at"`
+}
+
+// ChangeSet is everything in a group that changed after a sync cursor.
+type ChangeSet struct {
+	GroceryItems []GroceryItem `json:"groceryItems"`
+	MealPlans    []MealPlan    `json:"mealPlans"`
+	Receipts     []Receipt     `json:"receipts"`
+	Settlements  []Settlement  `json:"settlements"`
+	Categories   []Category    `json:"categories"`
+	Members      []Member      `json:"members"`
+	Deleted      []Tombstone   `json:"deleted"`
+	// Cursor is the group's change sequence the set is complete up to
+	Cursor int64 `json:"-"`
+	// Reset tells the client to replace its local state instead of merging,
+	// because its cursor is ahead of the server's
+	Reset bool `json:"reset"`
+}
@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Bus carries events between the backend's instances, so that clients
+// connected to any of them see changes made through the others.
+type Bus interface {
+	// Publish numbers the event within its group and sends it to every
+	// instance, this one included.
+	Publish(ctx context.Context, event Envelope) error
+	// Revoke sends a revocation to every instance, this one included, so
+	// each ends the subscriptions it holds with the revoked tokens.
+	Revoke(ctx context.Context, revocation Revocation) error
+	// Listen starts delivering the events and revocations published by any
+	// instance, in order within each group, until ctx is done. It doesn't
+	// block.
+	Listen(ctx context.Context, deliver func(Envelope), revoke func(Revocation))
+}
+
+// LocalBus is a Bus for a single instance, delivering events in process.
+type LocalBus struct {
+	mu sync.Mutex
+	// Numbering starts from seqBase so that it carries on past the numbers
+	// used before a restart, whose events can't be replayed
+	seqBase int64
+	seqs    map[string]int64
+	deliver func(Envelope)
+	revoke  func(Revocation)
+}
+
+// NewLocalBus creates an in-process bus.
+func NewLocalBus() *LocalBus {
+	return &LocalBus{
+		seqBase: time.Now().UnixMilli() * 1000,
+		seqs:    make(map[string]int64),
+	}
+}
+
+func (bus *LocalBus) Publish(ctx context.Context, event Envelope) error {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	seq, ok := bus.seqs[event.GroupID]
+	if !ok {
+		seq = bus.seqBase
+	}
+	event.Seq = seq + 1
+	bus.seqs[event.GroupID] = event.Seq
+
+	// Delivering under the lock keeps the events in order
+	if bus.deliver != nil {
+		bus.deliver(event)
+	}
+	return nil
+}
+
+func (bus *LocalBus) Revoke(ctx context.Context, revocation Revocation) error {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if bus.revoke != nil {
+		bus.revoke(revocation)
+	}
+	return nil
+}
+
+func (bus *LocalBus) Listen(ctx context.Context, deliver func(Envelope), revoke func(Revocation)) {
+	bus.mu.Lock()
+	bus.deliver, bus.revoke = deliver, revoke
+	bus.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		bus.mu.Lock()
+		bus.deliver, bus.revoke = nil, nil
+		bus.mu.Unlock()
+	})
+}
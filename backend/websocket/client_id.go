@@ -0,0 +1,49 @@
+package websocket
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientIDHeader is the header a client identifies itself with, both on API
+// requests and when connecting, so it can tell its own changes apart.
+const ClientIDHeader = "X-Client-ID"
+
+// maxClientIDLength is the longest client ID accepted. Longer ones are
+// ignored.
+const maxClientIDLength = 128
+
+type clientIDKey struct{}
+
+func parseClientID(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > maxClientIDLength {
+		return ""
+	}
+	return value
+}
+
+// WithClientID returns a context whose events name clientID as the client
+// that caused them.
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, clientID)
+}
+
+// ClientIDFrom returns the client ID stored by WithClientID, or "".
+func ClientIDFrom(ctx context.Context) string {
+	clientID, _ := ctx.Value(clientIDKey{}).(string)
+	return clientID
+}
+
+// TagClientID is middleware that stores the request's X-Client-ID in its
+// context, for EmitEvent to tag the events the request causes with.
+func TagClientID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if clientID := parseClientID(c.GetHeader(ClientIDHeader)); clientID != "" {
+			c.Request = c.Request.WithContext(WithClientID(c.Request.Context(), clientID))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	_ "embed"
+	"encoding/json"
+	"time"
+)
+
+// ProtocolVersion is the version of the envelope events are sent in. It only
+// changes when existing fields change meaning; new fields and new event types
+// don't need a new version, and clients should ignore events they don't know.
+const ProtocolVersion = 1
+
+// EventType names an event.
+type EventType string
+
+// Events about a group's data. Created and updated events carry the whole
+// entity, deleted events carry a DeletedPayload, and the plural updated
+// events carry a list of entities changed together.
+const (
+	EventGroceryItemCreated  EventType = "grocery_item_created"
+	EventGroceryItemUpdated  EventType = "grocery_item_updated"
+	EventGroceryItemDeleted  EventType = "grocery_item_deleted"
+	EventGroceryItemsUpdated EventType = "grocery_items_updated"
+	EventCategoryCreated     EventType = "category_created"
+	EventCategoryUpdated     EventType = "category_updated"
+	EventCategoryDeleted     EventType = "category_deleted"
+	EventMemberCreated       EventType = "member_created"
+	EventMemberUpdated       EventType = "member_updated"
+	EventMealPlanCreated     EventType = "meal_plan_created"
+	EventMealPlanUpdated     EventType = "meal_plan_updated"
+	EventMealPlanDeleted     EventType = "meal_plan_deleted"
+	EventReceiptCreated      EventType = "receipt_created"
+	EventReceiptUpdated      EventType = "receipt_updated"
+	EventReceiptDeleted      EventType = "receipt_deleted"
+	EventReceiptsUpdated     EventType = "receipts_updated"
+	EventSettlementCreated   EventType = "settlement_created"
+	EventSettlementUpdated   EventType = "settlement_updated"
+	EventSettlementDeleted   EventType = "settlement_deleted"
+	EventSettlementsUpdated  EventType = "settlements_updated"
+	EventBalancesUpdated     EventType = "balances_updated"
+	EventGroupUpdated        EventType = "group_updated"
+	EventGroupDeleted        EventType = "group_deleted"
+)
+
+// DeletedPayload is the data of the deleted events.
+type DeletedPayload struct {
+	ID string `json:"id"`
+	// Version is the version the entity had when it was deleted, if the
+	// delete was made conditional on it
+	Version *int64 `json:"version,omitempty"`
+}
+
+// Events about the connection itself. They aren't numbered or replayed.
+const (
+	EventConnected       EventType = "connected"
+	EventSubscribeDenied EventType = "subscribe_denied"
+	EventAccessRevoked   EventType = "access_revoked"
+	EventResumed         EventType = "resumed"
+	EventResyncRequired  EventType = "resync_required"
+	EventEcho            EventType = "echo"
+)
+
+// Messages clients send.
+const (
+	EventSubscribe EventType = "subscribe"
+	EventResume    EventType = "resume"
+)
+
+// Envelope is how every event is sent to clients, and how a group's events
+// travel over the bus.
+type Envelope struct {
+	Version int       `json:"version"`
+	Event   EventType `json:"event"`
+	// GroupID is the group the event is about, if any
+	GroupID string `json:"groupId,omitempty"`
+	// Seq numbers the group's events, without gaps unless some were lost.
+	// Connection events have none.
+	Seq int64 `json:"seq,omitempty"`
+	// Timestamp is when the server emitted the event
+	Timestamp time.Time `json:"timestamp"`
+	// ClientID is the X-Client-ID of the request that caused the event, if
+	// it sent one
+	ClientID string `json:"clientId,omitempty"`
+	// Own is set on the copy sent to the connections with that client ID,
+	// which may skip merging changes they made themselves
+	Own  bool            `json:"own,omitempty"`
+	Data json.RawMessage `json:"data"`
+}
+
+// newEnvelope wraps a payload in an envelope stamped with the current time.
+func newEnvelope(event EventType, groupID string, payload any) (Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{
+		Version:   ProtocolVersion,
+		Event:     event,
+		GroupID:   groupID,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	}, nil
+}
+
+// Schema is the JSON Schema of the envelope and the payloads of the events
+// above.
+//
+//go:embed events.schema.json
+var Schema []byte
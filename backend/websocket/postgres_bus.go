@@ -0,0 +1,266 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// notifyChannel is the Postgres channel events are announced on
+	notifyChannel = "websocket_events"
+
+	// How long published events are kept for instances to load them
+	eventRetention = 5 * time.Minute
+
+	// How long the listener waits before reconnecting after losing its
+	// connection
+	listenRetryDelay = 2 * time.Second
+
+	// revocationEvent marks the rows of websocket_events that are
+	// revocations. They aren't numbered, so their seq is 0.
+	revocationEvent = "_revocation"
+)
+
+// PostgresBus is a Bus for instances sharing a database. Events are stored in
+// websocket_events and announced by ID with NOTIFY, since notification
+// payloads are limited to 8000 bytes. Numbering them in the same transaction
+// as the announcement makes every instance see a group's events in order.
+type PostgresBus struct {
+	pool *pgxpool.Pool
+
+	pruneMu    sync.Mutex
+	lastPruned time.Time
+}
+
+// NewPostgresBus creates a bus on the given pool.
+func NewPostgresBus(pool *pgxpool.Pool) *PostgresBus {
+	return &PostgresBus{pool: pool}
+}
+
+func (bus *PostgresBus) Publish(ctx context.Context, event Envelope) error {
+	tx, err := bus.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The sequence row stays locked until commit, so a group's notifications
+	// go out in the order of their numbers
+	var id int64
+	err = tx.QueryRow(ctx, `
+		WITH next AS (
+			INSERT INTO websocket_sequences (group_id, seq) VALUES ($1, 1)
+			ON CONFLICT (group_id) DO UPDATE SET seq = websocket_sequences.seq + 1
+			RETURNING seq
+		)
+		INSERT INTO websocket_events (group_id, seq, event, data, created_at, client_id)
+		SELECT $1, seq, $2, $3, $4, $5 FROM next
+		RETURNING id`,
+		event.GroupID, event.Event, string(event.Data), event.Timestamp, event.ClientID).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to store event: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, strconv.FormatInt(id, 10)); err != nil {
+		return fmt.Errorf("failed to notify: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	bus.prune(ctx)
+	return nil
+}
+
+func (bus *PostgresBus) Revoke(ctx context.Context, revocation Revocation) error {
+	// Stored like an event, so it reaches the other instances in order with
+	// the group's events
+	tokenIDs, err := json.Marshal(revocation.TokenIDs)
+	if err != nil {
+		return err
+	}
+	tx, err := bus.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO websocket_events (group_id, seq, event, data)
+		VALUES ($1, 0, $2, $3)
+		RETURNING id`,
+		revocation.GroupID, revocationEvent, string(tokenIDs)).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to store revocation: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, strconv.FormatInt(id, 10)); err != nil {
+		return fmt.Errorf("failed to notify: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// prune deletes the events every instance has had time to load, at most
+// once a minute.
+func (bus *PostgresBus) prune(ctx context.Context) {
+	bus.pruneMu.Lock()
+	if time.Since(bus.lastPruned) < time.Minute {
+		bus.pruneMu.Unlock()
+		return
+	}
+	bus.lastPruned = time.Now()
+	bus.pruneMu.Unlock()
+
+	cutoff := time.Now().Add(-eventRetention)
+	if _, err := bus.pool.Exec(ctx, `DELETE FROM websocket_events WHERE created_at < $1`, cutoff); err != nil {
+		log.Printf("Failed to prune websocket events: %v", err)
+	}
+}
+
+func (bus *PostgresBus) Listen(ctx context.Context, deliver func(Envelope), revoke func(Revocation)) {
+	go func() {
+		// The events delivered or skipped so far, for catching up after a
+		// reconnect. Nil until the listener first starts.
+		var seen map[int64]time.Time
+		for ctx.Err() == nil {
+			if err := bus.listen(ctx, &seen, deliver, revoke); err != nil && ctx.Err() == nil {
+				// Events announced until the listener is back are loaded from
+				// websocket_events then, unless they were pruned already
+				log.Printf("Websocket event listener failed, retrying: %v", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(listenRetryDelay):
+				}
+			}
+		}
+	}()
+}
+
+// storedEvent is a row of websocket_events.
+type storedEvent struct {
+	id    int64
+	event Envelope
+	data  string
+}
+
+const storedEventColumns = `id, group_id, seq, event, data, created_at, client_id`
+
+func scanStoredEvent(row pgx.Row) (storedEvent, error) {
+	stored := storedEvent{event: Envelope{Version: ProtocolVersion}}
+	err := row.Scan(&stored.id, &stored.event.GroupID, &stored.event.Seq, &stored.event.Event,
+		&stored.data, &stored.event.Timestamp, &stored.event.ClientID)
+	stored.event.Timestamp = stored.event.Timestamp.UTC()
+	return stored, err
+}
+
+// listen holds a connection listening for announcements until it fails. It
+// first delivers the stored events missing from seen, which were announced
+// while no connection was listening, and keeps seen up to date.
+//
+// Catching up can't just load the events after the last one seen: IDs are
+// taken when a transaction inserts its event, so one that commits late can
+// have a lower ID than events already delivered. Every event still within
+// eventRetention is checked against seen instead.
+func (bus *PostgresBus) listen(ctx context.Context, seen *map[int64]time.Time, deliver func(Envelope), revoke func(Revocation)) error {
+	pooled, err := bus.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	// The connection can't go back to the pool still listening
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	rows, err := conn.Query(ctx, `SELECT `+storedEventColumns+` FROM websocket_events WHERE created_at > $1 ORDER BY id`,
+		time.Now().Add(-eventRetention))
+	if err != nil {
+		return fmt.Errorf("failed to load missed events: %w", err)
+	}
+	stored, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storedEvent, error) {
+		return scanStoredEvent(row)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load missed events: %w", err)
+	}
+	now := time.Now()
+	if *seen == nil {
+		// Starting up, so there's nothing to catch up on
+		*seen = make(map[int64]time.Time, len(stored))
+		for _, event := range stored {
+			(*seen)[event.id] = now
+		}
+	} else {
+		forgetSeen(*seen, now)
+		for _, event := range stored {
+			if _, ok := (*seen)[event.id]; !ok {
+				(*seen)[event.id] = now
+				bus.dispatch(event, deliver, revoke)
+			}
+		}
+	}
+
+	lastForgotten := now
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseInt(notification.Payload, 10, 64)
+		if err != nil {
+			log.Printf("Ignoring websocket event notification %q", notification.Payload)
+			continue
+		}
+		// Events caught up on above may be announced to the new LISTEN as
+		// well
+		if _, ok := (*seen)[id]; ok {
+			continue
+		}
+		(*seen)[id] = time.Now()
+		if time.Since(lastForgotten) > eventRetention {
+			lastForgotten = time.Now()
+			forgetSeen(*seen, lastForgotten)
+		}
+
+		stored, err := scanStoredEvent(bus.pool.QueryRow(ctx, `SELECT `+storedEventColumns+` FROM websocket_events WHERE id = $1`, id))
+		if err != nil {
+			log.Printf("Failed to load websocket event %d: %v", id, err)
+			continue
+		}
+		bus.dispatch(stored, deliver, revoke)
+	}
+}
+
+// forgetSeen drops the events seen long enough ago that they're no longer
+// loaded for catching up.
+func forgetSeen(seen map[int64]time.Time, now time.Time) {
+	for id, at := range seen {
+		if now.Sub(at) > 2*eventRetention {
+			delete(seen, id)
+		}
+	}
+}
+
+// dispatch hands a stored event or revocation to the listener.
+func (bus *PostgresBus) dispatch(stored storedEvent, deliver func(Envelope), revoke func(Revocation)) {
+	if stored.event.Event == revocationEvent {
+		revocation := Revocation{GroupID: stored.event.GroupID}
+		if err := json.Unmarshal([]byte(stored.data), &revocation.TokenIDs); err != nil {
+			log.Printf("Failed to decode websocket revocation %d: %v", stored.id, err)
+			return
+		}
+		revoke(revocation)
+		return
+	}
+	stored.event.Data = []byte(stored.data)
+	deliver(stored.event)
+}
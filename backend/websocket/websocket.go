@@ -1,11 +1,15 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"log"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -33,52 +37,214 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 512 * 1024
+
+	// Messages queued for a client before it counts as too slow and is
+	// disconnected
+	sendBufferSize = 256
+
+	// Events kept per group for clients resuming after a reconnect. It is
+	// less than sendBufferSize so a full replay always fits in the queue.
+	replayLogSize = 128
+
+	// How long a group's replay log is kept after its last event once no
+	// client is subscribed to it, and how often that is checked
+	replayRetention  = 30 * time.Minute
+	replayPruneEvery = time.Minute
 )
 
+// Authorizer checks that token grants access to a group.
+type Authorizer func(ctx context.Context, groupID, token string) (grant Grant, ok bool)
+
+// Grant is a client's access to one group.
+type Grant struct {
+	// TokenID is the access token the subscription was made with. It is
+	// empty for groups that don't require one.
+	TokenID string
+	// Allows reports whether an event is sent to the client. Nil allows all.
+	Allows func(event string) bool
+}
+
+func (g Grant) allows(event EventType) bool {
+	return g.Allows == nil || g.Allows(string(event))
+}
+
 // Client represents a connected WebSocket client
 type Client struct {
-	Conn    *websocket.Conn
-	Groups  map[string]bool // Set of group IDs
-	writeMu sync.Mutex      // Serializes data-frame writes (broadcasts, welcome, echo)
+	Conn   *websocket.Conn
+	Groups map[string]Grant // Group ID -> access the client subscribed with
+	// ClientID is the ID the client connected with, if any. Events its own
+	// requests caused are flagged as its own.
+	ClientID string
+	// send queues messages for writePump, the only goroutine writing to
+	// Conn. It is closed once the client is gone.
+	send   chan []byte
+	sendMu sync.Mutex // Guards closed and closing send
+	closed bool
+}
+
+func newClient(conn *websocket.Conn, groups map[string]Grant, clientID string) *Client {
+	return &Client{
+		Conn:     conn,
+		Groups:   groups,
+		ClientID: clientID,
+		send:     make(chan []byte, sendBufferSize),
+	}
+}
+
+// enqueue queues a message without blocking. A client whose queue is full
+// can't keep up and is disconnected; enqueue then reports true.
+func (client *Client) enqueue(message []byte) (tooSlow bool) {
+	client.sendMu.Lock()
+	defer client.sendMu.Unlock()
+	if client.closed {
+		return false
+	}
+	select {
+	case client.send <- message:
+		return false
+	default:
+		// Closing the connection as well cuts short a write that is stuck
+		client.closed = true
+		close(client.send)
+		client.Conn.Close()
+		return true
+	}
+}
+
+// close stops the client's writer, which then closes the connection.
+func (client *Client) close() {
+	client.sendMu.Lock()
+	defer client.sendMu.Unlock()
+	if !client.closed {
+		client.closed = true
+		close(client.send)
+	}
+}
+
+// writePump writes queued messages and keep-alive pings to the connection
+// until the queue is closed or a write fails.
+func (client *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.Conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-client.send:
+			client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				client.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := client.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("Error writing message: %v", err)
+				return
+			}
+		case <-ticker.C:
+			client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
 }
 
-// BroadcastMessage represents a message to be sent to clients
-type BroadcastMessage struct {
-	Data     []byte
-	GroupIDs []string // Optional: if empty, broadcast to all (legacy)
+// Resume asks for the events a client missed in each group since the
+// sequence number it saw last.
+type Resume struct {
+	Client *websocket.Conn
+	Seqs   map[string]int64 // Group ID -> last sequence number seen
+}
+
+// loggedEvent is an event kept for replay, already encoded for sending.
+type loggedEvent struct {
+	seq      int64
+	event    EventType
+	clientID string
+	message  []byte
+	// ownMessage is the message for the client that caused the event
+	ownMessage []byte
+}
+
+// messageFor returns the message to send client, flagged as its own if the
+// client's request caused the event.
+func (logged loggedEvent) messageFor(client *Client) []byte {
+	if logged.ownMessage != nil && client.ClientID == logged.clientID {
+		return logged.ownMessage
+	}
+	return logged.message
 }
 
 // Subscription represents a request to subscribe to groups
 type Subscription struct {
-	Client   *websocket.Conn
-	GroupIDs []string
+	Client *websocket.Conn
+	Groups map[string]Grant
+}
+
+// Revocation ends the subscriptions to a group made with the given access
+// tokens, or all of them if TokenIDs is empty.
+type Revocation struct {
+	GroupID  string
+	TokenIDs []string
 }
 
 // WebSocketManager manages WebSocket connections
 type WebSocketManager struct {
 	clients    map[*websocket.Conn]*Client
 	groups     map[string]map[*websocket.Conn]bool // groupID -> set of connections
-	broadcast  chan BroadcastMessage
+	broadcast  chan Envelope                       // Events the bus delivers
 	register   chan *Client
 	unregister chan *websocket.Conn
 	subscribe  chan Subscription
+	revoke     chan Revocation
+	resume     chan Resume
+	authorize  Authorizer
+	bus        Bus
 	mutex      sync.RWMutex
+	// The last sequence number seen, the replay log and the time of the
+	// last event per group, only touched by Run
+	seqs      map[string]int64
+	history   map[string][]loggedEvent
+	lastEvent map[string]time.Time
+	// slowClients counts the clients disconnected for a full queue
+	slowClients atomic.Int64
 }
 
-// NewWebSocketManager creates a new WebSocket manager
-func NewWebSocketManager() *WebSocketManager {
+// NewWebSocketManager creates a new WebSocket manager that checks
+// subscriptions with authorize and sends events over bus
+func NewWebSocketManager(authorize Authorizer, bus Bus) *WebSocketManager {
 	return &WebSocketManager{
 		clients:    make(map[*websocket.Conn]*Client),
 		groups:     make(map[string]map[*websocket.Conn]bool),
-		broadcast:  make(chan BroadcastMessage, 256),
+		broadcast:  make(chan Envelope, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *websocket.Conn),
 		subscribe:  make(chan Subscription),
+		revoke:     make(chan Revocation),
+		resume:     make(chan Resume),
+		authorize:  authorize,
+		bus:        bus,
+		seqs:       make(map[string]int64),
+		history:    make(map[string][]loggedEvent),
+		lastEvent:  make(map[string]time.Time),
 	}
 }
 
 // Run starts the WebSocket manager
 func (manager *WebSocketManager) Run() {
+	// Events published by every instance, this one included, come back
+	// through the bus
+	manager.bus.Listen(context.Background(), func(event Envelope) {
+		manager.broadcast <- event
+	}, func(revocation Revocation) {
+		manager.revoke <- revocation
+	})
+
+	pruneTicker := time.NewTicker(replayPruneEvery)
+	defer pruneTicker.Stop()
+
 	for {
 		select {
 		case client := <-manager.register:
@@ -92,35 +258,51 @@ func (manager *WebSocketManager) Run() {
 				manager.groups[groupID][client.Conn] = true
 			}
 			manager.mutex.Unlock()
-			log.Printf("Client connected: Groups=%v", client.Groups)
+			log.Printf("Client connected: Groups=%v", slices.Collect(maps.Keys(client.Groups)))
 
 			// Send welcome message
-			welcomeMsg := map[string]any{
-				"event": "connected",
-				"data":  map[string]string{"message": "Connected to Lebensmittel backend"},
-			}
-			msgBytes, _ := json.Marshal(welcomeMsg)
-			client.writeMu.Lock()
-			client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-			client.Conn.WriteMessage(websocket.TextMessage, msgBytes)
-			client.writeMu.Unlock()
+			manager.sendEvent(client, EventConnected, "", map[string]string{"message": "Connected to Lebensmittel backend"})
 
 		case sub := <-manager.subscribe:
 			manager.mutex.Lock()
 			if client, ok := manager.clients[sub.Client]; ok {
-				for _, groupID := range sub.GroupIDs {
+				for groupID, grant := range sub.Groups {
 					// Add to client's group list
-					client.Groups[groupID] = true
+					client.Groups[groupID] = grant
 					// Add to manager's group map
 					if _, ok := manager.groups[groupID]; !ok {
 						manager.groups[groupID] = make(map[*websocket.Conn]bool)
 					}
 					manager.groups[groupID][sub.Client] = true
 				}
-				log.Printf("Client subscribed to groups: %v", sub.GroupIDs)
+				log.Printf("Client subscribed to groups: %v", slices.Collect(maps.Keys(sub.Groups)))
 			}
 			manager.mutex.Unlock()
 
+		case revocation := <-manager.revoke:
+			manager.mutex.Lock()
+			var revoked []*Client
+			for conn := range manager.groups[revocation.GroupID] {
+				client := manager.clients[conn]
+				tokenID := client.Groups[revocation.GroupID].TokenID
+				if len(revocation.TokenIDs) > 0 && !slices.Contains(revocation.TokenIDs, tokenID) {
+					continue
+				}
+				delete(client.Groups, revocation.GroupID)
+				delete(manager.groups[revocation.GroupID], conn)
+				revoked = append(revoked, client)
+			}
+			if len(manager.groups[revocation.GroupID]) == 0 {
+				delete(manager.groups, revocation.GroupID)
+			}
+			manager.mutex.Unlock()
+
+			// Let the clients know so they can ask the user to join again
+			for _, client := range revoked {
+				manager.sendEvent(client, EventAccessRevoked, revocation.GroupID, map[string]string{"groupId": revocation.GroupID})
+			}
+			log.Printf("Revoked %d subscription(s) to group %s", len(revoked), revocation.GroupID)
+
 		case conn := <-manager.unregister:
 			manager.mutex.Lock()
 			if client, ok := manager.clients[conn]; ok {
@@ -134,63 +316,163 @@ func (manager *WebSocketManager) Run() {
 					}
 				}
 				delete(manager.clients, conn)
-				conn.Close()
+				client.close()
 			}
 			manager.mutex.Unlock()
 			log.Println("Client disconnected")
 
-		case message := <-manager.broadcast:
+		case resume := <-manager.resume:
 			manager.mutex.RLock()
-
-			// Use a set to avoid sending duplicate messages to the same connection
-			targetConns := make(map[*websocket.Conn]bool)
-
-			for _, groupID := range message.GroupIDs {
-				if conns, ok := manager.groups[groupID]; ok {
-					for conn := range conns {
-						targetConns[conn] = true
-					}
+			client, ok := manager.clients[resume.Client]
+			manager.mutex.RUnlock()
+			if ok {
+				for groupID, seq := range resume.Seqs {
+					manager.replay(client, groupID, seq)
 				}
 			}
 
-			for conn := range targetConns {
-				if client, ok := manager.clients[conn]; ok {
-					client.writeMu.Lock()
-					conn.SetWriteDeadline(time.Now().Add(writeWait))
-					err := conn.WriteMessage(websocket.TextMessage, message.Data)
-					client.writeMu.Unlock()
-					if err != nil {
-						log.Printf("Error writing message: %v", err)
-						conn.Close()
-					}
+		case event := <-manager.broadcast:
+			logged := manager.record(event)
+
+			manager.mutex.RLock()
+			var targets []*Client
+			for conn := range manager.groups[event.GroupID] {
+				// Share links only get the events they're allowed to see
+				if client := manager.clients[conn]; client.Groups[event.GroupID].allows(event.Event) {
+					targets = append(targets, client)
 				}
 			}
 			manager.mutex.RUnlock()
+
+			// Queuing never blocks, so a slow client can't hold up the others
+			for _, client := range targets {
+				manager.send(client, logged.messageFor(client))
+			}
+
+			// Nobody can resume a deleted group
+			if event.Event == EventGroupDeleted {
+				manager.forget(event.GroupID)
+			}
+
+		case now := <-pruneTicker.C:
+			manager.pruneReplayLogs(now)
 		}
 	}
 }
 
-// EmitEvent sends an event to connected WebSocket clients
-// If groupIDs are provided, it sends only to clients subscribed to those groups
-func (manager *WebSocketManager) EmitEvent(event string, payload any, groupIDs ...string) {
-	message := map[string]any{
-		"event": event,
-		"data":  payload,
+// pruneReplayLogs drops the replay logs of groups nobody is subscribed to
+// that haven't had an event for replayRetention.
+func (manager *WebSocketManager) pruneReplayLogs(now time.Time) {
+	manager.mutex.RLock()
+	var idle []string
+	for groupID, last := range manager.lastEvent {
+		if len(manager.groups[groupID]) == 0 && now.Sub(last) > replayRetention {
+			idle = append(idle, groupID)
+		}
+	}
+	manager.mutex.RUnlock()
+
+	for _, groupID := range idle {
+		manager.forget(groupID)
+	}
+}
+
+// forget drops a group's sequence number and replay log. Clients resuming
+// it are told to resync.
+func (manager *WebSocketManager) forget(groupID string) {
+	delete(manager.seqs, groupID)
+	delete(manager.history, groupID)
+	delete(manager.lastEvent, groupID)
+}
+
+// record encodes an event and keeps it for replay.
+func (manager *WebSocketManager) record(event Envelope) loggedEvent {
+	msgBytes, _ := json.Marshal(event)
+	logged := loggedEvent{seq: event.Seq, event: event.Event, clientID: event.ClientID, message: msgBytes}
+	if event.ClientID != "" {
+		own := event
+		own.Own = true
+		logged.ownMessage, _ = json.Marshal(own)
+	}
+
+	// The log only ever holds consecutive events. After a gap, which the bus
+	// may leave, it starts over.
+	history := manager.history[event.GroupID]
+	if last, ok := manager.seqs[event.GroupID]; !ok || event.Seq != last+1 {
+		history = nil
+	}
+	history = append(history, logged)
+	if len(history) > replayLogSize {
+		history = slices.Clone(history[len(history)-replayLogSize:])
 	}
+	manager.history[event.GroupID] = history
+	manager.seqs[event.GroupID] = event.Seq
+	manager.lastEvent[event.GroupID] = time.Now()
+	return logged
+}
 
-	msgBytes, err := json.Marshal(message)
+// replay sends a client the events of a group after lastSeq, followed by
+// resumed with the group's current sequence number. If they aren't all in
+// the log any more it sends resync_required instead, and the client has to
+// sync over HTTP.
+func (manager *WebSocketManager) replay(client *Client, groupID string, lastSeq int64) {
+	manager.mutex.RLock()
+	grant, subscribed := client.Groups[groupID]
+	manager.mutex.RUnlock()
+	if !subscribed {
+		return
+	}
+
+	// The log holds consecutive events, so the ones missed are a suffix of
+	// it if it reaches back far enough
+	current, seen := manager.seqs[groupID]
+	history := manager.history[groupID]
+	switch {
+	case seen && lastSeq == current:
+	case len(history) > 0 && lastSeq >= history[0].seq-1 && lastSeq < current:
+		for _, logged := range history[lastSeq-history[0].seq+1:] {
+			if grant.allows(logged.event) {
+				manager.send(client, logged.messageFor(client))
+			}
+		}
+	default:
+		// The current number is unknown until the group's next event
+		data := map[string]any{"groupId": groupID}
+		if seen {
+			data["seq"] = current
+		}
+		manager.sendEvent(client, EventResyncRequired, groupID, data)
+		log.Printf("Client resuming group %s at %d must resync, now at %d", groupID, lastSeq, current)
+		return
+	}
+
+	manager.sendEvent(client, EventResumed, groupID, map[string]any{"groupId": groupID, "seq": current})
+}
+
+// EmitEvent sends an event to the WebSocket clients subscribed to the
+// groups, on every instance, by publishing it on the bus. The event names
+// the client ID stored in ctx as the one that caused it. Events are never
+// dropped: EmitEvent waits if the broadcast channel is full.
+func (manager *WebSocketManager) EmitEvent(ctx context.Context, event EventType, payload any, groupIDs ...string) {
+	envelope, err := newEnvelope(event, "", payload)
 	if err != nil {
 		log.Printf("[socketio] Failed to marshal event %s: %v", event, err)
 		return
 	}
+	envelope.ClientID = ClientIDFrom(ctx)
+	// The change is made, so the event goes out even if the request is gone
+	ctx = context.WithoutCancel(ctx)
 
 	log.Printf("[socketio] Emitting %s -> %v (Groups: %v)", event, payload, groupIDs)
 
-	select {
-	case manager.broadcast <- BroadcastMessage{Data: msgBytes, GroupIDs: groupIDs}:
+	// Each group numbers its events, so they are published separately
+	for _, groupID := range groupIDs {
+		envelope.GroupID = groupID
+		if err := manager.bus.Publish(ctx, envelope); err != nil {
+			log.Printf("[socketio] Emit failed for %s: %v", event, err)
+			continue
+		}
 		log.Printf("[socketio] Emitted %s", event)
-	default:
-		log.Printf("[socketio] Emit failed for %s: broadcast channel full", event)
 	}
 }
 
@@ -202,23 +484,30 @@ func (manager *WebSocketManager) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	requestedGroups := c.Query("groups")
-	initialGroups := make(map[string]bool)
-
-	if requestedGroups != "" {
+	// The groups in the query share the bearer token. Tokens are never taken
+	// from the query, which ends up in request logs; groups needing
+	// different tokens are subscribed to with the subscribe message.
+	var bearer string
+	if scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		bearer = strings.TrimSpace(token)
+	}
+	requested := map[string]string{}
+	if requestedGroups := c.Query("groups"); requestedGroups != "" {
 		for _, gid := range strings.Split(requestedGroups, ",") {
-			gid = strings.TrimSpace(gid)
-			if gid != "" {
-				initialGroups[gid] = true
-			}
+			requested[strings.TrimSpace(gid)] = bearer
 		}
 	}
+	initialGroups, denied := manager.authorizeGroups(c.Request.Context(), requested)
 
-	client := &Client{
-		Conn:   conn,
-		Groups: initialGroups,
+	// Clients that can't set headers when connecting send the ID as clientId
+	clientID := c.GetHeader(ClientIDHeader)
+	if clientID == "" {
+		clientID = c.Query("clientId")
 	}
 
+	client := newClient(conn, initialGroups, parseClientID(clientID))
+	go client.writePump()
+
 	// Configure connection
 	conn.SetReadLimit(maxMessageSize)
 	conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -228,28 +517,13 @@ func (manager *WebSocketManager) HandleWebSocket(c *gin.Context) {
 	})
 
 	manager.register <- client
-
-	// Start ping ticker for keep-alive
-	ticker := time.NewTicker(pingPeriod)
-
-	// Handle outgoing pings — WriteControl is safe for concurrent use
-	// with WriteMessage, unlike WriteMessage which would race with
-	// the broadcast writes in Run().
-	go func() {
-		defer ticker.Stop()
-		for range ticker.C {
-			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
-				return
-			}
-		}
-	}()
+	if len(denied) > 0 {
+		manager.sendSubscribeDenied(client, denied)
+	}
 
 	// Handle incoming messages
 	go func() {
-		defer func() {
-			ticker.Stop()
-			manager.unregister <- conn
-		}()
+		defer func() { manager.unregister <- conn }()
 
 		for {
 			messageType, message, err := conn.ReadMessage()
@@ -265,43 +539,53 @@ func (manager *WebSocketManager) HandleWebSocket(c *gin.Context) {
 				if err := json.Unmarshal(message, &msg); err == nil {
 					// Handle specific message types
 					if event, ok := msg["event"].(string); ok {
-						switch event {
-						case "subscribe":
-							// Handle subscription to groups
+						switch EventType(event) {
+						case EventSubscribe:
+							// Handle subscription to groups. Tokens are sent as a
+							// "tokens" object keyed by group ID, or one "token" for all.
 							if data, ok := msg["data"].(map[string]any); ok {
 								if groupsInterface, ok := data["groups"].([]any); ok {
-									var groupIDs []string
+									defaultToken, _ := data["token"].(string)
+									groupTokens, _ := data["tokens"].(map[string]any)
+
+									requested := map[string]string{}
 									for _, g := range groupsInterface {
-										if s, ok := g.(string); ok {
-											groupIDs = append(groupIDs, s)
+										if gid, ok := g.(string); ok {
+											token := defaultToken
+											if groupToken, ok := groupTokens[gid].(string); ok {
+												token = groupToken
+											}
+											requested[strings.TrimSpace(gid)] = strings.TrimSpace(token)
 										}
 									}
 
-									var requested []string
-									for _, gid := range groupIDs {
-										gid = strings.TrimSpace(gid)
-										if gid != "" {
-											requested = append(requested, gid)
-										}
+									allowed, denied := manager.authorizeGroups(context.Background(), requested)
+									if len(allowed) > 0 {
+										manager.subscribe <- Subscription{Client: conn, Groups: allowed}
 									}
-
-									if len(requested) > 0 {
-										manager.subscribe <- Subscription{Client: conn, GroupIDs: requested}
+									if len(denied) > 0 {
+										manager.sendSubscribeDenied(client, denied)
+									}
+								}
+							}
+						case EventResume:
+							// Replay what the client missed while it was away.
+							// "seqs" maps group IDs to the last sequence number seen.
+							if data, ok := msg["data"].(map[string]any); ok {
+								if seqsInterface, ok := data["seqs"].(map[string]any); ok {
+									seqs := map[string]int64{}
+									for gid, seq := range seqsInterface {
+										if number, ok := seq.(float64); ok {
+											seqs[strings.TrimSpace(gid)] = int64(number)
+										}
 									}
+									manager.resume <- Resume{Client: conn, Seqs: seqs}
 								}
 							}
-						case "echo":
+						case EventEcho:
 							// Echo message back to client
 							if _, ok := msg["data"]; ok {
-								echoMsg := map[string]any{
-									"event": "echo",
-									"data":  msg["data"],
-								}
-								echoBytes, _ := json.Marshal(echoMsg)
-								client.writeMu.Lock()
-								conn.SetWriteDeadline(time.Now().Add(writeWait))
-								conn.WriteMessage(websocket.TextMessage, echoBytes)
-								client.writeMu.Unlock()
+								manager.sendEvent(client, EventEcho, "", msg["data"])
 								log.Printf("Echoed message: %v", msg["data"])
 							}
 						default:
@@ -316,20 +600,118 @@ func (manager *WebSocketManager) HandleWebSocket(c *gin.Context) {
 	}()
 }
 
+// authorizeGroups splits requested group IDs (mapped to the access token sent
+// for them) into the ones the tokens grant access to, mapped to the grants,
+// and the ones they don't.
+func (manager *WebSocketManager) authorizeGroups(ctx context.Context, requested map[string]string) (map[string]Grant, []string) {
+	allowed := map[string]Grant{}
+	var denied []string
+	for groupID, token := range requested {
+		if groupID == "" {
+			continue
+		}
+		if grant, ok := manager.authorize(ctx, groupID, token); ok {
+			allowed[groupID] = grant
+		} else {
+			denied = append(denied, groupID)
+		}
+	}
+	return allowed, denied
+}
+
+// sendSubscribeDenied tells the client which groups it couldn't subscribe to.
+func (manager *WebSocketManager) sendSubscribeDenied(client *Client, groupIDs []string) {
+	manager.sendEvent(client, EventSubscribeDenied, "", map[string]any{"groups": groupIDs})
+}
+
+// sendEvent sends the client an event about its connection, which isn't
+// numbered or kept for replay.
+func (manager *WebSocketManager) sendEvent(client *Client, event EventType, groupID string, payload any) {
+	envelope, err := newEnvelope(event, groupID, payload)
+	if err != nil {
+		log.Printf("[socketio] Failed to marshal event %s: %v", event, err)
+		return
+	}
+	msgBytes, _ := json.Marshal(envelope)
+	manager.send(client, msgBytes)
+}
+
+// send queues a message for the client, counting it if it turns out too slow.
+func (manager *WebSocketManager) send(client *Client, message []byte) {
+	if client.enqueue(message) {
+		manager.slowClients.Add(1)
+		log.Printf("Disconnected slow client with %d messages queued", sendBufferSize)
+	}
+}
+
+// Stats describes the manager's clients and their outbound queues.
+type Stats struct {
+	Clients int `json:"clients"`
+	// QueuedMessages is the number of messages waiting in all queues
+	QueuedMessages int `json:"queuedMessages"`
+	// MaxQueueDepth is the longest queue, out of QueueCapacity
+	MaxQueueDepth int `json:"maxQueueDepth"`
+	QueueCapacity int `json:"queueCapacity"`
+	// SlowClientsDisconnected counts the clients dropped for a full queue
+	// since the server started
+	SlowClientsDisconnected int64 `json:"slowClientsDisconnected"`
+}
+
+// Stats reports the current queue depths.
+func (manager *WebSocketManager) Stats() Stats {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	stats := Stats{
+		Clients:                 len(manager.clients),
+		QueueCapacity:           sendBufferSize,
+		SlowClientsDisconnected: manager.slowClients.Load(),
+	}
+	for _, client := range manager.clients {
+		depth := len(client.send)
+		stats.QueuedMessages += depth
+		stats.MaxQueueDepth = max(stats.MaxQueueDepth, depth)
+	}
+	return stats
+}
+
+// RevokeAccess ends subscriptions to a group made with the given access
+// tokens, or all subscriptions to it if none are given, on every instance.
+func (manager *WebSocketManager) RevokeAccess(groupID string, tokenIDs ...string) {
+	if err := manager.bus.Revoke(context.Background(), Revocation{GroupID: groupID, TokenIDs: tokenIDs}); err != nil {
+		log.Printf("Failed to revoke access to group %s: %v", groupID, err)
+	}
+}
+
 // Global WebSocket manager instance
 var wsManager *WebSocketManager
 
 // InitWebSocketManager initializes the global WebSocket manager
-func InitWebSocketManager() {
-	wsManager = NewWebSocketManager()
+func InitWebSocketManager(authorize Authorizer, bus Bus) {
+	wsManager = NewWebSocketManager(authorize, bus)
 	go wsManager.Run()
 }
 
 // EmitEvent is a helper function to emit events using the global manager
-func EmitEvent(event string, payload any, groupIDs ...string) {
+func EmitEvent(ctx context.Context, event EventType, payload any, groupIDs ...string) {
 	if wsManager != nil {
-		wsManager.EmitEvent(event, payload, groupIDs...)
+		wsManager.EmitEvent(ctx, event, payload, groupIDs...)
+	}
+}
+
+// RevokeAccess ends group subscriptions using the global manager
+func RevokeAccess(groupID string, tokenIDs ...string) {
+	if wsManager != nil {
+		wsManager.RevokeAccess(groupID, tokenIDs...)
+	}
+}
+
+// GetStats reports the global manager's queue depths
+func GetStats() Stats {
+	if wsManager == nil {
+		return Stats{QueueCapacity: sendBufferSize}
 	}
+	return wsManager.Stats()
 }
 
 // HandleWebSocket handles WebSocket requests using the global manager
@@ -0,0 +1,220 @@
+package websocket
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const testGroup = "group"
+
+func testEvent(seq int64, event EventType, clientID string) Envelope {
+	return Envelope{
+		Version:   ProtocolVersion,
+		Event:     event,
+		GroupID:   testGroup,
+		Seq:       seq,
+		Timestamp: time.Now().UTC(),
+		ClientID:  clientID,
+		Data:      json.RawMessage(`{"id":"item"}`),
+	}
+}
+
+// recordEvents records events numbered from first to last.
+func recordEvents(manager *WebSocketManager, first, last int64) {
+	for seq := first; seq <= last; seq++ {
+		manager.record(testEvent(seq, EventGroceryItemUpdated, ""))
+	}
+}
+
+// received drains the messages queued for client.
+func received(t *testing.T, client *Client) []Envelope {
+	t.Helper()
+	var envelopes []Envelope
+	for {
+		select {
+		case message := <-client.send:
+			var envelope Envelope
+			if err := json.Unmarshal(message, &envelope); err != nil {
+				t.Fatalf("decoding %s: %v", message, err)
+			}
+			envelopes = append(envelopes, envelope)
+		default:
+			return envelopes
+		}
+	}
+}
+
+func seqsOf(envelopes []Envelope) []int64 {
+	seqs := make([]int64, len(envelopes))
+	for i, envelope := range envelopes {
+		seqs[i] = envelope.Seq
+	}
+	return seqs
+}
+
+func TestRecord(t *testing.T) {
+	manager := NewWebSocketManager(nil, NewLocalBus())
+
+	recordEvents(manager, 1, 3)
+	if history := manager.history[testGroup]; len(history) != 3 || history[0].seq != 1 || manager.seqs[testGroup] != 3 {
+		t.Fatalf("after 3 events the log has %d entries from %d at seq %d", len(history), history[0].seq, manager.seqs[testGroup])
+	}
+
+	// A gap starts the log over, since it has to be consecutive
+	recordEvents(manager, 7, 8)
+	if history := manager.history[testGroup]; len(history) != 2 || history[0].seq != 7 || manager.seqs[testGroup] != 8 {
+		t.Errorf("after a gap the log has %d entries from %d at seq %d, want 7 and 8", len(history), history[0].seq, manager.seqs[testGroup])
+	}
+
+	// It keeps only the latest replayLogSize events
+	recordEvents(manager, 9, 8+2*replayLogSize)
+	history := manager.history[testGroup]
+	if len(history) != replayLogSize || history[len(history)-1].seq != 8+2*replayLogSize || history[0].seq != 9+replayLogSize {
+		t.Errorf("log has %d entries from %d to %d, want the last %d", len(history), history[0].seq, history[len(history)-1].seq, replayLogSize)
+	}
+	if cap(history) > 2*replayLogSize {
+		t.Errorf("log capacity grew to %d", cap(history))
+	}
+}
+
+func TestRecordOwnMessage(t *testing.T) {
+	manager := NewWebSocketManager(nil, NewLocalBus())
+
+	anonymous := manager.record(testEvent(1, EventGroceryItemUpdated, ""))
+	if anonymous.ownMessage != nil {
+		t.Error("an event without a client ID has an own copy")
+	}
+
+	logged := manager.record(testEvent(2, EventGroceryItemUpdated, "phone"))
+	for _, tt := range []struct {
+		clientID string
+		own      bool
+	}{{"phone", true}, {"laptop", false}, {"", false}} {
+		var envelope Envelope
+		if err := json.Unmarshal(logged.messageFor(&Client{ClientID: tt.clientID}), &envelope); err != nil {
+			t.Fatal(err)
+		}
+		if envelope.Own != tt.own || envelope.ClientID != "phone" || envelope.Seq != 2 {
+			t.Errorf("client %q got %+v, want own = %v", tt.clientID, envelope, tt.own)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	manager := NewWebSocketManager(nil, NewLocalBus())
+	recordEvents(manager, 1, 5)
+	manager.record(testEvent(6, EventGroceryItemUpdated, "phone"))
+
+	tests := []struct {
+		name     string
+		lastSeq  int64
+		replayed []int64
+		final    EventType
+	}{
+		{"up to date", 6, nil, EventResumed},
+		{"missed some", 3, []int64{4, 5, 6}, EventResumed},
+		{"missed everything logged", 0, []int64{1, 2, 3, 4, 5, 6}, EventResumed},
+		{"ahead of the server", 9, nil, EventResyncRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClient(nil, map[string]Grant{testGroup: {}}, "phone")
+			manager.replay(client, testGroup, tt.lastSeq)
+
+			envelopes := received(t, client)
+			if len(envelopes) != len(tt.replayed)+1 {
+				t.Fatalf("got %d messages, want %d replayed and %s", len(envelopes), len(tt.replayed), tt.final)
+			}
+			replayed, final := envelopes[:len(envelopes)-1], envelopes[len(envelopes)-1]
+			if got := seqsOf(replayed); !slices.Equal(got, tt.replayed) {
+				t.Errorf("replayed %v, want %v", got, tt.replayed)
+			}
+			for _, envelope := range replayed {
+				if own := envelope.Seq == 6; envelope.Own != own {
+					t.Errorf("event %d has own = %v, want %v", envelope.Seq, envelope.Own, own)
+				}
+			}
+
+			var position struct {
+				GroupID string `json:"groupId"`
+				Seq     int64  `json:"seq"`
+			}
+			json.Unmarshal(final.Data, &position)
+			if final.Event != tt.final || final.GroupID != testGroup || position.Seq != 6 || final.Seq != 0 {
+				t.Errorf("final message = %s %s at %d, want %s at 6", final.Event, final.Data, final.Seq, tt.final)
+			}
+		})
+	}
+}
+
+func TestReplayOutsideTheLog(t *testing.T) {
+	manager := NewWebSocketManager(nil, NewLocalBus())
+	recordEvents(manager, 1, replayLogSize+10)
+
+	client := newClient(nil, map[string]Grant{testGroup: {}, "quiet": {}}, "")
+	manager.replay(client, testGroup, 5)
+	envelopes := received(t, client)
+	if len(envelopes) != 1 || envelopes[0].Event != EventResyncRequired {
+		t.Fatalf("resuming before the log got %+v, want resync_required", envelopes)
+	}
+
+	// A group without events since the server started has no number yet
+	manager.replay(client, "quiet", 3)
+	envelopes = received(t, client)
+	if len(envelopes) != 1 || envelopes[0].Event != EventResyncRequired || string(envelopes[0].Data) != `{"groupId":"quiet"}` {
+		t.Errorf("resuming an unknown group got %+v, want resync_required without a seq", envelopes)
+	}
+
+	// Groups the client isn't subscribed to are ignored
+	manager.replay(client, "other", 0)
+	if envelopes := received(t, client); len(envelopes) != 0 {
+		t.Errorf("resuming an unsubscribed group got %+v", envelopes)
+	}
+}
+
+func TestReplayFiltersByGrant(t *testing.T) {
+	manager := NewWebSocketManager(nil, NewLocalBus())
+	manager.record(testEvent(1, EventGroceryItemUpdated, ""))
+	manager.record(testEvent(2, EventBalancesUpdated, ""))
+	manager.record(testEvent(3, EventGroceryItemCreated, ""))
+
+	groceriesOnly := Grant{Allows: func(event string) bool { return event != string(EventBalancesUpdated) }}
+	client := newClient(nil, map[string]Grant{testGroup: groceriesOnly}, "")
+	manager.replay(client, testGroup, 0)
+
+	envelopes := received(t, client)
+	if got := seqsOf(envelopes); !slices.Equal(got, []int64{1, 3, 0}) || envelopes[2].Event != EventResumed {
+		t.Errorf("got events %v, want 1 and 3 and then resumed", got)
+	}
+}
+
+func TestPruneReplayLogs(t *testing.T) {
+	manager := NewWebSocketManager(nil, NewLocalBus())
+	recordEvents(manager, 1, 3)
+	manager.record(Envelope{Event: EventGroceryItemUpdated, GroupID: "watched", Seq: 1})
+	manager.groups["watched"] = map[*websocket.Conn]bool{nil: true}
+
+	manager.pruneReplayLogs(time.Now())
+	if _, kept := manager.seqs[testGroup]; !kept {
+		t.Fatal("a group with a recent event was pruned")
+	}
+
+	manager.pruneReplayLogs(time.Now().Add(replayRetention + time.Minute))
+	if _, kept := manager.seqs[testGroup]; kept || manager.history[testGroup] != nil {
+		t.Error("an idle group without subscribers kept its log")
+	}
+	if _, kept := manager.seqs["watched"]; !kept {
+		t.Error("an idle group with subscribers lost its log")
+	}
+
+	// Resuming a forgotten group needs a resync
+	client := newClient(nil, map[string]Grant{testGroup: {}}, "")
+	manager.replay(client, testGroup, 3)
+	if envelopes := received(t, client); len(envelopes) != 1 || envelopes[0].Event != EventResyncRequired {
+		t.Errorf("resuming a pruned group got %+v, want resync_required", envelopes)
+	}
+}